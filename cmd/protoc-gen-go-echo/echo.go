@@ -17,13 +17,16 @@ const (
 	contextPkg         = protogen.GoImportPath("context")
 	echoPkg            = protogen.GoImportPath("github.com/labstack/echo/v4")
 	metadataPkg        = protogen.GoImportPath("google.golang.org/grpc/metadata")
+	metricPkg          = protogen.GoImportPath("github.com/douyu/jupiter/pkg/core/metric")
+	xvalidatePkg       = protogen.GoImportPath("github.com/douyu/jupiter/pkg/util/xvalidate")
 	deprecationComment = "// Deprecated: Do not use."
 )
 
 var methodSets = make(map[string]int)
 
 // generateFile generates a _echo.pb.go file.
-func generateFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+// Requests are validated by xvalidate if validate is set.
+func generateFile(gen *protogen.Plugin, file *protogen.File, validate bool) *protogen.GeneratedFile {
 	if len(file.Services) == 0 {
 		return nil
 	}
@@ -42,15 +45,19 @@ func generateFile(gen *protogen.Plugin, file *protogen.File) *protogen.Generated
 	g.P("var _ = new(", contextPkg.Ident("Context"), ")")
 	g.P("var _ = ", metadataPkg.Ident("New"))
 	g.P("var _ = ", echoPkg.Ident("DefaultBinder"), "{}")
+	if validate {
+		g.P("var _ = ", metricPkg.Ident("TypeHTTP"))
+		g.P("var _ = ", xvalidatePkg.Ident("ValidateRequest"))
+	}
 	g.P()
 
 	for _, service := range file.Services {
-		genService(gen, file, g, service)
+		genService(gen, file, g, service, validate)
 	}
 	return g
 }
 
-func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.GeneratedFile, s *protogen.Service) {
+func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.GeneratedFile, s *protogen.Service, validate bool) {
 	if s.Desc.Options().(*descriptorpb.ServiceOptions).GetDeprecated() {
 		g.P("//")
 		g.P(deprecationComment)
@@ -60,6 +67,7 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 		Name:     s.GoName,
 		FullName: string(s.Desc.FullName()),
 		FilePath: file.Desc.Path(),
+		Validate: validate,
 	}
 
 	for _, method := range s.Methods {
@@ -23,6 +23,11 @@ type _echo_{{$.Name}} struct{
 		ctx.Error(err)
 		return nil
 	}
+	{{- if $.Validate}}
+	if err := xvalidate.ValidateRequest(metric.TypeHTTP, "/{{$.FullName}}/{{.Name}}", &in); err != nil {
+		return ctx.JSON(http.StatusBadRequest, err)
+	}
+	{{- end}}
 	md := metadata.New(nil)
 	for k, v := range ctx.Request().Header {
 		md.Set(k, v...)
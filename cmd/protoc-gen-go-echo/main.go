@@ -20,6 +20,7 @@ func main() {
 	}
 
 	var flags flag.FlagSet
+	validate := flags.Bool("validate", false, "validate requests with xvalidate in generated handlers")
 
 	options := protogen.Options{
 		ParamFunc: flags.Set,
@@ -31,7 +32,7 @@ func main() {
 			if !f.Generate {
 				continue
 			}
-			generateFile(gen, f)
+			generateFile(gen, f, *validate)
 		}
 		return nil
 	})
@@ -27,6 +27,12 @@ type _gin_{{$.Name}} struct{
 		ctx.Error(err)
 		return
 	}
+	{{- if $.Validate}}
+	if err := xvalidate.ValidateRequest(metric.TypeHTTP, "/{{$.FullName}}/{{.Name}}", &in); err != nil {
+		ctx.JSON(http.StatusBadRequest, err)
+		return
+	}
+	{{- end}}
 	md := metadata.New(nil)
 	for k, v := range ctx.Request.Header {
 		md.Set(k, v...)
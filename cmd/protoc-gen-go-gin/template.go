@@ -15,6 +15,7 @@ type service struct {
 	Name     string // Greeter
 	FullName string // helloworld.Greeter
 	FilePath string // api/helloworld/helloworld.proto
+	Validate bool   // 是否校验请求
 
 	Methods   []*method
 	MethodSet map[string]*method
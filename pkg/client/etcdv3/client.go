@@ -24,6 +24,8 @@ import (
 	"time"
 
 	"github.com/douyu/jupiter/pkg/core/ecode"
+	"github.com/douyu/jupiter/pkg/core/inspect"
+	"github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/xlog"
 	grpcprom "github.com/grpc-ecosystem/go-grpc-prometheus"
 	mvccpb "go.etcd.io/etcd/api/v3/mvccpb"
@@ -124,6 +126,12 @@ func newClient(config *Config) (*Client, error) {
 	}
 
 	config.logger.Info("dial etcd server")
+	inspect.Register(metric.TypeEtcd, config.Name, config, func() inspect.Stats {
+		return inspect.Stats{
+			"endpoints": client.Endpoints(),
+			"state":     client.ActiveConnection().GetState().String(),
+		}
+	})
 	return cc, nil
 }
 
@@ -15,8 +15,10 @@
 package balancer
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 
 	"google.golang.org/grpc/attributes"
 	"google.golang.org/grpc/balancer"
@@ -24,6 +26,7 @@ import (
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/grpclog"
 	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
 )
 
 // NewBalancerBuilderV2 returns a base balancer builder configured by the provided config.
@@ -44,10 +47,12 @@ type baseBuilder struct {
 // Build ...
 func (bb *baseBuilder) Build(cc balancer.ClientConn, opt balancer.BuildOptions) balancer.Balancer {
 	bal := &baseBalancer{
+		name:            bb.name,
 		cc:              cc,
 		v2PickerBuilder: bb.v2PickerBuilder,
 
 		subConns: make(map[resolver.Address]balancer.SubConn),
+		addrs:    make(map[balancer.SubConn]resolver.Address),
 		scStates: make(map[balancer.SubConn]connectivity.State),
 		csEvltr:  &balancer.ConnectivityStateEvaluator{},
 		config:   bb.config,
@@ -64,9 +69,46 @@ func (bb *baseBuilder) Name() string {
 	return bb.name
 }
 
+type lbConfig struct {
+	serviceconfig.LoadBalancingConfig
+
+	// picker config parsed by the picker builder
+	picker           serviceconfig.LoadBalancingConfig
+	outlierDetection *OutlierDetection
+}
+
+// ParseConfig parses outlierDetection in loadBalancingConfig of the
+// balancer in service config, and the whole config by the picker builder
+// if it implements balancer.ConfigParser
+func (bb *baseBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	var raw struct {
+		OutlierDetection *OutlierDetection `json:"outlierDetection"`
+	}
+	if err := json.Unmarshal(js, &raw); err != nil {
+		return nil, fmt.Errorf("%s: unmarshal config: %w", bb.name, err)
+	}
+
+	config := &lbConfig{outlierDetection: raw.OutlierDetection}
+	if config.outlierDetection != nil {
+		if err := config.outlierDetection.init(); err != nil {
+			return nil, fmt.Errorf("%s: %w", bb.name, err)
+		}
+	}
+
+	if parser, ok := bb.v2PickerBuilder.(balancer.ConfigParser); ok {
+		picker, err := parser.ParseConfig(js)
+		if err != nil {
+			return nil, err
+		}
+		config.picker = picker
+	}
+	return config, nil
+}
+
 var _ balancer.Balancer = (*baseBalancer)(nil) // Assert that we implement V2Balancer
 
 type baseBalancer struct {
+	name            string
 	cc              balancer.ClientConn
 	v2PickerBuilder PickerBuilder
 
@@ -74,10 +116,18 @@ type baseBalancer struct {
 	state   connectivity.State
 
 	subConns   map[resolver.Address]balancer.SubConn
+	addrs      map[balancer.SubConn]resolver.Address
 	scStates   map[balancer.SubConn]connectivity.State
 	v2Picker   balancer.Picker
 	config     base.Config
 	attributes *attributes.Attributes
+	lbConfig   *lbConfig
+
+	// mu guards the balancer against ejections of the outlier detector,
+	// which happen out of the calls of grpc
+	mu       sync.Mutex
+	detector *outlierDetector
+	closed   bool
 }
 
 // HandleResolvedAddrs ...
@@ -87,6 +137,9 @@ func (b *baseBalancer) HandleResolvedAddrs(addrs []resolver.Address, err error)
 
 // ResolverError ...
 func (b *baseBalancer) ResolverError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	switch b.state {
 	case connectivity.TransientFailure, connectivity.Idle, connectivity.Connecting:
 		b.v2Picker = NewErrPickerV2(err)
@@ -100,16 +153,23 @@ func (b *baseBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
 	if grpclog.V(2) {
 		grpclog.Infoln("base.baseBalancer: got new ClientConn state: ", s)
 	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	// addrsSet is the set converted from addrs, it's used for quick lookup of an address.
 	addrsSet := make(map[resolver.Address]struct{})
-	fmt.Printf("s.ResolverState.Addresses = %+v\n", s.ResolverState.Addresses)
-	fmt.Printf("s.ResolverState.Attributes = %+v\n", s.ResolverState.Attributes)
-	for _, a := range s.ResolverState.Addresses {
+	for _, addr := range s.ResolverState.Addresses {
+		// attributes are recreated on every resolver update, so SubConns
+		// are keyed by address without them, and the latest address is
+		// kept for pickers
+		a := resolver.Address{Addr: addr.Addr, ServerName: addr.ServerName}
 		addrsSet[a] = struct{}{}
-		if _, ok := b.subConns[a]; !ok {
+		if sc, ok := b.subConns[a]; ok {
+			b.addrs[sc] = addr
+		} else {
 			// a is a new address (not existing in b.subConns).
 			sc, err := b.cc.NewSubConn(
-				[]resolver.Address{a},
+				[]resolver.Address{addr},
 				balancer.NewSubConnOptions{HealthCheckEnabled: b.config.HealthCheck},
 			)
 			if err != nil {
@@ -117,28 +177,40 @@ func (b *baseBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
 				continue
 			}
 			b.subConns[a] = sc
+			b.addrs[sc] = addr
 			b.scStates[sc] = connectivity.Idle
 			sc.Connect()
 		}
 	}
 
 	b.attributes = s.ResolverState.Attributes
+	b.lbConfig, _ = s.BalancerConfig.(*lbConfig)
 
 	for a, sc := range b.subConns {
 		// a was removed by resolver.
 		if _, ok := addrsSet[a]; !ok {
 			b.cc.RemoveSubConn(sc)
 			delete(b.subConns, a)
+			delete(b.addrs, sc)
 			// Keep the state of this sc in b.scStates until sc's state becomes Shutdown.
 			// The entry will be deleted in HandleSubConnStateChange.
 		}
 	}
+
+	b.updateDetector()
+
+	// addresses or attributes changed, pickers may depend on them
+	if b.state != connectivity.TransientFailure {
+		b.regeneratePicker(nil)
+		b.cc.UpdateState(balancer.State{ConnectivityState: b.state, Picker: b.v2Picker})
+	}
 	return nil
 }
 
 // regeneratePicker takes a snapshot of the balancer, and generates a picker
 // from it. The picker is
 //   - errPicker with ErrTransientFailure if the balancer is in TransientFailure,
+//   - errPicker with ErrNoSubConnAvailable if no SubConn is READY,
 //   - built by the pickerBuilder with all READY SubConns otherwise.
 func (b *baseBalancer) regeneratePicker(err error) {
 	if b.state == connectivity.TransientFailure {
@@ -154,19 +226,81 @@ func (b *baseBalancer) regeneratePicker(err error) {
 		return
 	}
 	readySCs := make(map[balancer.SubConn]base.SubConnInfo)
+	subConns := make(map[balancer.SubConn]base.SubConnInfo)
+	var ejectedSCs []balancer.SubConn
 
 	// Filter out all ready SCs from full subConn map.
-	for addr, sc := range b.subConns {
+	for _, sc := range b.subConns {
+		subConns[sc] = base.SubConnInfo{Address: b.addrs[sc]}
 		if st, ok := b.scStates[sc]; ok && st == connectivity.Ready {
-			readySCs[sc] = base.SubConnInfo{Address: addr}
+			readySCs[sc] = base.SubConnInfo{Address: b.addrs[sc]}
+			if b.detector != nil && b.detector.ejected(sc) {
+				ejectedSCs = append(ejectedSCs, sc)
+			}
 		}
 	}
+	// ejected SubConns are picked only if there is no other ready one
+	if len(ejectedSCs) < len(readySCs) {
+		for _, sc := range ejectedSCs {
+			delete(readySCs, sc)
+		}
+	}
+	if len(readySCs) == 0 {
+		b.v2Picker = NewErrPickerV2(balancer.ErrNoSubConnAvailable)
+		return
+	}
+
+	var config serviceconfig.LoadBalancingConfig
+	if b.lbConfig != nil {
+		config = b.lbConfig.picker
+	}
 	b.v2Picker = b.v2PickerBuilder.Build(
 		PickerBuildInfo{
 			ReadySCs:   readySCs,
+			SubConns:   subConns,
+			Config:     config,
 			Attributes: b.attributes,
 		},
 	)
+	if b.detector != nil {
+		b.v2Picker = &outlierPicker{Picker: b.v2Picker, detector: b.detector}
+	}
+}
+
+// updateDetector creates, updates or closes the outlier detector by the
+// config, and syncs SubConns to it
+func (b *baseBalancer) updateDetector() {
+	var config *OutlierDetection
+	if b.lbConfig != nil {
+		config = b.lbConfig.outlierDetection
+	}
+
+	switch {
+	case config == nil && b.detector != nil:
+		b.detector.close()
+		b.detector = nil
+	case config != nil && b.detector == nil:
+		b.detector = newOutlierDetector(b.name, config, b.handleEjection)
+	case config != nil:
+		b.detector.setConfig(config)
+	}
+
+	if b.detector != nil {
+		b.detector.update(b.addrs)
+	}
+}
+
+// handleEjection regenerates the picker after SubConns are ejected or
+// readmitted
+func (b *baseBalancer) handleEjection() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed || b.state == connectivity.TransientFailure {
+		return
+	}
+	b.regeneratePicker(nil)
+	b.cc.UpdateState(balancer.State{ConnectivityState: b.state, Picker: b.v2Picker})
 }
 
 // HandleSubConnStateChange ...
@@ -180,6 +314,9 @@ func (b *baseBalancer) UpdateSubConnState(sc balancer.SubConn, state balancer.Su
 	if grpclog.V(2) {
 		grpclog.Infof("base.baseBalancer: handle SubConn state change: %p, %v", sc, s)
 	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	oldS, ok := b.scStates[sc]
 	if !ok {
 		if grpclog.V(2) {
@@ -213,9 +350,17 @@ func (b *baseBalancer) UpdateSubConnState(sc balancer.SubConn, state balancer.Su
 	b.cc.UpdateState(balancer.State{ConnectivityState: b.state, Picker: b.v2Picker})
 }
 
-// Close is a nop because base balancer doesn't have internal state to clean up,
-// and it doesn't need to call RemoveSubConn for the SubConns.
+// Close stops the outlier detector, it doesn't need to call RemoveSubConn
+// for the SubConns.
 func (b *baseBalancer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	if b.detector != nil {
+		b.detector.close()
+		b.detector = nil
+	}
 }
 
 // NewErrPickerV2 returns a V2Picker that always returns err on Pick().
@@ -0,0 +1,218 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package consistenthash provides a balancer which maps requests of the
+// same hash key to the same node with a ketama ring.
+package consistenthash
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	xbalancer "github.com/douyu/jupiter/pkg/client/grpc/balancer"
+	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/core/imeta"
+	"github.com/douyu/jupiter/pkg/server"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// Name is the name of consistent hash balancer
+const Name = "consistent_hash"
+
+const (
+	// DefaultHashKey ...
+	DefaultHashKey = "x-hash-key"
+	// DefaultReplicas ...
+	DefaultReplicas = 160
+
+	// defaultWeight weight of nodes registered without weight
+	defaultWeight = 100
+)
+
+func init() {
+	balancer.Register(
+		xbalancer.NewBalancerBuilderV2(Name, &pickerBuilder{}, base.Config{HealthCheck: true}),
+	)
+}
+
+type hashKey struct{}
+
+// WithHashKey returns a context whose requests are hashed by key, which
+// takes precedence over the metadata
+func WithHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKey{}, key)
+}
+
+// Config consistent hash balancer config, set by balancerConfig of grpc client
+type Config struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+
+	// HashKey 哈希键的元数据名，依次从 WithHashKey、outgoing metadata 和 imeta 中读取，
+	// 默认 x-hash-key，没有哈希键的请求随机选择节点
+	HashKey string `json:"hashKey"`
+	// Replicas 权重为100的节点在哈希环上的虚拟节点数，按节点权重等比例增减，默认160
+	Replicas int `json:"replicas"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		HashKey:  DefaultHashKey,
+		Replicas: DefaultReplicas,
+	}
+}
+
+type pickerBuilder struct{}
+
+// ParseConfig ...
+func (*pickerBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	config := defaultConfig()
+	if err := json.Unmarshal(js, config); err != nil {
+		return nil, fmt.Errorf("consistent_hash: unmarshal config: %w", err)
+	}
+	if config.HashKey == "" {
+		return nil, fmt.Errorf("consistent_hash: empty hashKey")
+	}
+	if config.Replicas <= 0 {
+		return nil, fmt.Errorf("consistent_hash: replicas should be positive")
+	}
+	return config, nil
+}
+
+type point struct {
+	hash uint32
+	addr string
+	sc   balancer.SubConn
+}
+
+// Build builds the ring with all nodes rather than the ready ones, so
+// keys of a node which is not ready fall to the next node on the ring
+// and return once it's ready, keys of the other nodes stay.
+func (*pickerBuilder) Build(info xbalancer.PickerBuildInfo) balancer.Picker {
+	config, _ := info.Config.(*Config)
+	if config == nil {
+		config = defaultConfig()
+	}
+	if len(info.ReadySCs) == 0 {
+		return xbalancer.NewErrPickerV2(balancer.ErrNoSubConnAvailable)
+	}
+
+	p := &picker{
+		hashKey: config.HashKey,
+		ready:   make(map[balancer.SubConn]bool, len(info.ReadySCs)),
+	}
+	for sc, sci := range info.SubConns {
+		weight := float64(defaultWeight)
+		if node, ok := sci.Address.Attributes.Value(constant.KeyServiceInfo).(server.ServiceInfo); ok && node.Weight > 0 {
+			weight = node.Weight
+		}
+		p.ring = append(p.ring, points(sc, sci.Address.Addr, virtualNodes(config.Replicas, weight))...)
+	}
+	for sc := range info.ReadySCs {
+		p.ready[sc] = true
+		p.readySCs = append(p.readySCs, sc)
+	}
+
+	sort.Slice(p.ring, func(i, j int) bool {
+		if p.ring[i].hash != p.ring[j].hash {
+			return p.ring[i].hash < p.ring[j].hash
+		}
+		return p.ring[i].addr < p.ring[j].addr
+	})
+	return p
+}
+
+func virtualNodes(replicas int, weight float64) int {
+	n := int(math.Round(float64(replicas) * weight / defaultWeight))
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// points returns n ketama points of addr, each md5 digest makes four
+func points(sc balancer.SubConn, addr string, n int) []point {
+	list := make([]point, 0, n+3)
+	for i := 0; len(list) < n; i++ {
+		digest := md5.Sum([]byte(addr + "-" + strconv.Itoa(i)))
+		for j := 0; j < 4 && len(list) < n; j++ {
+			list = append(list, point{
+				hash: binary.LittleEndian.Uint32(digest[j*4:]),
+				addr: addr,
+				sc:   sc,
+			})
+		}
+	}
+	return list
+}
+
+func hash(key string) uint32 {
+	digest := md5.Sum([]byte(key))
+	return binary.LittleEndian.Uint32(digest[:4])
+}
+
+type picker struct {
+	hashKey  string
+	ring     []point
+	ready    map[balancer.SubConn]bool
+	readySCs []balancer.SubConn
+}
+
+// Pick picks the first ready node clockwise from the hash of the key
+func (p *picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	key, ok := p.key(info.Ctx)
+	if !ok {
+		return balancer.PickResult{SubConn: p.readySCs[rand.Intn(len(p.readySCs))]}, nil
+	}
+
+	h := hash(key)
+	start := sort.Search(len(p.ring), func(i int) bool {
+		return p.ring[i].hash >= h
+	})
+	for i := 0; i < len(p.ring); i++ {
+		if pt := p.ring[(start+i)%len(p.ring)]; p.ready[pt.sc] {
+			return balancer.PickResult{SubConn: pt.sc}, nil
+		}
+	}
+	return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+}
+
+func (p *picker) key(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	if key, ok := ctx.Value(hashKey{}).(string); ok {
+		return key, true
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if values := md.Get(p.hashKey); len(values) > 0 {
+			return values[0], true
+		}
+	}
+	if md, ok := imeta.FromContext(ctx); ok {
+		if values := md.Get(p.hashKey); len(values) > 0 {
+			return values[0], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consistenthash
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	xbalancer "github.com/douyu/jupiter/pkg/client/grpc/balancer"
+	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/core/imeta"
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+)
+
+type fakeSubConn struct {
+	balancer.SubConn
+	name string
+}
+
+type node struct {
+	name     string
+	weight   float64
+	notReady bool
+}
+
+func buildInfo(config *Config, nodes ...node) xbalancer.PickerBuildInfo {
+	info := xbalancer.PickerBuildInfo{
+		ReadySCs: make(map[balancer.SubConn]base.SubConnInfo),
+		SubConns: make(map[balancer.SubConn]base.SubConnInfo),
+		Config:   config,
+	}
+	for _, n := range nodes {
+		sc := &fakeSubConn{name: n.name}
+		sci := base.SubConnInfo{Address: resolver.Address{
+			Addr:       n.name,
+			Attributes: attributes.New(constant.KeyServiceInfo, server.ServiceInfo{Address: n.name, Weight: n.weight}),
+		}}
+		info.SubConns[sc] = sci
+		if !n.notReady {
+			info.ReadySCs[sc] = sci
+		}
+	}
+	return info
+}
+
+// mapping returns node names of 10000 keys
+func mapping(t *testing.T, p balancer.Picker) []string {
+	names := make([]string, 10000)
+	for i := range names {
+		res, err := p.Pick(balancer.PickInfo{Ctx: WithHashKey(context.Background(), "user-"+strconv.Itoa(i))})
+		assert.Nil(t, err)
+		names[i] = res.SubConn.(*fakeSubConn).name
+	}
+	return names
+}
+
+func count(names []string) map[string]int {
+	counts := make(map[string]int)
+	for _, name := range names {
+		counts[name]++
+	}
+	return counts
+}
+
+func TestPickerBuilder(t *testing.T) {
+	pb := &pickerBuilder{}
+	nodes := []node{{name: "a"}, {name: "b"}, {name: "c", weight: 200}}
+	before := mapping(t, pb.Build(buildInfo(nil, nodes...)))
+
+	// c takes about half of the keys by weight
+	counts := count(before)
+	assert.InDelta(t, 2500, counts["a"], 500, counts)
+	assert.InDelta(t, 2500, counts["b"], 500, counts)
+	assert.InDelta(t, 5000, counts["c"], 500, counts)
+
+	// the ring doesn't depend on the order of nodes
+	assert.Equal(t, before, mapping(t, pb.Build(buildInfo(nil, nodes[2], nodes[0], nodes[1]))))
+
+	// only keys of the new node move
+	after := mapping(t, pb.Build(buildInfo(nil, append(nodes, node{name: "d"})...)))
+	moved := 0
+	for i := range before {
+		if before[i] != after[i] {
+			assert.Equal(t, "d", after[i])
+			moved++
+		}
+	}
+	assert.InDelta(t, 2000, moved, 500)
+
+	// keys of the node not ready move to the others, and the others stay
+	after = mapping(t, pb.Build(buildInfo(nil, node{name: "a"}, node{name: "b", notReady: true}, node{name: "c", weight: 200})))
+	for i := range before {
+		if before[i] != "b" {
+			assert.Equal(t, before[i], after[i])
+		} else {
+			assert.NotEqual(t, "b", after[i])
+		}
+	}
+}
+
+func TestPicker_Key(t *testing.T) {
+	p := (&pickerBuilder{}).Build(buildInfo(&Config{HashKey: "uid", Replicas: 10}, node{name: "a"}, node{name: "b"}, node{name: "c"})).(*picker)
+
+	pick := func(ctx context.Context) string {
+		res, err := p.Pick(balancer.PickInfo{Ctx: ctx})
+		assert.Nil(t, err)
+		return res.SubConn.(*fakeSubConn).name
+	}
+	want := pick(WithHashKey(context.Background(), "42"))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, want, pick(metadata.AppendToOutgoingContext(context.Background(), "uid", "42")))
+		assert.Equal(t, want, pick(imeta.WithContext(context.Background(), imeta.Pairs("uid", "42"))))
+	}
+
+	// random without key
+	names := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		names[pick(metadata.AppendToOutgoingContext(context.Background(), "x-hash-key", "42"))] = true
+	}
+	assert.Len(t, names, 3)
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := (&pickerBuilder{}).ParseConfig([]byte(`{"hashKey": "uid"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, &Config{HashKey: "uid", Replicas: DefaultReplicas}, config)
+
+	_, err = (&pickerBuilder{}).ParseConfig([]byte(`{"replicas": -1}`))
+	assert.NotNil(t, err)
+}
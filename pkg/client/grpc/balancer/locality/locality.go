@@ -0,0 +1,176 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package locality provides a balancer which prefers nodes in the same
+// zone, then the same region, and isolates traffic by deployment.
+package locality
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/douyu/jupiter/pkg"
+	xbalancer "github.com/douyu/jupiter/pkg/client/grpc/balancer"
+	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/douyu/jupiter/pkg/server"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/serviceconfig"
+	"google.golang.org/grpc/status"
+)
+
+// Name is the name of locality aware balancer
+const Name = "locality"
+
+// DefaultSpilloverThreshold ...
+const DefaultSpilloverThreshold = 0.7
+
+// localities of nodes relative to the client, in order of preference
+const (
+	localityZone = iota
+	localityRegion
+	localityOther
+)
+
+var localityNames = [...]string{"zone", "region", "other"}
+
+func init() {
+	balancer.Register(
+		xbalancer.NewBalancerBuilderV2(Name, &pickerBuilder{}, base.Config{HealthCheck: true}),
+	)
+}
+
+// Config locality balancer config, set by balancerConfig of grpc client
+type Config struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+
+	// Region region of the client, pkg.AppRegion() by default
+	Region string `json:"region"`
+	// Zone zone of the client, pkg.AppZone() by default
+	Zone string `json:"zone"`
+	// Deployment 部署组，只会选择相同部署组的节点，不同部署组的流量严格隔离
+	Deployment string `json:"deployment"`
+	// SpilloverThreshold 当前位置可用节点占比低于该值时，流量溢出到下一级位置，
+	// zone => region => other, 默认0.7
+	SpilloverThreshold float64 `json:"spilloverThreshold"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Region:             pkg.AppRegion(),
+		Zone:               pkg.AppZone(),
+		SpilloverThreshold: DefaultSpilloverThreshold,
+	}
+}
+
+// locality returns the locality of node relative to the client
+func (config *Config) locality(node server.ServiceInfo) int {
+	if node.Region != config.Region {
+		return localityOther
+	}
+	if config.Zone != "" && config.Zone != "unknown" && node.Zone == config.Zone {
+		return localityZone
+	}
+	if config.Region != "" {
+		return localityRegion
+	}
+	return localityOther
+}
+
+type pickerBuilder struct{}
+
+// ParseConfig ...
+func (*pickerBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	config := defaultConfig()
+	if err := json.Unmarshal(js, config); err != nil {
+		return nil, fmt.Errorf("locality: unmarshal config: %w", err)
+	}
+	if config.SpilloverThreshold < 0 || config.SpilloverThreshold > 1 {
+		return nil, fmt.Errorf("locality: spilloverThreshold should be in [0, 1]")
+	}
+	return config, nil
+}
+
+type tier struct {
+	total int
+	ready []balancer.SubConn
+}
+
+// Build picks from ready nodes of the same deployment in the nearest
+// localities, farther localities are added until ready nodes are no less
+// than SpilloverThreshold of all nodes of the added localities.
+func (*pickerBuilder) Build(info xbalancer.PickerBuildInfo) balancer.Picker {
+	config, ok := info.Config.(*Config)
+	if !ok {
+		config = defaultConfig()
+	}
+
+	var tiers [len(localityNames)]tier
+	var serverName string
+	for sc, sci := range info.SubConns {
+		node, _ := sci.Address.Attributes.Value(constant.KeyServiceInfo).(server.ServiceInfo)
+		// 部署组流量隔离
+		if node.Deployment != config.Deployment {
+			continue
+		}
+
+		t := &tiers[config.locality(node)]
+		t.total++
+		if _, ok := info.ReadySCs[sc]; ok {
+			t.ready = append(t.ready, sc)
+		}
+		serverName = sci.Address.ServerName
+	}
+
+	p := &picker{serverName: serverName}
+	total := 0
+	for i := range tiers {
+		total += tiers[i].total
+		for _, sc := range tiers[i].ready {
+			p.subConns = append(p.subConns, sc)
+			p.localities = append(p.localities, localityNames[i])
+		}
+		if len(p.subConns) > 0 && float64(len(p.subConns)) >= config.SpilloverThreshold*float64(total) {
+			break
+		}
+	}
+
+	switch {
+	case total == 0:
+		return xbalancer.NewErrPickerV2(status.Errorf(codes.Unavailable, "locality: no node of deployment %q", config.Deployment))
+	case len(p.subConns) == 0:
+		return xbalancer.NewErrPickerV2(balancer.ErrNoSubConnAvailable)
+	}
+
+	p.next = uint32(rand.Intn(len(p.subConns)))
+	return p
+}
+
+type picker struct {
+	serverName string
+	subConns   []balancer.SubConn
+	localities []string
+	next       uint32
+}
+
+// Pick round-robins the picked nodes
+func (p *picker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	i := int(atomic.AddUint32(&p.next, 1) % uint32(len(p.subConns)))
+	metric.ClientPickCounter.Inc(Name, p.serverName, p.localities[i])
+	return balancer.PickResult{SubConn: p.subConns[i]}, nil
+}
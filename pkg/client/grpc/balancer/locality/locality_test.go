@@ -0,0 +1,139 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locality
+
+import (
+	"testing"
+
+	xbalancer "github.com/douyu/jupiter/pkg/client/grpc/balancer"
+	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+)
+
+type fakeSubConn struct {
+	balancer.SubConn
+	name string
+}
+
+type node struct {
+	name       string
+	region     string
+	zone       string
+	deployment string
+	ready      bool
+}
+
+func buildInfo(config *Config, nodes ...node) xbalancer.PickerBuildInfo {
+	info := xbalancer.PickerBuildInfo{
+		ReadySCs: make(map[balancer.SubConn]base.SubConnInfo),
+		SubConns: make(map[balancer.SubConn]base.SubConnInfo),
+		Config:   config,
+	}
+	for _, n := range nodes {
+		sc := &fakeSubConn{name: n.name}
+		sci := base.SubConnInfo{Address: resolver.Address{
+			Addr:       n.name,
+			ServerName: "grpc:locality.test:v1:test/",
+			Attributes: attributes.New(constant.KeyServiceInfo, server.ServiceInfo{
+				Address:    n.name,
+				Region:     n.region,
+				Zone:       n.zone,
+				Deployment: n.deployment,
+			}),
+		}}
+		info.SubConns[sc] = sci
+		if n.ready {
+			info.ReadySCs[sc] = sci
+		}
+	}
+	return info
+}
+
+func picked(t *testing.T, p balancer.Picker) []string {
+	names := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		res, err := p.Pick(balancer.PickInfo{})
+		assert.Nil(t, err)
+		names[res.SubConn.(*fakeSubConn).name] = true
+	}
+
+	list := make([]string, 0, len(names))
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if names[name] {
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+func TestPickerBuilder(t *testing.T) {
+	nodes := []node{
+		{name: "a", region: "r1", zone: "z1", ready: true},
+		{name: "b", region: "r1", zone: "z1"},
+		{name: "c", region: "r1", zone: "z2", ready: true},
+		{name: "d", region: "r2", zone: "z3", ready: true},
+		{name: "e", region: "r1", zone: "z1", deployment: "gray", ready: true},
+	}
+	pb := &pickerBuilder{}
+
+	// half of the zone is ready
+	zonePicks := metric.ClientPickCounter.WithLabelValues(Name, "grpc:locality.test:v1:test/", "zone")
+	before := testutil.ToFloat64(zonePicks)
+	p := pb.Build(buildInfo(&Config{Region: "r1", Zone: "z1", SpilloverThreshold: 0.5}, nodes...))
+	assert.Equal(t, []string{"a"}, picked(t, p))
+	assert.Equal(t, float64(20), testutil.ToFloat64(zonePicks)-before)
+
+	// spill over to the region, then the others
+	p = pb.Build(buildInfo(&Config{Region: "r1", Zone: "z1", SpilloverThreshold: 0.6}, nodes...))
+	assert.Equal(t, []string{"a", "c"}, picked(t, p))
+	p = pb.Build(buildInfo(&Config{Region: "r1", Zone: "z1", SpilloverThreshold: 0.7}, nodes...))
+	assert.Equal(t, []string{"a", "c", "d"}, picked(t, p))
+
+	// no zone of client
+	p = pb.Build(buildInfo(&Config{Region: "r2", Zone: "unknown", SpilloverThreshold: 0.7}, nodes...))
+	assert.Equal(t, []string{"d"}, picked(t, p))
+
+	// isolated by deployment
+	p = pb.Build(buildInfo(&Config{Region: "r2", Zone: "z3", Deployment: "gray", SpilloverThreshold: 0.7}, nodes...))
+	assert.Equal(t, []string{"e"}, picked(t, p))
+
+	p = pb.Build(buildInfo(&Config{Deployment: "none"}, nodes...))
+	_, err := p.Pick(balancer.PickInfo{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	p = pb.Build(buildInfo(&Config{Region: "r1", Zone: "z1"}, nodes[1]))
+	_, err = p.Pick(balancer.PickInfo{})
+	assert.Equal(t, balancer.ErrNoSubConnAvailable, err)
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := (&pickerBuilder{}).ParseConfig([]byte(`{"zone": "z1", "deployment": "gray"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "z1", config.(*Config).Zone)
+	assert.Equal(t, "gray", config.(*Config).Deployment)
+	assert.Equal(t, DefaultSpilloverThreshold, config.(*Config).SpilloverThreshold)
+
+	_, err = (&pickerBuilder{}).ParseConfig([]byte(`{"spilloverThreshold": 1.5}`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,362 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package balancer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/douyu/jupiter/pkg/xlog"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+)
+
+// reasons of ejections
+const (
+	reasonConsecutiveFailures = "consecutive_failures"
+	reasonFailureRate         = "failure_rate"
+	reasonProbe               = "probe"
+)
+
+// OutlierDetection 被动异常检测配置，按节点统计请求失败情况，摘除异常节点。
+// 失败指 UNAVAILABLE、DEADLINE_EXCEEDED、INTERNAL 和 RESOURCE_EXHAUSTED 错误，业务错误不计入。
+// 以 outlierDetection 为键配置在负载均衡器配置中，对 swr、xp2c、locality、consistent_hash 生效
+type OutlierDetection struct {
+	// ConsecutiveFailures 连续失败次数达到该值时摘除节点，与 failureRate 都未配置时默认5
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// FailureRate 统计周期内失败率达到该值时摘除节点，取值(0, 1]，默认0为不检测
+	FailureRate float64 `json:"failureRate"`
+	// MinimumRequests 统计周期内请求数少于该值时不按失败率摘除，默认10
+	MinimumRequests int `json:"minimumRequests"`
+	// Interval 失败率的统计周期，默认10s
+	Interval string `json:"interval"`
+	// BaseEjectionTime 首次摘除时长，节点再次被摘除时时长翻倍，默认30s
+	BaseEjectionTime string `json:"baseEjectionTime"`
+	// MaxEjectionTime 摘除时长上限，默认300s
+	MaxEjectionTime string `json:"maxEjectionTime"`
+	// MaxEjectionPercent 最多摘除节点的百分比，默认50，至少保留一个节点
+	MaxEjectionPercent int `json:"maxEjectionPercent"`
+
+	interval         time.Duration
+	baseEjectionTime time.Duration
+	maxEjectionTime  time.Duration
+}
+
+func (od *OutlierDetection) init() error {
+	if od.ConsecutiveFailures == 0 && od.FailureRate == 0 {
+		od.ConsecutiveFailures = 5
+	}
+	if od.MinimumRequests == 0 {
+		od.MinimumRequests = 10
+	}
+	if od.MaxEjectionPercent == 0 {
+		od.MaxEjectionPercent = 50
+	}
+	if od.FailureRate < 0 || od.FailureRate > 1 {
+		return fmt.Errorf("outlierDetection: failureRate should be in [0, 1]")
+	}
+	if od.MaxEjectionPercent < 0 || od.MaxEjectionPercent > 100 {
+		return fmt.Errorf("outlierDetection: maxEjectionPercent should be in [0, 100]")
+	}
+
+	for _, field := range []struct {
+		value string
+		def   time.Duration
+		to    *time.Duration
+	}{
+		{od.Interval, 10 * time.Second, &od.interval},
+		{od.BaseEjectionTime, 30 * time.Second, &od.baseEjectionTime},
+		{od.MaxEjectionTime, 300 * time.Second, &od.maxEjectionTime},
+	} {
+		*field.to = field.def
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("outlierDetection: invalid duration %q", field.value)
+		}
+		*field.to = d
+	}
+	if od.maxEjectionTime < od.baseEjectionTime {
+		od.maxEjectionTime = od.baseEjectionTime
+	}
+	return nil
+}
+
+// failure reports whether err is caused by the endpoint rather than the
+// caller or the business
+func failure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+type endpoint struct {
+	addr resolver.Address
+
+	consecutiveFailures int
+	requests            int
+	failures            int
+
+	// ejections multiplier of the ejection time, decreased by one for
+	// every interval without ejection
+	ejections int
+	ejected   bool
+	// probing readmitted endpoint, the next request decides whether it's
+	// ejected again
+	probing bool
+	timer   *time.Timer
+}
+
+// outlierDetector tracks results of requests of each SubConn, and calls
+// onChange when the ejected SubConns change, which may be in any goroutine.
+type outlierDetector struct {
+	name     string
+	onChange func()
+
+	mu        sync.Mutex
+	config    *OutlierDetection
+	endpoints map[balancer.SubConn]*endpoint
+	ticker    *time.Ticker
+	done      chan struct{}
+}
+
+func newOutlierDetector(name string, config *OutlierDetection, onChange func()) *outlierDetector {
+	od := &outlierDetector{
+		name:      name,
+		onChange:  onChange,
+		config:    config,
+		endpoints: make(map[balancer.SubConn]*endpoint),
+		ticker:    time.NewTicker(config.interval),
+		done:      make(chan struct{}),
+	}
+	go od.run(od.ticker, od.done)
+	return od
+}
+
+func (od *outlierDetector) setConfig(config *OutlierDetection) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	if od.config.interval != config.interval {
+		od.ticker.Reset(config.interval)
+	}
+	od.config = config
+}
+
+// update syncs endpoints with SubConns of the balancer
+func (od *outlierDetector) update(addrs map[balancer.SubConn]resolver.Address) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	for sc, ep := range od.endpoints {
+		if _, ok := addrs[sc]; !ok {
+			if ep.timer != nil {
+				ep.timer.Stop()
+			}
+			if ep.ejected {
+				metric.ClientEjectedGauge.Set(0, od.name, ep.addr.ServerName, ep.addr.Addr)
+			}
+			delete(od.endpoints, sc)
+		}
+	}
+	for sc, addr := range addrs {
+		if ep, ok := od.endpoints[sc]; ok {
+			ep.addr = addr
+			continue
+		}
+		od.endpoints[sc] = &endpoint{addr: addr}
+	}
+}
+
+// ejected reports whether sc is ejected
+func (od *outlierDetector) ejected(sc balancer.SubConn) bool {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	ep, ok := od.endpoints[sc]
+	return ok && ep.ejected
+}
+
+// record records the result of a request
+func (od *outlierDetector) record(sc balancer.SubConn, err error) {
+	od.mu.Lock()
+	changed := od.recordLocked(sc, failure(err))
+	od.mu.Unlock()
+
+	if changed {
+		od.onChange()
+	}
+}
+
+func (od *outlierDetector) recordLocked(sc balancer.SubConn, failed bool) bool {
+	ep, ok := od.endpoints[sc]
+	if !ok || ep.ejected {
+		return false
+	}
+
+	if ep.probing {
+		ep.probing = false
+		if failed {
+			return od.eject(ep, reasonProbe)
+		}
+		xlog.Jupiter().Info("outlier endpoint recovered", xlog.FieldName(ep.addr.ServerName), xlog.FieldAddr(ep.addr.Addr))
+	}
+
+	ep.requests++
+	if !failed {
+		ep.consecutiveFailures = 0
+		return false
+	}
+	ep.failures++
+	ep.consecutiveFailures++
+	if od.config.ConsecutiveFailures > 0 && ep.consecutiveFailures >= od.config.ConsecutiveFailures {
+		return od.eject(ep, reasonConsecutiveFailures)
+	}
+	return false
+}
+
+// eject ejects ep if the max ejection percent allows
+func (od *outlierDetector) eject(ep *endpoint, reason string) bool {
+	ejected := 0
+	for _, e := range od.endpoints {
+		if e.ejected {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > od.config.MaxEjectionPercent*len(od.endpoints) || ejected+1 >= len(od.endpoints) {
+		xlog.Jupiter().Warn("outlier endpoint not ejected for max ejection percent",
+			xlog.FieldName(ep.addr.ServerName), xlog.FieldAddr(ep.addr.Addr), xlog.String("reason", reason))
+		return false
+	}
+
+	ep.ejections++
+	ejection := od.config.baseEjectionTime
+	for i := 1; i < ep.ejections && ejection < od.config.maxEjectionTime; i++ {
+		ejection *= 2
+	}
+	if ejection > od.config.maxEjectionTime {
+		ejection = od.config.maxEjectionTime
+	}
+
+	ep.ejected = true
+	ep.probing = false
+	ep.consecutiveFailures, ep.requests, ep.failures = 0, 0, 0
+	ep.timer = time.AfterFunc(ejection, func() { od.readmit(ep) })
+
+	xlog.Jupiter().Warn("outlier endpoint ejected",
+		xlog.FieldName(ep.addr.ServerName), xlog.FieldAddr(ep.addr.Addr), xlog.String("reason", reason), xlog.FieldCost(ejection))
+	metric.ClientEjectionCounter.Inc(od.name, ep.addr.ServerName, ep.addr.Addr, reason)
+	metric.ClientEjectedGauge.Set(1, od.name, ep.addr.ServerName, ep.addr.Addr)
+	return true
+}
+
+func (od *outlierDetector) readmit(ep *endpoint) {
+	od.mu.Lock()
+	if !ep.ejected {
+		od.mu.Unlock()
+		return
+	}
+	ep.ejected = false
+	ep.probing = true
+	ep.timer = nil
+	addr := ep.addr
+	od.mu.Unlock()
+
+	xlog.Jupiter().Info("outlier endpoint readmitted for probing", xlog.FieldName(addr.ServerName), xlog.FieldAddr(addr.Addr))
+	metric.ClientEjectedGauge.Set(0, od.name, addr.ServerName, addr.Addr)
+	od.onChange()
+}
+
+func (od *outlierDetector) run(ticker *time.Ticker, done chan struct{}) {
+	for {
+		select {
+		case <-ticker.C:
+			if od.sweep() {
+				od.onChange()
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// sweep ejects endpoints by failure rate of the interval, and starts a
+// new interval
+func (od *outlierDetector) sweep() bool {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	changed := false
+	for _, ep := range od.endpoints {
+		if ep.ejected {
+			continue
+		}
+		if od.config.FailureRate > 0 && ep.requests >= od.config.MinimumRequests &&
+			float64(ep.failures) >= od.config.FailureRate*float64(ep.requests) && od.eject(ep, reasonFailureRate) {
+			changed = true
+			continue
+		}
+		if ep.ejections > 0 && !ep.probing {
+			ep.ejections--
+		}
+		ep.requests, ep.failures = 0, 0
+	}
+	return changed
+}
+
+func (od *outlierDetector) close() {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	od.ticker.Stop()
+	close(od.done)
+	for _, ep := range od.endpoints {
+		if ep.timer != nil {
+			ep.timer.Stop()
+		}
+		if ep.ejected {
+			metric.ClientEjectedGauge.Set(0, od.name, ep.addr.ServerName, ep.addr.Addr)
+		}
+	}
+}
+
+// outlierPicker records results of requests picked by the picker
+type outlierPicker struct {
+	balancer.Picker
+	detector *outlierDetector
+}
+
+// Pick ...
+func (p *outlierPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	res, err := p.Picker.Pick(info)
+	if err != nil {
+		return res, err
+	}
+
+	sc, done := res.SubConn, res.Done
+	res.Done = func(di balancer.DoneInfo) {
+		p.detector.record(sc, di.Err)
+		if done != nil {
+			done(di)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package balancer
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+)
+
+func TestOutlierDetection_Init(t *testing.T) {
+	od := &OutlierDetection{}
+	assert.Nil(t, od.init())
+	assert.Equal(t, 5, od.ConsecutiveFailures)
+	assert.Equal(t, 50, od.MaxEjectionPercent)
+	assert.Equal(t, 30*time.Second, od.baseEjectionTime)
+	assert.Equal(t, 300*time.Second, od.maxEjectionTime)
+
+	for _, od := range []*OutlierDetection{
+		{FailureRate: 2},
+		{MaxEjectionPercent: 101},
+		{Interval: "10"},
+		{BaseEjectionTime: "-1s"},
+	} {
+		assert.NotNil(t, od.init(), od)
+	}
+}
+
+func newTestDetector(t *testing.T, config *OutlierDetection, n int) (*outlierDetector, []balancer.SubConn, *int32) {
+	assert.Nil(t, config.init())
+	var changes int32
+	od := newOutlierDetector("test", config, func() { atomic.AddInt32(&changes, 1) })
+	t.Cleanup(od.close)
+
+	scs := make([]balancer.SubConn, n)
+	addrs := make(map[balancer.SubConn]resolver.Address)
+	for i := range scs {
+		scs[i] = &fakeSubConn{addr: string(rune('a' + i))}
+		addrs[scs[i]] = resolver.Address{Addr: scs[i].(*fakeSubConn).addr, ServerName: "outlier.test"}
+	}
+	od.update(addrs)
+	return od, scs, &changes
+}
+
+func TestOutlierDetector(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	od, scs, changes := newTestDetector(t, &OutlierDetection{
+		ConsecutiveFailures: 3,
+		BaseEjectionTime:    "50ms",
+		MaxEjectionTime:     "80ms",
+	}, 4)
+
+	// business errors and successes break consecutive failures
+	for _, err := range []error{unavailable, unavailable, status.Error(codes.InvalidArgument, "invalid"), unavailable, unavailable, nil, unavailable} {
+		od.record(scs[0], err)
+	}
+	assert.False(t, od.ejected(scs[0]))
+
+	od.record(scs[0], unavailable)
+	od.record(scs[0], unavailable)
+	assert.True(t, od.ejected(scs[0]))
+	assert.Equal(t, int32(1), atomic.LoadInt32(changes))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metric.ClientEjectedGauge.WithLabelValues("test", "outlier.test", "a")))
+
+	// at most half of the endpoints are ejected
+	for i := 0; i < 3; i++ {
+		od.record(scs[1], unavailable)
+		od.record(scs[2], unavailable)
+	}
+	assert.True(t, od.ejected(scs[1]))
+	assert.False(t, od.ejected(scs[2]))
+
+	// readmitted for probing, and ejected again for the failed probe
+	assert.Eventually(t, func() bool { return !od.ejected(scs[0]) }, time.Second, 5*time.Millisecond)
+	od.record(scs[0], unavailable)
+	assert.True(t, od.ejected(scs[0]))
+	od.mu.Lock()
+	assert.Equal(t, 2, od.endpoints[scs[0]].ejections)
+	od.mu.Unlock()
+
+	// recovered by the successful probe
+	assert.Eventually(t, func() bool { return !od.ejected(scs[0]) }, time.Second, 5*time.Millisecond)
+	od.record(scs[0], nil)
+	od.record(scs[0], unavailable)
+	assert.False(t, od.ejected(scs[0]))
+
+	// removed endpoints are forgotten
+	od.update(map[balancer.SubConn]resolver.Address{scs[0]: {Addr: "a"}})
+	assert.Len(t, od.endpoints, 1)
+	assert.Equal(t, float64(0), testutil.ToFloat64(metric.ClientEjectedGauge.WithLabelValues("test", "outlier.test", "b")))
+}
+
+func TestOutlierDetector_FailureRate(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	od, scs, _ := newTestDetector(t, &OutlierDetection{
+		FailureRate:     0.5,
+		MinimumRequests: 4,
+		Interval:        "1h",
+	}, 3)
+
+	for _, err := range []error{unavailable, nil, unavailable, nil} {
+		od.record(scs[0], err)
+	}
+	for _, err := range []error{unavailable, nil, unavailable} {
+		od.record(scs[1], err)
+	}
+	assert.True(t, od.sweep())
+	assert.True(t, od.ejected(scs[0]))
+	// not enough requests
+	assert.False(t, od.ejected(scs[1]))
+
+	// counters are reset every interval
+	od.record(scs[1], unavailable)
+	assert.False(t, od.sweep())
+	assert.False(t, od.ejected(scs[1]))
+}
+
+type testServer struct {
+	testpb.UnimplementedTestServiceServer
+	err error
+}
+
+func (s *testServer) EmptyCall(context.Context, *testpb.Empty) (*testpb.Empty, error) {
+	return &testpb.Empty{}, s.err
+}
+
+func TestOutlierDetection_Balancer(t *testing.T) {
+	var addrs []resolver.Address
+	for _, err := range []error{nil, nil, status.Error(codes.Unavailable, "unavailable")} {
+		l, err2 := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err2)
+		s := grpc.NewServer()
+		testpb.RegisterTestServiceServer(s, &testServer{err: err})
+		go func() { _ = s.Serve(l) }()
+		defer s.Stop()
+		addrs = append(addrs, resolver.Address{Addr: l.Addr().String()})
+	}
+	bad := addrs[2].Addr
+
+	r := manual.NewBuilderWithScheme("outlier")
+	r.InitialState(resolver.State{Addresses: addrs})
+	cc, err := grpc.Dial(r.Scheme()+":///test.server",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithResolvers(r),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"swr": {"outlierDetection": {"consecutiveFailures": 2}}}]}`),
+	)
+	assert.Nil(t, err)
+	defer cc.Close()
+	client := testpb.NewTestServiceClient(cc)
+
+	// the bad endpoint is ejected after 2 failures
+	failures := 0
+	for i := 0; i < 100; i++ {
+		var p peer.Peer
+		if _, err := client.EmptyCall(context.Background(), &testpb.Empty{}, grpc.Peer(&p), grpc.WaitForReady(true)); err != nil {
+			failures++
+			assert.Equal(t, bad, p.Addr.String())
+		}
+	}
+	assert.Equal(t, 2, failures)
+}
@@ -23,18 +23,21 @@ import (
 	_ "google.golang.org/grpc/health"
 )
 
-// Name is the name of p2c with least loaded balancer.
 const (
-	Name = "p2c_least_loaded"
+	// Name is the name of p2c with least loaded balancer.
+	Name = "p2c"
+	// NameLeastLoaded is the former name of p2c balancer, kept for compatibility
+	NameLeastLoaded = "p2c_least_loaded"
 )
 
 // newBuilder creates a new balance builder.
-func newBuilder() balancer.Builder {
-	return base.NewBalancerBuilder(Name, &p2cPickerBuilder{}, base.Config{HealthCheck: true})
+func newBuilder(name string) balancer.Builder {
+	return base.NewBalancerBuilder(name, &p2cPickerBuilder{}, base.Config{HealthCheck: true})
 }
 
 func init() {
-	balancer.Register(newBuilder())
+	balancer.Register(newBuilder(Name))
+	balancer.Register(newBuilder(NameLeastLoaded))
 }
 
 type p2cPickerBuilder struct{}
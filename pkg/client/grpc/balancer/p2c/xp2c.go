@@ -0,0 +1,127 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package p2c
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	xbalancer "github.com/douyu/jupiter/pkg/client/grpc/balancer"
+	"github.com/douyu/jupiter/pkg/util/xp2c/ewma"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// XName is the name of p2c balancer scored by peak EWMA latency times
+// in-flight requests.
+const XName = "xp2c"
+
+func init() {
+	balancer.Register(xp2cBuilder{})
+}
+
+// Config xp2c balancer config, set by balancerConfig of grpc client
+type Config struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+
+	// Decay 延迟衰减的时间常数，默认10s
+	Decay string `json:"decay"`
+	// Penalty 新加入节点的初始延迟，以及节点出错时记录的延迟，默认250ms
+	Penalty string `json:"penalty"`
+	// CoolDown 节点加入或出错后的冷却时间，默认5s
+	CoolDown string `json:"coolDown"`
+
+	ewma *ewma.Config
+}
+
+// xp2cBuilder builds a picker builder for each balancer, so statistics
+// of nodes are kept across pickers of the same ClientConn.
+type xp2cBuilder struct{}
+
+// Name ...
+func (xp2cBuilder) Name() string {
+	return XName
+}
+
+// Build ...
+func (xp2cBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	return newXP2CBuilder().Build(cc, opts)
+}
+
+// ParseConfig ...
+func (xp2cBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	return newXP2CBuilder().(balancer.ConfigParser).ParseConfig(js)
+}
+
+func newXP2CBuilder() balancer.Builder {
+	return xbalancer.NewBalancerBuilderV2(XName, &xp2cPickerBuilder{}, base.Config{HealthCheck: true})
+}
+
+type xp2cPickerBuilder struct {
+	p2c *ewma.EWMA
+}
+
+// ParseConfig ...
+func (*xp2cPickerBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	config := &Config{ewma: ewma.DefaultConfig()}
+	if err := json.Unmarshal(js, config); err != nil {
+		return nil, fmt.Errorf("xp2c: unmarshal config: %w", err)
+	}
+
+	for _, field := range []struct {
+		value string
+		to    *time.Duration
+	}{
+		{config.Decay, &config.ewma.Decay},
+		{config.Penalty, &config.ewma.Penalty},
+		{config.CoolDown, &config.ewma.CoolDown},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("xp2c: invalid duration %q", field.value)
+		}
+		*field.to = d
+	}
+	return config, nil
+}
+
+// Build ...
+func (b *xp2cPickerBuilder) Build(info xbalancer.PickerBuildInfo) balancer.Picker {
+	if b.p2c == nil {
+		// config is fixed once the balancer picks
+		var config *ewma.Config
+		if c, ok := info.Config.(*Config); ok {
+			config = c.ewma
+		}
+		b.p2c = ewma.New(config)
+	}
+
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+
+	items := make([]interface{}, 0, len(info.ReadySCs))
+	for sc := range info.ReadySCs {
+		items = append(items, sc)
+	}
+	b.p2c.Update(items)
+
+	return &p2cPicker{p2c: b.p2c}
+}
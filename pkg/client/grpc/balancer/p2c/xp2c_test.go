@@ -0,0 +1,87 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package p2c_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/douyu/jupiter/pkg/client/grpc/balancer/p2c"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+func TestXP2C(t *testing.T) {
+	r := manual.NewBuilderWithScheme("grpc")
+
+	backendCount := 3
+	test, err := startTestServers(backendCount)
+	if err != nil {
+		t.Fatalf("failed to start servers: %v", err)
+	}
+	defer test.cleanup()
+
+	// invalid config
+	svcCfg := fmt.Sprintf(`{"loadBalancingConfig":[{"%s":{"decay":"10"}}]}`, p2c.XName)
+	_, err = grpc.Dial(r.Scheme()+":///test.server", grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithResolvers(r), grpc.WithDefaultServiceConfig(svcCfg))
+	assert.NotNil(t, err)
+
+	svcCfg = fmt.Sprintf(`{"loadBalancingConfig":[{"%s":{"decay":"1s","coolDown":"0s"}}]}`, p2c.XName)
+	cc, err := grpc.Dial(r.Scheme()+":///test.server", grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithResolvers(r), grpc.WithDefaultServiceConfig(svcCfg))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer cc.Close()
+	testc := testpb.NewTestServiceClient(cc)
+
+	var resolvedAddrs []resolver.Address
+	for i := 0; i < backendCount; i++ {
+		resolvedAddrs = append(resolvedAddrs, resolver.Address{Addr: test.addresses[i]})
+	}
+	r.UpdateState(resolver.State{Addresses: resolvedAddrs})
+
+	var p peer.Peer
+	countMap := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		if _, err := testc.EmptyCall(context.Background(), &testpb.Empty{}, grpc.Peer(&p)); err != nil {
+			t.Fatalf("EmptyCall() = _, %v, want _, <nil>", err)
+		}
+		countMap[p.Addr.String()]++
+	}
+	assert.Equal(t, backendCount, len(countMap), countMap)
+
+	// removed address is not picked any more
+	r.UpdateState(resolver.State{Addresses: resolvedAddrs[:1]})
+	for i := 0; i < 1000; i++ {
+		if _, err := testc.EmptyCall(context.Background(), &testpb.Empty{}, grpc.Peer(&p)); err != nil {
+			t.Fatalf("EmptyCall() = _, %v, want _, <nil>", err)
+		}
+		if p.Addr.String() == test.addresses[0] && i > 10 {
+			break
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := testc.EmptyCall(context.Background(), &testpb.Empty{}, grpc.Peer(&p)); err != nil {
+			t.Fatalf("EmptyCall() = _, %v, want _, <nil>", err)
+		}
+		assert.Equal(t, test.addresses[0], p.Addr.String())
+	}
+}
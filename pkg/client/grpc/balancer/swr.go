@@ -16,7 +16,8 @@ package balancer
 
 import (
 	"errors"
-	"fmt"
+	"math"
+	"sort"
 	"sync"
 
 	"github.com/douyu/jupiter/pkg/core/constant"
@@ -26,11 +27,16 @@ import (
 	"google.golang.org/grpc/attributes"
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/serviceconfig"
 )
 
 const (
 	// NameSmoothWeightRoundRobin ...
 	NameSmoothWeightRoundRobin = "swr"
+
+	// defaultWeight weight of nodes registered without weight, same as
+	// the default weight of server.ServiceInfo
+	defaultWeight = 100
 )
 
 // PickerBuildInfo ...
@@ -38,6 +44,11 @@ type PickerBuildInfo struct {
 	// ReadySCs is a map from all ready SubConns to the Addresses used to
 	// create them.
 	ReadySCs map[balancer.SubConn]base.SubConnInfo
+	// SubConns is a map from all SubConns to the Addresses, ready or not
+	SubConns map[balancer.SubConn]base.SubConnInfo
+	// Config loadBalancingConfig parsed by the picker builder, nil if the
+	// picker builder doesn't implement balancer.ConfigParser
+	Config serviceconfig.LoadBalancingConfig
 	*attributes.Attributes
 }
 
@@ -98,72 +109,66 @@ func (p *swrPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
 }
 
 func (p *swrPicker) parseBuildInfo(info PickerBuildInfo) {
-	var hostedSubConns = map[string]balancer.SubConn{}
-	var groupedSubConns = map[string][]balancer.SubConn{}
-
-	for subConn, info := range info.ReadySCs {
-		p.buckets.Add(subConn, 1)
-		if info.Address.Attributes != nil {
-			if serviceInfo, ok := info.Address.Attributes.Value(constant.KeyServiceInfo).(server.ServiceInfo); ok {
-				// todo(gorexlv): 分组
-				group := serviceInfo.Group
-				if _, ok := groupedSubConns[group]; !ok {
-					groupedSubConns[group] = make([]balancer.SubConn, 0)
-				}
-				groupedSubConns[group] = append(groupedSubConns[group], subConn)
-			}
-		}
-		host := info.Address.Addr
-		hostedSubConns[host] = subConn
-		p.buckets.Add(subConn, 1)
-	}
-
-	if info.Attributes == nil {
-		return
-	}
+	// 服务提供方配置，以节点地址为key
+	providerConfigs, _ := info.Attributes.Value(constant.KeyProviderConfig).(map[string]registry.ProviderConfig)
+	// 路由配置
+	routeConfigs, _ := info.Attributes.Value(constant.KeyRouteConfig).(map[string]registry.RouteConfig)
 
-	providerConfig, ok := info.Attributes.Value(constant.KeyProviderConfig).(registry.ProviderConfig)
-	if !ok {
-		return
+	type node struct {
+		subConn balancer.SubConn
+		addr    string
+		info    server.ServiceInfo
 	}
+	var nodes = make([]node, 0, len(info.ReadySCs))
 
-	fmt.Printf("providerConfig => %v\n", providerConfig)
+	for subConn, sci := range info.ReadySCs {
+		serviceInfo, _ := sci.Address.Attributes.Value(constant.KeyServiceInfo).(server.ServiceInfo)
+		// 通过ProviderConfig禁用节点
+		if config, ok := providerConfigs[sci.Address.Addr]; ok && !config.Enable {
+			continue
+		}
 
-	consumerConfigs, ok := info.Attributes.Value(constant.KeyConsumerConfig).(map[string]registry.ConsumerConfig)
-	if !ok {
-		return
+		p.buckets.Add(subConn, nodeWeight(serviceInfo))
+		nodes = append(nodes, node{subConn: subConn, addr: sci.Address.Addr, info: serviceInfo})
 	}
 
-	fmt.Printf("consumerConfigs => %v\n", consumerConfigs)
-
-	// 路由配置
-	routeConfigs, ok := info.Attributes.Value(constant.KeyRouteConfig).(map[string]registry.RouteConfig)
-	if !ok {
-		return
+	ids := make([]string, 0, len(routeConfigs))
+	for id := range routeConfigs {
+		ids = append(ids, id)
 	}
-	for _, config := range routeConfigs {
-		if _, ok := p.routeBuckets[config.URI]; !ok {
-			p.routeBuckets[config.URI] = &weighted.SW{}
-		}
-
-		// 基于Group的权重配置, 同一分组下的IP分配同一个权重值
-		for group, weight := range config.Upstream.Groups {
-			sConns, ok := groupedSubConns[group]
-			if !ok {
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		config := routeConfigs[id]
+		buckets := &weighted.SW{}
+		for _, n := range nodes {
+			// 路由部署组, 将流量导入部署组
+			if config.Deployment != "" && n.info.Deployment != config.Deployment {
 				continue
 			}
 
-			for _, sConn := range sConns {
-				p.routeBuckets[config.URI].Add(sConn, weight)
+			// 基于Node IP的权重配置, 如果配置了对应Node，将会覆盖Group中配置的权重
+			weight, ok := config.Upstream.Nodes[n.addr]
+			if !ok {
+				// 基于Group的权重配置, 同一分组下的IP分配同一个权重值
+				weight, ok = config.Upstream.Groups[n.info.Group]
+			}
+			if ok && weight > 0 {
+				buckets.Add(n.subConn, weight)
 			}
 		}
 
-		// 基于Node IP的权重配置, 如果配置了对应Node，将会覆盖Group中配置的权重
-		for node, weight := range config.Upstream.Nodes {
-			if sConn, ok := hostedSubConns[node]; ok {
-				p.routeBuckets[config.URI].Add(sConn, weight)
-			}
+		// 没有匹配的节点时，使用默认权重
+		if len(buckets.All()) > 0 {
+			p.routeBuckets[config.URI] = buckets
 		}
+	}
+}
 
+// nodeWeight returns the weight of node, defaultWeight if not set
+func nodeWeight(info server.ServiceInfo) int {
+	if weight := int(math.Round(info.Weight)); weight > 0 {
+		return weight
 	}
+	return defaultWeight
 }
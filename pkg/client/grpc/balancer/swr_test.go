@@ -0,0 +1,82 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package balancer
+
+import (
+	"testing"
+
+	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/registry"
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/resolver"
+)
+
+type fakeSubConn struct {
+	balancer.SubConn
+	addr string
+}
+
+func TestSWRPicker(t *testing.T) {
+	info := PickerBuildInfo{
+		ReadySCs: make(map[balancer.SubConn]base.SubConnInfo),
+		Attributes: attributes.
+			New(constant.KeyRouteConfig, map[string]registry.RouteConfig{
+				"1": {URI: "/pkg.Service/Green", Upstream: registry.Upstream{Groups: map[string]int{"green": 1}}},
+				"2": {URI: "/pkg.Service/Nodes", Upstream: registry.Upstream{
+					Groups: map[string]int{"blue": 1, "green": 1},
+					Nodes:  map[string]int{"b:1": 3},
+				}},
+				"3": {URI: "/pkg.Service/Gray", Deployment: "gray", Upstream: registry.Upstream{Groups: map[string]int{"blue": 1}}},
+			}).
+			WithValue(constant.KeyProviderConfig, map[string]registry.ProviderConfig{
+				"a:1": {Enable: true},
+				"c:1": {Enable: false},
+			}),
+	}
+	for _, node := range []server.ServiceInfo{
+		{Address: "a:1", Weight: 300, Group: "blue"},
+		{Address: "b:1", Group: "green"},
+		{Address: "c:1", Weight: 100, Group: "green"},
+	} {
+		info.ReadySCs[&fakeSubConn{addr: node.Address}] = base.SubConnInfo{Address: resolver.Address{
+			Addr:       node.Address,
+			Attributes: attributes.New(constant.KeyServiceInfo, node),
+		}}
+	}
+
+	picker := swrPickerBuilder{}.Build(info)
+	picks := func(method string) map[string]int {
+		counts := make(map[string]int)
+		for i := 0; i < 40; i++ {
+			res, err := picker.Pick(balancer.PickInfo{FullMethodName: method})
+			assert.Nil(t, err)
+			counts[res.SubConn.(*fakeSubConn).addr]++
+		}
+		return counts
+	}
+
+	// node weights, c is disabled
+	assert.Equal(t, map[string]int{"a:1": 30, "b:1": 10}, picks("/pkg.Service/Default"))
+	// group weights
+	assert.Equal(t, map[string]int{"b:1": 40}, picks("/pkg.Service/Green"))
+	// node weights override group weights
+	assert.Equal(t, map[string]int{"a:1": 10, "b:1": 30}, picks("/pkg.Service/Nodes"))
+	// no node of the deployment
+	assert.Equal(t, map[string]int{"a:1": 30, "b:1": 10}, picks("/pkg.Service/Gray"))
+}
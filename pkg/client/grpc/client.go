@@ -16,14 +16,21 @@ package grpc
 
 import (
 	"context"
-	"fmt"
 	"time"
 
+	_ "github.com/douyu/jupiter/pkg/client/grpc/balancer/consistenthash" // register balancers by name
+	_ "github.com/douyu/jupiter/pkg/client/grpc/balancer/locality"
+	_ "github.com/douyu/jupiter/pkg/client/grpc/balancer/p2c"
 	"github.com/douyu/jupiter/pkg/client/grpc/resolver"
 	"github.com/douyu/jupiter/pkg/core/ecode"
+	"github.com/douyu/jupiter/pkg/core/inspect"
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/douyu/jupiter/pkg/util/xtls"
 	"github.com/douyu/jupiter/pkg/xlog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	grpcresolver "google.golang.org/grpc/resolver"
 )
 
 type ClientConn = grpc.ClientConn
@@ -31,7 +38,17 @@ type ClientConn = grpc.ClientConn
 func newGRPCClient(config *Config) (*grpc.ClientConn, error) {
 	var ctx = context.Background()
 
-	dialOptions := getDialOptions(config)
+	var tracker *subConnTracker
+	if config.EnableSubConnStats {
+		tracker = newSubConnTracker()
+		defer tracker.release()
+	}
+
+	dialOptions, err := getDialOptions(config, tracker)
+	if err != nil {
+		config.logger.Error("build dial options failed", xlog.FieldErrKind(ecode.ErrKindRequestErr), xlog.FieldErr(err))
+		return nil, err
+	}
 
 	// 默认使用block连接，失败后fallback到异步连接
 	if config.DialTimeout > time.Duration(0) {
@@ -55,26 +72,48 @@ func newGRPCClient(config *Config) (*grpc.ClientConn, error) {
 	}
 
 	config.logger.Info("start grpc client")
+	inspect.Register(metric.TypeGRPC, config.Name, config, clientStats(conn, tracker))
 
 	return conn, nil
 }
 
-func getDialOptions(config *Config) []grpc.DialOption {
+func getDialOptions(config *Config, tracker *subConnTracker) ([]grpc.DialOption, error) {
 	dialOptions := config.dialOptions
 
 	if config.KeepAlive != nil {
 		dialOptions = append(dialOptions, grpc.WithKeepaliveParams(*config.KeepAlive))
 	}
 
+	creds := insecure.NewCredentials()
+	if config.EnableTLS {
+		reloader, err := xtls.NewReloader(config.CertFile, config.PrivateFile, config.CaFile)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(reloader.ClientConfig(config.ServerName, config.InsecureSkipVerify))
+	}
+
 	dialOptions = append(dialOptions,
-		grpc.WithInsecure(),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithResolvers(resolver.NewEtcdBuilder("etcd", config.RegistryConfig)),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithResolvers(resolverBuilders(config)...),
 		grpc.WithDisableServiceConfig(),
+		grpc.WithDefaultServiceConfig(config.methods.serviceConfig(tracker.balancer(config.BalancerName, config.BalancerConfig))),
+		grpc.WithStatsHandler(attemptsStatsHandler{}),
 	)
 
-	svcCfg := fmt.Sprintf(`{"loadBalancingPolicy":"%s"}`, config.BalancerName)
-	dialOptions = append(dialOptions, grpc.WithDefaultServiceConfig(svcCfg))
+	return dialOptions, nil
+}
+
+// resolverBuilders returns resolver builders of registries by scheme
+func resolverBuilders(config *Config) []grpcresolver.Builder {
+	registries := map[string]string{"etcd": config.RegistryConfig}
+	for scheme, key := range config.Registries {
+		registries[scheme] = key
+	}
 
-	return dialOptions
+	builders := make([]grpcresolver.Builder, 0, len(registries))
+	for scheme, key := range registries {
+		builders = append(builders, resolver.NewBuilder(scheme, key))
+	}
+	return builders
 }
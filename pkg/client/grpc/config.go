@@ -39,6 +39,12 @@ type Config struct {
 	ReadTimeout    time.Duration
 	KeepAlive      *keepalive.ClientParameters
 	RegistryConfig string
+	// BalancerConfig 负载均衡器配置，配置项见各负载均衡器的 Config
+	BalancerConfig map[string]interface{}
+	// Registries 其他注册中心，scheme => 注册中心配置键，
+	// 如 consul = "jupiter.registry.consul"，则以 consul:///name 访问，
+	// RegistryConfig 对应 etcd scheme
+	Registries map[string]string
 
 	logger      *xlog.Logger
 	dialOptions []grpc.DialOption
@@ -53,6 +59,26 @@ type Config struct {
 	DisableMetricInterceptor   bool
 	DisableAccessInterceptor   bool
 	AccessInterceptorLevel     string
+
+	// EnableTLS dial with TLS, certificates are reloaded when files change
+	EnableTLS bool
+	// CaFile CA to verify the server, system roots if empty
+	CaFile string
+	// CertFile client certificate for mTLS, optional
+	CertFile string
+	// PrivateFile private key of CertFile
+	PrivateFile string
+	// ServerName overrides the server name to verify
+	ServerName string
+	// InsecureSkipVerify skips verifying the server, for development only
+	InsecureSkipVerify bool
+
+	// EnableSubConnStats 在 governor /clients 中展示各子连接的状态和调用统计，默认开启
+	EnableSubConnStats bool
+
+	// Methods 方法级别的超时、重试和对冲策略
+	Methods []MethodConfig
+	methods methodConfigs
 }
 
 // DefaultConfig ...
@@ -67,7 +93,8 @@ func DefaultConfig() *Config {
 			Time:    5 * time.Minute,
 			Timeout: 20 * time.Second,
 		},
-		RegistryConfig: constant.ConfigKey("registry.default"),
+		RegistryConfig:     constant.ConfigKey("registry.default"),
+		EnableSubConnStats: true,
 	}
 }
 
@@ -105,48 +132,73 @@ func (config *Config) WithDialOption(opts ...grpc.DialOption) *Config {
 func (config *Config) Build() (*grpc.ClientConn, error) {
 	config.logger = xlog.Jupiter().Named(ecode.ModClientGrpc)
 
+	methods, err := newMethodConfigs(config.Methods)
+	if err != nil {
+		config.logger.Error("invalid method config", xlog.FieldErrKind(ecode.ErrKindUnmarshalConfigErr), xlog.FieldErr(err), xlog.FieldName(config.Name))
+		return nil, err
+	}
+	config.methods = methods
+
 	if config.Debug {
 		config.dialOptions = append(config.dialOptions,
 			grpc.WithChainUnaryInterceptor(debugUnaryClientInterceptor(config.Addr)),
+			grpc.WithChainStreamInterceptor(debugStreamClientInterceptor(config.Addr)),
 		)
 	}
 
 	if !config.DisableAidInterceptor {
 		config.dialOptions = append(config.dialOptions,
 			grpc.WithChainUnaryInterceptor(aidUnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(aidStreamClientInterceptor()),
 		)
 	}
 
 	if !config.DisableTimeoutInterceptor {
 		config.dialOptions = append(config.dialOptions,
-			grpc.WithChainUnaryInterceptor(timeoutUnaryClientInterceptor(config.logger, config.ReadTimeout, config.SlowThreshold)),
+			grpc.WithChainUnaryInterceptor(timeoutUnaryClientInterceptor(config.logger, config.methods, config.ReadTimeout, config.SlowThreshold)),
+			grpc.WithChainStreamInterceptor(timeoutStreamClientInterceptor(config.logger, config.SlowThreshold)),
 		)
 	}
 
 	if !config.DisableTraceInterceptor {
 		config.dialOptions = append(config.dialOptions,
 			grpc.WithChainUnaryInterceptor(TraceUnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(TraceStreamClientInterceptor()),
 		)
 	}
 
 	if !config.DisableAccessInterceptor {
 		config.dialOptions = append(config.dialOptions,
 			grpc.WithChainUnaryInterceptor(loggerUnaryClientInterceptor(config.logger, config.Name, config.AccessInterceptorLevel)),
+			grpc.WithChainStreamInterceptor(loggerStreamClientInterceptor(config.logger, config.Name, config.AccessInterceptorLevel)),
 		)
 	}
 
 	if !config.DisableMetricInterceptor {
 		config.dialOptions = append(config.dialOptions,
-			grpc.WithChainUnaryInterceptor(metricUnaryClientInterceptor(config.Name)),
+			grpc.WithChainUnaryInterceptor(metricUnaryClientInterceptor(config.Name, config.methods)),
+			grpc.WithChainStreamInterceptor(metricStreamClientInterceptor(config.Name, config.methods)),
 		)
 	}
 
 	if !config.DisableSentinelInterceptor {
 		config.dialOptions = append(config.dialOptions,
 			grpc.WithChainUnaryInterceptor(sentinelUnaryClientInterceptor(config.Addr)),
+			grpc.WithChainStreamInterceptor(sentinelStreamClientInterceptor(config.Addr)),
 		)
 	}
 
+	// 还原服务端的错误码，其他拦截器看到的是原始错误码
+	config.dialOptions = append(config.dialOptions,
+		grpc.WithChainUnaryInterceptor(statusUnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(statusStreamClientInterceptor()),
+	)
+
+	// 对冲请求放在最内层，其他拦截器只看到一次调用
+	config.dialOptions = append(config.dialOptions,
+		grpc.WithChainUnaryInterceptor(hedgingUnaryClientInterceptor(config.methods)),
+	)
+
 	return newGRPCClient(config)
 }
 
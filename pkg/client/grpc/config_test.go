@@ -16,11 +16,14 @@ package grpc
 
 import (
 	"bytes"
+	"context"
 	"testing"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/douyu/jupiter/pkg/client/grpc/balancer/locality"
 	"github.com/douyu/jupiter/pkg/conf"
+	helloworldv1 "github.com/douyu/jupiter/proto/helloworld/v1"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,6 +33,17 @@ func TestConfig(t *testing.T) {
 	balancerName="swr"
 	addr="127.0.0.1:9091"
 	dialTimeout="10s"
+[[jupiter.grpc.test.methods]]
+	name="/helloworld.Greeter/*"
+	timeout="2s"
+	[jupiter.grpc.test.methods.retry]
+		maxAttempts=3
+		retryableStatusCodes=["UNAVAILABLE"]
+[[jupiter.grpc.test.methods]]
+	name="/helloworld.Greeter/SayHello"
+	[jupiter.grpc.test.methods.hedging]
+		maxAttempts=2
+		hedgingDelay="50ms"
 	`
 	assert.Nil(t, conf.LoadFromReader(bytes.NewBufferString(configStr), toml.Unmarshal))
 
@@ -38,5 +52,40 @@ func TestConfig(t *testing.T) {
 		assert.Equal(t, "swr", config.BalancerName)
 		assert.Equal(t, time.Second*10, config.DialTimeout)
 		assert.Equal(t, "127.0.0.1:9091", config.Addr)
+		assert.Len(t, config.Methods, 2)
+		assert.Equal(t, time.Second*2, config.Methods[0].Timeout)
+		assert.Equal(t, 3, config.Methods[0].Retry.MaxAttempts)
+		assert.Equal(t, time.Millisecond*50, config.Methods[1].Hedging.HedgingDelay)
 	})
 }
+
+func TestConfig_TLS(t *testing.T) {
+	config := DefaultConfig()
+	config.Addr = "127.0.0.1:9091"
+	config.EnableTLS = true
+	config.CaFile = "not-exists.pem"
+
+	_, err := config.Build()
+	assert.NotNil(t, err)
+}
+
+func TestConfig_BalancerConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.Addr = "127.0.0.1:9528"
+	config.BalancerName = locality.Name
+	config.BalancerConfig = map[string]interface{}{"zone": "z1", "spilloverThreshold": 0.5}
+
+	cc, err := config.Build()
+	assert.Nil(t, err)
+	defer cc.Close()
+
+	_, err = helloworldv1.NewGreeterServiceClient(cc).SayHello(context.Background(), &helloworldv1.SayHelloRequest{})
+	assert.Nil(t, err)
+
+	config = DefaultConfig()
+	config.Addr = "127.0.0.1:9528"
+	config.BalancerName = locality.Name
+	config.BalancerConfig = map[string]interface{}{"spilloverThreshold": 2}
+	_, err = config.Build()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,362 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/douyu/jupiter/pkg/core/inspect"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/connectivity"
+	grpcresolver "google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// inspectBalancerName wraps the configured balancer to keep track of the
+// subconns it creates and the calls picked on them.
+const inspectBalancerName = "jupiter_inspect"
+
+func init() {
+	balancer.Register(inspectBuilder{})
+}
+
+// SubConnStats ...
+type SubConnStats struct {
+	Addr           string `json:"addr"`
+	State          string `json:"state"`
+	CallsStarted   int64  `json:"callsStarted"`
+	CallsSucceeded int64  `json:"callsSucceeded"`
+	CallsFailed    int64  `json:"callsFailed"`
+}
+
+// clientStats reports the state of cc, and of its subconns if tracker
+// isn't nil.
+func clientStats(cc *grpc.ClientConn, tracker *subConnTracker) func() inspect.Stats {
+	return func() inspect.Stats {
+		stats := inspect.Stats{
+			"target": cc.Target(),
+			"state":  cc.GetState().String(),
+		}
+		if tracker == nil {
+			return stats
+		}
+
+		subConns := tracker.list()
+		ready := 0
+		for _, sc := range subConns {
+			if sc.State == connectivity.Ready.String() {
+				ready++
+			}
+		}
+
+		stats["subconns"] = subConns
+		stats["subconns_total"] = len(subConns)
+		stats["subconns_ready"] = ready
+		return stats
+	}
+}
+
+type subConn struct {
+	addr      string
+	state     atomic.Int32 // connectivity.State
+	started   atomic.Int64
+	succeeded atomic.Int64
+	failed    atomic.Int64
+}
+
+var (
+	trackerID atomic.Uint64
+	// trackers holds the trackers of clients being dialed, so that the
+	// service config can refer to them by id.
+	trackers sync.Map
+)
+
+// subConnTracker keeps track of the live subconns of a single ClientConn.
+// It's fed by the inspect balancer, which wraps the configured one.
+type subConnTracker struct {
+	id       uint64
+	mu       sync.RWMutex
+	subConns map[balancer.SubConn]*subConn
+}
+
+func newSubConnTracker() *subConnTracker {
+	return &subConnTracker{
+		id:       trackerID.Add(1),
+		subConns: make(map[balancer.SubConn]*subConn),
+	}
+}
+
+// balancer returns the balancer name and config which wraps the given
+// balancer in the inspect balancer. The tracker must stay registered while
+// the service config is parsed, i.e. until the client is dialed.
+func (t *subConnTracker) balancer(name string, config map[string]interface{}) (string, map[string]interface{}) {
+	if t == nil {
+		return name, config
+	}
+
+	trackers.Store(t.id, t)
+	return inspectBalancerName, map[string]interface{}{
+		"tracker":     t.id,
+		"childPolicy": name,
+		"childConfig": config,
+	}
+}
+
+func (t *subConnTracker) release() {
+	if t != nil {
+		trackers.Delete(t.id)
+	}
+}
+
+func (t *subConnTracker) add(conn balancer.SubConn, sc *subConn) {
+	t.mu.Lock()
+	t.subConns[conn] = sc
+	t.mu.Unlock()
+}
+
+func (t *subConnTracker) remove(conn balancer.SubConn) {
+	t.mu.Lock()
+	delete(t.subConns, conn)
+	t.mu.Unlock()
+}
+
+func (t *subConnTracker) get(conn balancer.SubConn) *subConn {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.subConns[conn]
+}
+
+func (t *subConnTracker) reset() {
+	t.mu.Lock()
+	t.subConns = make(map[balancer.SubConn]*subConn)
+	t.mu.Unlock()
+}
+
+func (t *subConnTracker) list() []SubConnStats {
+	t.mu.RLock()
+	list := make([]SubConnStats, 0, len(t.subConns))
+	for _, sc := range t.subConns {
+		list = append(list, SubConnStats{
+			Addr:           sc.addr,
+			State:          connectivity.State(sc.state.Load()).String(),
+			CallsStarted:   sc.started.Load(),
+			CallsSucceeded: sc.succeeded.Load(),
+			CallsFailed:    sc.failed.Load(),
+		})
+	}
+	t.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Addr < list[j].Addr })
+	return list
+}
+
+type inspectConfig struct {
+	serviceconfig.LoadBalancingConfig
+	tracker     *subConnTracker
+	child       balancer.Builder
+	childConfig serviceconfig.LoadBalancingConfig
+}
+
+type inspectBuilder struct{}
+
+// Name ...
+func (inspectBuilder) Name() string {
+	return inspectBalancerName
+}
+
+// Build ...
+func (inspectBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	return &inspectBalancer{cc: cc, opts: opts}
+}
+
+// ParseConfig ...
+func (inspectBuilder) ParseConfig(data json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	var raw struct {
+		Tracker     uint64          `json:"tracker"`
+		ChildPolicy string          `json:"childPolicy"`
+		ChildConfig json.RawMessage `json:"childConfig"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	tracker, ok := trackers.Load(raw.Tracker)
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown tracker %d", inspectBalancerName, raw.Tracker)
+	}
+	child := balancer.Get(raw.ChildPolicy)
+	if child == nil {
+		return nil, fmt.Errorf("%s: unknown child balancer %q", inspectBalancerName, raw.ChildPolicy)
+	}
+
+	config := &inspectConfig{tracker: tracker.(*subConnTracker), child: child}
+	if parser, ok := child.(balancer.ConfigParser); ok {
+		if len(raw.ChildConfig) == 0 || string(raw.ChildConfig) == "null" {
+			raw.ChildConfig = json.RawMessage("{}")
+		}
+		childConfig, err := parser.ParseConfig(raw.ChildConfig)
+		if err != nil {
+			return nil, err
+		}
+		config.childConfig = childConfig
+	}
+	return config, nil
+}
+
+// inspectBalancer builds the child balancer on the first config update, as
+// that's when the child and the tracker are known.
+type inspectBalancer struct {
+	cc      balancer.ClientConn
+	opts    balancer.BuildOptions
+	child   balancer.Balancer
+	tracker *subConnTracker
+}
+
+// UpdateClientConnState ...
+func (b *inspectBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	config, ok := s.BalancerConfig.(*inspectConfig)
+	if !ok {
+		return fmt.Errorf("%s: unexpected balancer config %T", inspectBalancerName, s.BalancerConfig)
+	}
+
+	if b.child == nil {
+		b.tracker = config.tracker
+		b.child = config.child.Build(&inspectClientConn{ClientConn: b.cc, balancer: b}, b.opts)
+	}
+	s.BalancerConfig = config.childConfig
+	return b.child.UpdateClientConnState(s)
+}
+
+// ResolverError ...
+func (b *inspectBalancer) ResolverError(err error) {
+	if b.child == nil {
+		b.cc.UpdateState(balancer.State{
+			ConnectivityState: connectivity.TransientFailure,
+			Picker:            base.NewErrPicker(err),
+		})
+		return
+	}
+	b.child.ResolverError(err)
+}
+
+// UpdateSubConnState ...
+func (b *inspectBalancer) UpdateSubConnState(sc balancer.SubConn, s balancer.SubConnState) {
+	if b.child != nil {
+		b.child.UpdateSubConnState(sc, s)
+	}
+}
+
+// ExitIdle ...
+func (b *inspectBalancer) ExitIdle() {
+	if ei, ok := b.child.(balancer.ExitIdler); ok {
+		ei.ExitIdle()
+	}
+}
+
+// Close ...
+func (b *inspectBalancer) Close() {
+	if b.child == nil {
+		return
+	}
+	b.child.Close()
+	b.tracker.reset()
+}
+
+// inspectClientConn records the subconns created by the child balancer and
+// their state changes, and wraps its pickers to count calls.
+type inspectClientConn struct {
+	balancer.ClientConn
+	balancer *inspectBalancer
+}
+
+// NewSubConn ...
+func (cc *inspectClientConn) NewSubConn(addrs []grpcresolver.Address, opts balancer.NewSubConnOptions) (balancer.SubConn, error) {
+	var conn balancer.SubConn
+	sc := &subConn{}
+	if len(addrs) > 0 {
+		sc.addr = addrs[0].Addr
+	}
+
+	tracker := cc.balancer.tracker
+	listener := opts.StateListener
+	opts.StateListener = func(s balancer.SubConnState) {
+		sc.state.Store(int32(s.ConnectivityState))
+		if s.ConnectivityState == connectivity.Shutdown {
+			tracker.remove(conn)
+		}
+		if listener != nil {
+			listener(s)
+		} else {
+			cc.balancer.child.UpdateSubConnState(conn, s)
+		}
+	}
+
+	conn, err := cc.ClientConn.NewSubConn(addrs, opts)
+	if err != nil {
+		return nil, err
+	}
+	tracker.add(conn, sc)
+	return conn, nil
+}
+
+// UpdateState ...
+func (cc *inspectClientConn) UpdateState(s balancer.State) {
+	if s.Picker != nil {
+		s.Picker = &inspectPicker{Picker: s.Picker, tracker: cc.balancer.tracker}
+	}
+	cc.ClientConn.UpdateState(s)
+}
+
+type inspectPicker struct {
+	balancer.Picker
+	tracker *subConnTracker
+}
+
+// Pick ...
+func (p *inspectPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	res, err := p.Picker.Pick(info)
+	if err != nil {
+		return res, err
+	}
+
+	sc := p.tracker.get(res.SubConn)
+	if sc == nil {
+		return res, nil
+	}
+
+	sc.started.Add(1)
+	done := res.Done
+	res.Done = func(di balancer.DoneInfo) {
+		switch {
+		case di.Err != nil:
+			sc.failed.Add(1)
+		case !di.BytesSent:
+			// the subconn wasn't ready and the call is picked again
+			sc.started.Add(-1)
+		default:
+			sc.succeeded.Add(1)
+		}
+		if done != nil {
+			done(di)
+		}
+	}
+	return res, nil
+}
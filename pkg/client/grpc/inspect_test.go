@@ -0,0 +1,99 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/inspect"
+	"github.com/douyu/jupiter/pkg/core/metric"
+	helloworldv1 "github.com/douyu/jupiter/proto/helloworld/v1"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+func TestClientStats(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Name = "test-client-stats"
+	cfg.Addr = "etcd:///grpc:srv1:v1:unknown-mode"
+	conn := lo.Must(cfg.Build())
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := helloworldv1.NewGreeterServiceClient(conn).SayHello(ctx, &helloworldv1.SayHelloRequest{Name: "hello"})
+	assert.Nil(t, err)
+
+	var stats inspect.Stats
+	assert.Eventually(t, func() bool {
+		stats = registeredStats(cfg.Name)
+		return stats["subconns_ready"] == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "etcd:///grpc:srv1:v1:unknown-mode", stats["target"])
+	assert.Equal(t, "READY", stats["state"])
+	assert.Equal(t, 2, stats["subconns_total"])
+
+	addrs := make([]string, 0)
+	var started, succeeded int64
+	for _, sc := range stats["subconns"].([]SubConnStats) {
+		addrs = append(addrs, sc.Addr)
+		started += sc.CallsStarted
+		succeeded += sc.CallsSucceeded
+	}
+	assert.ElementsMatch(t, []string{"127.0.0.1:9528", "127.0.0.1:9529"}, addrs)
+	assert.Equal(t, int64(1), started)
+	assert.Equal(t, int64(1), succeeded)
+}
+
+func TestClientStatsDisabled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Name = "test-client-stats-disabled"
+	cfg.Addr = "etcd:///grpc:srv1:v1:unknown-mode"
+	cfg.EnableSubConnStats = false
+	conn := lo.Must(cfg.Build())
+	defer conn.Close()
+
+	stats := registeredStats(cfg.Name)
+	assert.Equal(t, "etcd:///grpc:srv1:v1:unknown-mode", stats["target"])
+	assert.NotContains(t, stats, "subconns")
+}
+
+func TestClientStatsRemovedSubConn(t *testing.T) {
+	r := manual.NewBuilderWithScheme("inspect")
+	r.InitialState(resolver.State{Addresses: []resolver.Address{
+		{Addr: "127.0.0.1:9528"},
+		{Addr: "127.0.0.1:9529"},
+	}})
+
+	cfg := DefaultConfig()
+	cfg.Name = "test-client-stats-removed"
+	cfg.Addr = "inspect:///srv1"
+	cfg.WithDialOption(grpc.WithResolvers(r))
+	conn := lo.Must(cfg.Build())
+	defer conn.Close()
+
+	assert.Eventually(t, func() bool {
+		return registeredStats(cfg.Name)["subconns_ready"] == 2
+	}, time.Second, 10*time.Millisecond)
+
+	r.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: "127.0.0.1:9529"}}})
+	assert.Eventually(t, func() bool {
+		return registeredStats(cfg.Name)["subconns_total"] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	subConns := registeredStats(cfg.Name)["subconns"].([]SubConnStats)
+	assert.Equal(t, "127.0.0.1:9529", subConns[0].Addr)
+	assert.Equal(t, "READY", subConns[0].State)
+}
+
+func registeredStats(name string) inspect.Stats {
+	for _, info := range inspect.List() {
+		if info.Type == metric.TypeGRPC && info.Name == name {
+			return info.Stats
+		}
+	}
+	return nil
+}
@@ -45,11 +45,17 @@ var (
 )
 
 // metric统计
-func metricUnaryClientInterceptor(name string) func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+func metricUnaryClientInterceptor(name string, methods methodConfigs) func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 		beg := time.Now()
+		ctx, attempts := withAttempts(ctx)
 		err := invoker(ctx, method, req, reply, cc, opts...)
 
+		// 重试和对冲的额外请求次数
+		if n := retries(attempts); n > 0 {
+			metric.ClientHandleCounter.Add(float64(n), metric.TypeGRPCUnary, name, method, cc.Target(), methods.retryCode(method))
+		}
+
 		// 收敛err错误，将err过滤后，可以知道err是否为系统错误码
 		spbStatus := ecode.ExtractCodes(err)
 		// 只记录系统级别错误
@@ -65,19 +71,6 @@ func metricUnaryClientInterceptor(name string) func(ctx context.Context, method
 	}
 }
 
-// func metricStreamClientInterceptor(name string) func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-// 	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-// 		beg := time.Now()
-// 		clientStream, err := streamer(ctx, desc, cc, method, opts...)
-
-// 		// 暂时用默认的grpc的默认err收敛
-// 		codes := ecode.ExtractCodes(err)
-// 		metric.ClientHandleCounter.Inc(metric.TypeGRPCStream, name, method, cc.Target(), codes.GetMessage())
-// 		metric.ClientHandleHistogram.Observe(time.Since(beg).Seconds(), metric.TypeGRPCStream, name, method, cc.Target())
-// 		return clientStream, err
-// 	}
-// }
-
 func sentinelUnaryClientInterceptor(name string) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply interface{},
 		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
@@ -167,14 +160,14 @@ func aidUnaryClientInterceptor() grpc.UnaryClientInterceptor {
 }
 
 // timeoutUnaryClientInterceptor gRPC客户端超时拦截器
-func timeoutUnaryClientInterceptor(_logger *xlog.Logger, timeout time.Duration, slowThreshold time.Duration) grpc.UnaryClientInterceptor {
+func timeoutUnaryClientInterceptor(_logger *xlog.Logger, methods methodConfigs, timeout time.Duration, slowThreshold time.Duration) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 		now := time.Now()
 		// 若无自定义超时设置，默认设置超时
 		_, ok := ctx.Deadline()
 		if !ok {
 			var cancel context.CancelFunc
-			ctx, cancel = context.WithTimeout(ctx, timeout)
+			ctx, cancel = context.WithTimeout(ctx, methods.timeout(method, timeout))
 			defer cancel()
 		}
 
@@ -0,0 +1,395 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxAttempts is the limit of attempts enforced by grpc
+const maxAttempts = 5
+
+// MethodConfig 方法级别的调用策略
+type MethodConfig struct {
+	// Name /package.Service/Method, /package.Service/* for all methods of
+	// the service, * for all methods. The most specific one wins.
+	Name string
+	// Timeout overrides ReadTimeout for the method
+	Timeout time.Duration
+	// Retry retries failed calls, conflicts with Hedging
+	Retry *RetryPolicy
+	// Hedging sends extra calls when the previous ones are slow, unary
+	// calls only, conflicts with Retry
+	Hedging *HedgingPolicy
+}
+
+// RetryPolicy retry policy, translated into grpc service config
+type RetryPolicy struct {
+	// MaxAttempts attempts including the original one, at most 5
+	MaxAttempts int
+	// InitialBackoff backoff before the first retry, 100ms by default
+	InitialBackoff time.Duration
+	// MaxBackoff 1s by default
+	MaxBackoff time.Duration
+	// BackoffMultiplier 2 by default
+	BackoffMultiplier float64
+	// RetryableStatusCodes e.g. ["UNAVAILABLE"], which is the default
+	RetryableStatusCodes []string
+}
+
+// HedgingPolicy hedging policy, implemented by interceptor
+type HedgingPolicy struct {
+	// MaxAttempts attempts including the original one, at most 5
+	MaxAttempts int
+	// HedgingDelay delay between two attempts, all attempts are sent at
+	// once if zero
+	HedgingDelay time.Duration
+	// NonFatalStatusCodes codes which trigger the next attempt at once
+	// instead of failing the call, e.g. ["UNAVAILABLE"]
+	NonFatalStatusCodes []string
+
+	nonFatal map[codes.Code]bool
+}
+
+// methodConfigs method configs by path, "/package.Service/Method",
+// "/package.Service/" and "" for all methods
+type methodConfigs map[string]*MethodConfig
+
+func newMethodConfigs(list []MethodConfig) (methodConfigs, error) {
+	methods := make(methodConfigs, len(list))
+	for i := range list {
+		mc := list[i]
+		path, err := methodPath(mc.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := methods[path]; ok {
+			return nil, fmt.Errorf("duplicated method config %s", mc.Name)
+		}
+		if mc.Retry != nil && mc.Hedging != nil {
+			return nil, fmt.Errorf("method config %s: retry conflicts with hedging", mc.Name)
+		}
+
+		if mc.Retry != nil {
+			retry := *mc.Retry
+			if err := retry.init(); err != nil {
+				return nil, fmt.Errorf("method config %s: %w", mc.Name, err)
+			}
+			mc.Retry = &retry
+		}
+		if mc.Hedging != nil {
+			hedging := *mc.Hedging
+			if err := hedging.init(); err != nil {
+				return nil, fmt.Errorf("method config %s: %w", mc.Name, err)
+			}
+			mc.Hedging = &hedging
+		}
+		methods[path] = &mc
+	}
+	return methods, nil
+}
+
+// lookup returns the config of method, nil if not configured
+func (methods methodConfigs) lookup(method string) *MethodConfig {
+	if mc, ok := methods[method]; ok {
+		return mc
+	}
+	if i := strings.LastIndex(method, "/"); i > 0 {
+		if mc, ok := methods[method[:i+1]]; ok {
+			return mc
+		}
+	}
+	return methods[""]
+}
+
+// timeout returns the timeout of method, def if not configured
+func (methods methodConfigs) timeout(method string, def time.Duration) time.Duration {
+	if mc := methods.lookup(method); mc != nil && mc.Timeout > 0 {
+		return mc.Timeout
+	}
+	return def
+}
+
+// retryCode returns the metric code of extra attempts of method
+func (methods methodConfigs) retryCode(method string) string {
+	if mc := methods.lookup(method); mc != nil && mc.Hedging != nil {
+		return "hedge"
+	}
+	return "retry"
+}
+
+// serviceConfig returns grpc service config json with the balancer and
+// the retry policies
+func (methods methodConfigs) serviceConfig(balancerName string, balancerConfig map[string]interface{}) string {
+	type name struct {
+		Service string `json:"service,omitempty"`
+		Method  string `json:"method,omitempty"`
+	}
+	type retryPolicy struct {
+		MaxAttempts          int      `json:"maxAttempts"`
+		InitialBackoff       string   `json:"initialBackoff"`
+		MaxBackoff           string   `json:"maxBackoff"`
+		BackoffMultiplier    float64  `json:"backoffMultiplier"`
+		RetryableStatusCodes []string `json:"retryableStatusCodes"`
+	}
+	type methodConfig struct {
+		Name        []name       `json:"name"`
+		Timeout     string       `json:"timeout,omitempty"`
+		RetryPolicy *retryPolicy `json:"retryPolicy,omitempty"`
+	}
+
+	sc := struct {
+		LoadBalancingPolicy string                   `json:"loadBalancingPolicy,omitempty"`
+		LoadBalancingConfig []map[string]interface{} `json:"loadBalancingConfig,omitempty"`
+		MethodConfig        []methodConfig           `json:"methodConfig,omitempty"`
+	}{
+		LoadBalancingPolicy: balancerName,
+	}
+	if balancerConfig != nil {
+		sc.LoadBalancingPolicy = ""
+		sc.LoadBalancingConfig = []map[string]interface{}{{balancerName: balancerConfig}}
+	}
+
+	paths := make([]string, 0, len(methods))
+	for path := range methods {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		mc := methods[path]
+		item := methodConfig{Name: []name{{}}}
+		if path != "" {
+			service, method, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+			item.Name[0] = name{Service: service, Method: method}
+		}
+		if mc.Timeout > 0 {
+			item.Timeout = seconds(mc.Timeout)
+		}
+		if mc.Retry != nil {
+			item.RetryPolicy = &retryPolicy{
+				MaxAttempts:          mc.Retry.MaxAttempts,
+				InitialBackoff:       seconds(mc.Retry.InitialBackoff),
+				MaxBackoff:           seconds(mc.Retry.MaxBackoff),
+				BackoffMultiplier:    mc.Retry.BackoffMultiplier,
+				RetryableStatusCodes: mc.Retry.RetryableStatusCodes,
+			}
+		}
+		sc.MethodConfig = append(sc.MethodConfig, item)
+	}
+
+	data, _ := json.Marshal(sc)
+	return string(data)
+}
+
+func (p *RetryPolicy) init() error {
+	if p.MaxAttempts < 2 || p.MaxAttempts > maxAttempts {
+		return fmt.Errorf("retry maxAttempts should be in [2, %d]", maxAttempts)
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = time.Second
+	}
+	if p.BackoffMultiplier <= 0 {
+		p.BackoffMultiplier = 2
+	}
+	if len(p.RetryableStatusCodes) == 0 {
+		p.RetryableStatusCodes = []string{"UNAVAILABLE"}
+	}
+
+	if _, err := parseCodes(p.RetryableStatusCodes); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(p.RetryableStatusCodes))
+	for _, name := range p.RetryableStatusCodes {
+		names = append(names, strings.ToUpper(name))
+	}
+	p.RetryableStatusCodes = names
+	return nil
+}
+
+func (p *HedgingPolicy) init() error {
+	if p.MaxAttempts < 2 || p.MaxAttempts > maxAttempts {
+		return fmt.Errorf("hedging maxAttempts should be in [2, %d]", maxAttempts)
+	}
+	if p.HedgingDelay < 0 {
+		return fmt.Errorf("hedging delay should not be negative")
+	}
+
+	var err error
+	p.nonFatal, err = parseCodes(p.NonFatalStatusCodes)
+	return err
+}
+
+// do sends an attempt, and another one every HedgingDelay until one of
+// them succeeds or fails with a fatal code, or all attempts are sent and
+// failed. Replies of the attempts are written to clones of reply, and the
+// winner is merged into reply.
+func (p *HedgingPolicy) do(ctx context.Context, reply proto.Message, call func(context.Context, proto.Message) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	// cancel the attempts still in flight
+	defer cancel()
+
+	type result struct {
+		reply proto.Message
+		err   error
+	}
+	results := make(chan result, p.MaxAttempts)
+	sent, received := 0, 0
+	send := func() {
+		sent++
+		r := reply.ProtoReflect().New().Interface()
+		go func() {
+			err := call(ctx, r)
+			results <- result{reply: r, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(p.HedgingDelay)
+	defer timer.Stop()
+
+	send()
+	for {
+		select {
+		case <-timer.C:
+			if sent < p.MaxAttempts {
+				send()
+				timer.Reset(p.HedgingDelay)
+			}
+		case res := <-results:
+			received++
+			if res.err == nil {
+				proto.Reset(reply)
+				proto.Merge(reply, res.reply)
+				return nil
+			}
+			if !p.nonFatal[status.Code(res.err)] {
+				return res.err
+			}
+			if sent < p.MaxAttempts {
+				send()
+				timer.Reset(p.HedgingDelay)
+			} else if received == sent {
+				return res.err
+			}
+		}
+	}
+}
+
+func hedgingUnaryClientInterceptor(methods methodConfigs) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		mc := methods.lookup(method)
+		msg, ok := reply.(proto.Message)
+		if mc == nil || mc.Hedging == nil || !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		return mc.Hedging.do(ctx, msg, func(ctx context.Context, reply proto.Message) error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+type attemptsKey struct{}
+
+// withAttempts returns a context which counts attempts of the call made
+// with it, including retries and hedges
+func withAttempts(ctx context.Context) (context.Context, *int32) {
+	attempts := new(int32)
+	return context.WithValue(ctx, attemptsKey{}, attempts), attempts
+}
+
+// attemptsStatsHandler counts attempts for contexts from withAttempts,
+// stats handlers are notified of every attempt, while interceptors only
+// see the call
+type attemptsStatsHandler struct{}
+
+// TagRPC ...
+func (attemptsStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+// HandleRPC ...
+func (attemptsStatsHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	begin, ok := rs.(*stats.Begin)
+	// transparent retries never reached the server
+	if !ok || begin.IsTransparentRetryAttempt {
+		return
+	}
+	if attempts, ok := ctx.Value(attemptsKey{}).(*int32); ok {
+		atomic.AddInt32(attempts, 1)
+	}
+}
+
+// TagConn ...
+func (attemptsStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn ...
+func (attemptsStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// retries returns the number of attempts after the first one
+func retries(attempts *int32) int32 {
+	if n := atomic.LoadInt32(attempts); n > 1 {
+		return n - 1
+	}
+	return 0
+}
+
+func methodPath(name string) (string, error) {
+	if name == "*" {
+		return "", nil
+	}
+
+	service, method, ok := strings.Cut(strings.TrimPrefix(name, "/"), "/")
+	if !strings.HasPrefix(name, "/") || !ok || service == "" || method == "" || strings.Contains(method, "/") {
+		return "", fmt.Errorf("invalid method config name %q, expect /package.Service/Method, /package.Service/* or *", name)
+	}
+	if method == "*" {
+		return "/" + service + "/", nil
+	}
+	return name, nil
+}
+
+func parseCodes(names []string) (map[codes.Code]bool, error) {
+	list := make(map[codes.Code]bool, len(names))
+	for _, name := range names {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(`"` + strings.ToUpper(name) + `"`)); err != nil {
+			return nil, fmt.Errorf("invalid status code %q", name)
+		}
+		list[code] = true
+	}
+	return list, nil
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%gs", d.Seconds())
+}
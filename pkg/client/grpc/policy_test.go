@@ -0,0 +1,170 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMethodConfigs(t *testing.T) {
+	for _, list := range [][]MethodConfig{
+		{{Name: "helloworld.Greeter/SayHello"}},
+		{{Name: "/helloworld.Greeter"}},
+		{{Name: "*"}, {Name: "*"}},
+		{{Name: "*", Retry: &RetryPolicy{MaxAttempts: 2}, Hedging: &HedgingPolicy{MaxAttempts: 2}}},
+		{{Name: "*", Retry: &RetryPolicy{MaxAttempts: 6}}},
+		{{Name: "*", Retry: &RetryPolicy{MaxAttempts: 2, RetryableStatusCodes: []string{"NOT_A_CODE"}}}},
+		{{Name: "*", Hedging: &HedgingPolicy{MaxAttempts: 1}}},
+	} {
+		_, err := newMethodConfigs(list)
+		assert.NotNil(t, err, list)
+	}
+
+	methods, err := newMethodConfigs([]MethodConfig{
+		{Name: "*", Timeout: time.Second},
+		{Name: "/helloworld.Greeter/*", Retry: &RetryPolicy{MaxAttempts: 3, RetryableStatusCodes: []string{"unavailable", "ABORTED"}}},
+		{Name: "/helloworld.Greeter/SayHello", Timeout: 2 * time.Second, Hedging: &HedgingPolicy{MaxAttempts: 2}},
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2*time.Second, methods.timeout("/helloworld.Greeter/SayHello", 0))
+	assert.Equal(t, "hedge", methods.retryCode("/helloworld.Greeter/SayHello"))
+	assert.Equal(t, 3, methods.lookup("/helloworld.Greeter/SayBye").Retry.MaxAttempts)
+	assert.Equal(t, "retry", methods.retryCode("/helloworld.Greeter/SayBye"))
+	assert.Equal(t, time.Second, methods.timeout("/other.Service/Method", 0))
+	assert.Equal(t, time.Millisecond, methodConfigs(nil).timeout("/other.Service/Method", time.Millisecond))
+
+	assert.JSONEq(t, `{
+		"loadBalancingPolicy": "round_robin",
+		"methodConfig": [
+			{"name": [{}], "timeout": "1s"},
+			{"name": [{"service": "helloworld.Greeter"}], "retryPolicy": {
+				"maxAttempts": 3,
+				"initialBackoff": "0.1s",
+				"maxBackoff": "1s",
+				"backoffMultiplier": 2,
+				"retryableStatusCodes": ["UNAVAILABLE", "ABORTED"]
+			}},
+			{"name": [{"service": "helloworld.Greeter", "method": "SayHello"}], "timeout": "2s"}
+		]
+	}`, methods.serviceConfig("round_robin", nil))
+}
+
+func TestServiceConfig_Balancer(t *testing.T) {
+	assert.JSONEq(t, `{"loadBalancingConfig": [{"locality": {"zone": "z1"}}]}`,
+		methodConfigs(nil).serviceConfig("locality", map[string]interface{}{"zone": "z1"}))
+}
+
+func TestHedgingPolicy(t *testing.T) {
+	policy := &HedgingPolicy{MaxAttempts: 3, HedgingDelay: 10 * time.Millisecond, NonFatalStatusCodes: []string{"UNAVAILABLE"}}
+	assert.Nil(t, policy.init())
+
+	// the slow first attempt is canceled after the second one succeeds
+	var calls int32
+	canceled := make(chan struct{})
+	reply := &healthpb.HealthCheckResponse{}
+	err := policy.do(context.Background(), reply, func(ctx context.Context, reply proto.Message) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			close(canceled)
+			return ctx.Err()
+		}
+		reply.(*healthpb.HealthCheckResponse).Status = healthpb.HealthCheckResponse_SERVING
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, reply.Status)
+	<-canceled
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// non fatal errors trigger the next attempt at once, until all failed
+	calls = 0
+	beg := time.Now()
+	err = policy.do(context.Background(), reply, func(ctx context.Context, reply proto.Message) error {
+		atomic.AddInt32(&calls, 1)
+		return status.Error(codes.Unavailable, "unavailable")
+	})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Less(t, time.Since(beg), 20*time.Millisecond)
+
+	// fatal errors fail the call
+	calls = 0
+	err = policy.do(context.Background(), reply, func(ctx context.Context, reply proto.Message) error {
+		atomic.AddInt32(&calls, 1)
+		return status.Error(codes.InvalidArgument, "invalid")
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+type flakyHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	failures int32
+	calls    int32
+}
+
+func (s *flakyHealthServer) Check(context.Context, *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if atomic.AddInt32(&s.calls, 1) <= s.failures {
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func TestRetryPolicy(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	srv := &flakyHealthServer{failures: 2}
+	gserver := grpc.NewServer()
+	healthpb.RegisterHealthServer(gserver, srv)
+	go func() { _ = gserver.Serve(l) }()
+	defer gserver.Stop()
+
+	cfg := DefaultConfig()
+	cfg.Addr = l.Addr().String()
+	cfg.Methods = []MethodConfig{
+		{Name: "/grpc.health.v1.Health/*", Retry: &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}},
+	}
+	conn, err := cfg.Build()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&srv.calls))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metric.ClientHandleCounter.WithLabelValues(
+		metric.TypeGRPCUnary, cfg.Name, "/grpc.health.v1.Health/Check", conn.Target(), "retry")))
+
+	// invalid method config fails the build
+	cfg = DefaultConfig()
+	cfg.Addr = l.Addr().String()
+	cfg.Methods = []MethodConfig{{Name: "bad"}}
+	_, err = cfg.Build()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,176 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/douyu/jupiter/pkg/registry"
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/douyu/jupiter/pkg/util/xgo"
+	"github.com/douyu/jupiter/pkg/xlog"
+	"github.com/fsnotify/fsnotify"
+	"google.golang.org/grpc/resolver"
+)
+
+// FileScheme resolves targets like file:///path/endpoints.json, or
+// file://./endpoints.json relative to the working directory, to the
+// nodes in the file, which is reloaded when changed. The file is a json
+// array of ServiceInfo, or a json object of registry.Endpoints with
+// nodes keyed by address, route configs and provider configs.
+const FileScheme = "file"
+
+func init() {
+	resolver.Register(fileBuilder{})
+}
+
+type fileBuilder struct{}
+
+// Build ...
+func (fileBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	path, err := filepath.Abs(target.URL.Host + target.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// watch the directory, the file may be replaced by rename
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	r := &fileResolver{
+		cc:      cc,
+		path:    path,
+		watcher: watcher,
+	}
+	if err := r.load(); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	xgo.Go(r.watch)
+	return r, nil
+}
+
+// Scheme ...
+func (fileBuilder) Scheme() string {
+	return FileScheme
+}
+
+type fileResolver struct {
+	cc      resolver.ClientConn
+	path    string
+	watcher *fsnotify.Watcher
+
+	// mu serializes loads of watch and ResolveNow
+	mu     sync.Mutex
+	closed bool
+}
+
+func (r *fileResolver) watch() {
+	for {
+		select {
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			const mask = fsnotify.Write | fsnotify.Create | fsnotify.Rename
+			if event.Op&mask == 0 || filepath.Clean(event.Name) != r.path {
+				continue
+			}
+			if err := r.load(); err != nil {
+				// keep the previous nodes
+				xlog.Jupiter().Error("reload endpoints file failed", xlog.FieldErr(err), xlog.String("path", r.path))
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			xlog.Jupiter().Error("watch endpoints file failed", xlog.FieldErr(err), xlog.String("path", r.path))
+		}
+	}
+}
+
+func (r *fileResolver) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+
+	content, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	endpoint, err := parseEndpointsFile(content)
+	if err != nil {
+		return fmt.Errorf("file resolver: parse %s: %w", r.path, err)
+	}
+	return r.cc.UpdateState(newState(endpoint, ""))
+}
+
+func parseEndpointsFile(content []byte) (*registry.Endpoints, error) {
+	endpoint := (&registry.Endpoints{}).DeepCopy()
+
+	content = bytes.TrimSpace(content)
+	if bytes.HasPrefix(content, []byte("[")) {
+		var nodes []server.ServiceInfo
+		if err := json.Unmarshal(content, &nodes); err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			endpoint.Nodes[node.Address] = node
+		}
+	} else if err := json.Unmarshal(content, endpoint); err != nil {
+		return nil, err
+	}
+
+	for addr, node := range endpoint.Nodes {
+		if node.Address == "" {
+			node.Address = addr
+			endpoint.Nodes[addr] = node
+		}
+		if node.Address == "" {
+			return nil, fmt.Errorf("node without address")
+		}
+	}
+	return endpoint, nil
+}
+
+// ResolveNow reloads the file, in case change events are missed
+func (r *fileResolver) ResolveNow(resolver.ResolveNowOptions) {
+	xgo.Go(func() {
+		if err := r.load(); err != nil {
+			xlog.Jupiter().Error("reload endpoints file failed", xlog.FieldErr(err), xlog.String("path", r.path))
+		}
+	})
+}
+
+// Close ...
+func (r *fileResolver) Close() {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.watcher.Close()
+}
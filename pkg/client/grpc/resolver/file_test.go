@@ -0,0 +1,79 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/resolver"
+)
+
+func TestFileResolver(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "endpoints.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`[{"address": "10.0.0.1:9091", "weight": 200}]`), 0644))
+
+	cc := &fakeClientConn{states: make(chan resolver.State, 10), errs: make(chan error, 10)}
+	target := resolver.Target{}
+	target.URL.Scheme = FileScheme
+	target.URL.Path = path
+	r, err := fileBuilder{}.Build(target, cc, resolver.BuildOptions{})
+	assert.Nil(t, err)
+	defer r.Close()
+	assert.Equal(t, []string{"10.0.0.1:9091"}, cc.addrs(t))
+
+	// replaced by rename, in registry.Endpoints form
+	tmp := filepath.Join(dir, "endpoints.json.tmp")
+	assert.Nil(t, os.WriteFile(tmp, []byte(`{
+		"nodes": {"10.0.0.1:9091": {"group": "blue"}, "10.0.0.2:9091": {"group": "green"}},
+		"routeConfigs": {"1": {"uri": "/pkg.Service/Method", "upstream": {"groups": {"green": 1}}}}
+	}`), 0644))
+	assert.Nil(t, os.Rename(tmp, path))
+
+	var state resolver.State
+	assert.Eventually(t, func() bool {
+		select {
+		case state = <-cc.states:
+			return len(state.Addresses) == 2
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+	routes := state.Attributes.Value(constant.KeyRouteConfig).(map[string]registry.RouteConfig)
+	assert.Equal(t, map[string]int{"green": 1}, routes["1"].Upstream.Groups)
+
+	// broken file keeps the previous nodes
+	for len(cc.states) > 0 {
+		<-cc.states
+	}
+	assert.Nil(t, os.WriteFile(path, []byte(`{broken`), 0644))
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	select {
+	case state := <-cc.states:
+		t.Fatalf("unexpected state %v", state)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// missing file fails the build
+	target.URL.Path = filepath.Join(dir, "missing.json")
+	_, err = fileBuilder{}.Build(target, cc, resolver.BuildOptions{})
+	assert.NotNil(t, err)
+}
@@ -16,24 +16,43 @@ package resolver
 
 import (
 	"context"
+	"errors"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/douyu/jupiter/pkg/core/constant"
-	"github.com/douyu/jupiter/pkg/registry/etcdv3"
+	"github.com/douyu/jupiter/pkg/registry"
+	_ "github.com/douyu/jupiter/pkg/registry/etcdv3" // etcdv3 is the default registry kind
+	"github.com/douyu/jupiter/pkg/server"
 	"github.com/douyu/jupiter/pkg/util/xgo"
 	"github.com/douyu/jupiter/pkg/xlog"
 	"google.golang.org/grpc/attributes"
 	"google.golang.org/grpc/resolver"
 )
 
-// NewEtcdBuilder returns a new etcdv3 resolver builder.
-func NewEtcdBuilder(name string, registryConfig string) resolver.Builder {
+// resolveTimeout timeout of listing services on ResolveNow
+const resolveTimeout = 3 * time.Second
+
+var errWatchClosed = errors.New("registry watch closed")
+
+// NewBuilder returns a resolver builder of scheme, which resolves targets
+// like scheme:///grpc:name:v1:mode through the registry configured at
+// registryConfig, whatever its kind is, see registry.Get.
+func NewBuilder(scheme string, registryConfig string) resolver.Builder {
 	return &baseBuilder{
-		name:           name,
+		name:           scheme,
 		registryConfig: registryConfig,
 	}
 }
 
+// NewEtcdBuilder returns a new etcdv3 resolver builder.
+// Deprecated: use NewBuilder, registry kind is read from registryConfig.
+func NewEtcdBuilder(name string, registryConfig string) resolver.Builder {
+	return NewBuilder(name, registryConfig)
+}
+
 type baseBuilder struct {
 	name string
 
@@ -42,50 +61,35 @@ type baseBuilder struct {
 
 // Build ...
 func (b *baseBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
-	reg := etcdv3.RawConfig(b.registryConfig).MustSingleton()
+	reg, err := registry.Get(b.registryConfig)
+	if err != nil {
+		xlog.Jupiter().Error("get registry failed", xlog.FieldErr(err), xlog.FieldKey(b.registryConfig))
+		return nil, err
+	}
 
 	serviceName := target.Endpoint()
 	if !strings.HasSuffix(serviceName, "/") {
 		serviceName += "/"
 	}
 
-	endpoints, err := reg.WatchServices(context.Background(), serviceName)
+	ctx, cancel := context.WithCancel(context.Background())
+	endpoints, err := reg.WatchServices(ctx, serviceName)
 	if err != nil {
+		cancel()
 		xlog.Jupiter().Error("watch services failed", xlog.FieldErr(err))
 		return nil, err
 	}
 
-	var stop = make(chan struct{})
-	xgo.Go(func() {
-		for {
-			select {
-			case endpoint := <-endpoints:
-				xlog.Jupiter().Debug("watch services finished", xlog.FieldValueAny(endpoint))
-
-				var state = resolver.State{
-					Addresses: make([]resolver.Address, 0),
-					Attributes: attributes.
-						New(constant.KeyRouteConfig, endpoint.RouteConfigs).             // 路由配置
-						WithValue(constant.KeyProviderConfig, endpoint.ProviderConfigs). // 服务提供方元信息
-						WithValue(constant.KeyConsumerConfig, endpoint.ConsumerConfigs), // 服务消费方配置信息,
-				}
-				for _, node := range endpoint.Nodes {
-					var address resolver.Address
-					address.Addr = node.Address
-					address.ServerName = serviceName
-					address.Attributes = attributes.New(constant.KeyServiceInfo, node)
-					state.Addresses = append(state.Addresses, address)
-				}
-				_ = cc.UpdateState(state)
-			case <-stop:
-				return
-			}
-		}
-	})
+	r := &baseResolver{
+		cc:          cc,
+		reg:         reg,
+		serviceName: serviceName,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	xgo.Go(func() { r.watch(endpoints) })
 
-	return &baseResolver{
-		stop: stop,
-	}, nil
+	return r, nil
 }
 
 // Scheme ...
@@ -94,11 +98,109 @@ func (b baseBuilder) Scheme() string {
 }
 
 type baseResolver struct {
-	stop chan struct{}
+	cc          resolver.ClientConn
+	reg         registry.Registry
+	serviceName string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	endpoints *registry.Endpoints
+	resolving int32
 }
 
-// ResolveNow ...
-func (b *baseResolver) ResolveNow(options resolver.ResolveNowOptions) {}
+func (r *baseResolver) watch(endpoints chan registry.Endpoints) {
+	for {
+		select {
+		case endpoint, ok := <-endpoints:
+			if !ok {
+				if r.ctx.Err() == nil {
+					r.cc.ReportError(errWatchClosed)
+				}
+				return
+			}
+			xlog.Jupiter().Debug("watch services finished", xlog.FieldValueAny(endpoint))
+			r.update(&endpoint)
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// ResolveNow lists services from the registry, in case watch events
+// are missed. Node infos known from watch are kept.
+func (r *baseResolver) ResolveNow(options resolver.ResolveNowOptions) {
+	if !atomic.CompareAndSwapInt32(&r.resolving, 0, 1) {
+		return
+	}
+
+	xgo.Go(func() {
+		defer atomic.StoreInt32(&r.resolving, 0)
+
+		ctx, cancel := context.WithTimeout(r.ctx, resolveTimeout)
+		defer cancel()
+		services, err := r.reg.ListServices(ctx, r.serviceName)
+		if err != nil {
+			if r.ctx.Err() == nil {
+				xlog.Jupiter().Error("list services failed", xlog.FieldErr(err), xlog.FieldName(r.serviceName))
+				r.cc.ReportError(err)
+			}
+			return
+		}
+
+		r.mu.Lock()
+		endpoint := r.endpoints.DeepCopy()
+		r.mu.Unlock()
+		if endpoint == nil {
+			endpoint = (&registry.Endpoints{}).DeepCopy()
+		}
+
+		nodes := make(map[string]server.ServiceInfo, len(services))
+		for _, service := range services {
+			if node, ok := endpoint.Nodes[service.Address]; ok {
+				nodes[service.Address] = node
+				continue
+			}
+			nodes[service.Address] = *service
+		}
+		endpoint.Nodes = nodes
+		r.update(endpoint)
+	})
+}
+
+func (r *baseResolver) update(endpoint *registry.Endpoints) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ctx.Err() != nil {
+		return
+	}
+	r.endpoints = endpoint
+
+	if err := r.cc.UpdateState(newState(endpoint, r.serviceName)); err != nil {
+		xlog.Jupiter().Warn("update resolver state failed", xlog.FieldErr(err), xlog.FieldName(r.serviceName))
+	}
+}
+
+// newState returns the resolver state of endpoint, configs and nodes are
+// attached as attributes for balancers
+func newState(endpoint *registry.Endpoints, serverName string) resolver.State {
+	var state = resolver.State{
+		Addresses: make([]resolver.Address, 0),
+		Attributes: attributes.
+			New(constant.KeyRouteConfig, endpoint.RouteConfigs).             // 路由配置
+			WithValue(constant.KeyProviderConfig, endpoint.ProviderConfigs). // 服务提供方元信息
+			WithValue(constant.KeyConsumerConfig, endpoint.ConsumerConfigs), // 服务消费方配置信息,
+	}
+	for _, node := range endpoint.Nodes {
+		var address resolver.Address
+		address.Addr = node.Address
+		address.ServerName = serverName
+		address.Attributes = attributes.New(constant.KeyServiceInfo, node)
+		state.Addresses = append(state.Addresses, address)
+	}
+	return state
+}
 
 // Close ...
-func (b *baseResolver) Close() { b.stop <- struct{}{} }
+func (r *baseResolver) Close() { r.cancel() }
@@ -14,8 +14,93 @@
 
 package resolver
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/douyu/jupiter/pkg/conf"
+	"github.com/douyu/jupiter/pkg/registry"
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/resolver"
+)
+
+type fakeClientConn struct {
+	resolver.ClientConn
+	states chan resolver.State
+	errs   chan error
+}
+
+func (cc *fakeClientConn) UpdateState(state resolver.State) error {
+	cc.states <- state
+	return nil
+}
+
+func (cc *fakeClientConn) ReportError(err error) {
+	cc.errs <- err
+}
+
+func (cc *fakeClientConn) addrs(t *testing.T) []string {
+	select {
+	case state := <-cc.states:
+		addrs := make([]string, 0, len(state.Addresses))
+		for _, addr := range state.Addresses {
+			addrs = append(addrs, addr.Addr)
+		}
+		sort.Strings(addrs)
+		return addrs
+	case <-time.After(time.Second):
+		t.Fatal("no state updated")
+		return nil
+	}
+}
 
 func Test_baseResolver(t *testing.T) {
+	assert.Nil(t, conf.LoadFromReader(bytes.NewBufferString(`
+[jupiter.registry.local]
+	kind = "local"
+[jupiter.registry.invalid]
+	kind = "invalid"
+`), toml.Unmarshal))
+
+	reg, err := registry.Get("jupiter.registry.local")
+	assert.Nil(t, err)
+	info := &server.ServiceInfo{Name: "resolver.test", Scheme: "grpc", Address: "127.0.0.1:1"}
+	assert.Nil(t, reg.RegisterService(context.Background(), info))
+
+	cc := &fakeClientConn{states: make(chan resolver.State, 10), errs: make(chan error, 10)}
+	target := resolver.Target{URL: url.URL{Scheme: "local", Path: "/" + info.ServicePrefix()}}
+	r, err := NewBuilder("local", "jupiter.registry.local").Build(target, cc, resolver.BuildOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.1:1"}, cc.addrs(t))
+
+	// watch
+	info2 := &server.ServiceInfo{Name: "resolver.test", Scheme: "grpc", Address: "127.0.0.1:2"}
+	assert.Nil(t, reg.RegisterService(context.Background(), info2))
+	t.Cleanup(func() {
+		_ = reg.UnregisterService(context.Background(), info2)
+	})
+	assert.Equal(t, []string{"127.0.0.1:1", "127.0.0.1:2"}, cc.addrs(t))
+
+	// resolve now
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	assert.Equal(t, []string{"127.0.0.1:1", "127.0.0.1:2"}, cc.addrs(t))
+
+	r.Close()
+	assert.Nil(t, reg.UnregisterService(context.Background(), info))
+	select {
+	case <-cc.states:
+		t.Fatal("state updated after close")
+	case err := <-cc.errs:
+		t.Fatal("error reported after close", err)
+	case <-time.After(100 * time.Millisecond):
+	}
 
+	_, err = NewBuilder("invalid", "jupiter.registry.invalid").Build(target, cc, resolver.BuildOptions{})
+	assert.NotNil(t, err)
 }
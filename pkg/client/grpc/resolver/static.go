@@ -0,0 +1,123 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/douyu/jupiter/pkg/registry"
+	"github.com/douyu/jupiter/pkg/server"
+	"google.golang.org/grpc/resolver"
+)
+
+// StaticScheme resolves targets like
+// static:///10.0.0.1:9091;weight=200;zone=z1,10.0.0.2:9091 to the
+// listed addresses, see ParseStaticNodes.
+const StaticScheme = "static"
+
+func init() {
+	resolver.Register(staticBuilder{})
+}
+
+type staticBuilder struct{}
+
+// Build ...
+func (staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	nodes, err := ParseStaticNodes(target.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := (&registry.Endpoints{}).DeepCopy()
+	for _, node := range nodes {
+		endpoint.Nodes[node.Address] = node
+	}
+	if err := cc.UpdateState(newState(endpoint, "")); err != nil {
+		return nil, err
+	}
+	return staticResolver{}, nil
+}
+
+// Scheme ...
+func (staticBuilder) Scheme() string {
+	return StaticScheme
+}
+
+type staticResolver struct{}
+
+// ResolveNow ...
+func (staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+// Close ...
+func (staticResolver) Close() {}
+
+// ParseStaticNodes parses comma separated addresses, each address can be
+// followed by semicolon separated attributes: weight, region, zone,
+// deployment, group, name and version set the fields of ServiceInfo,
+// others are set to Metadata.
+func ParseStaticNodes(endpoint string) ([]server.ServiceInfo, error) {
+	var nodes []server.ServiceInfo
+	for _, item := range strings.Split(endpoint, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, ";")
+		node := server.ServiceInfo{
+			Address:  parts[0],
+			Scheme:   "grpc",
+			Weight:   100,
+			Enable:   true,
+			Healthy:  true,
+			Metadata: make(map[string]string),
+		}
+		for _, attr := range parts[1:] {
+			key, value, ok := strings.Cut(attr, "=")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("static resolver: invalid attribute %q of %s", attr, node.Address)
+			}
+			switch key {
+			case "weight":
+				weight, err := strconv.ParseFloat(value, 64)
+				if err != nil || weight < 0 {
+					return nil, fmt.Errorf("static resolver: invalid weight %q of %s", value, node.Address)
+				}
+				node.Weight = weight
+			case "region":
+				node.Region = value
+			case "zone":
+				node.Zone = value
+			case "deployment":
+				node.Deployment = value
+			case "group":
+				node.Group = value
+			case "name":
+				node.Name = value
+			case "version":
+				node.Version = value
+			default:
+				node.Metadata[key] = value
+			}
+		}
+		nodes = append(nodes, node)
+	}
+
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("static resolver: no address in %q", endpoint)
+	}
+	return nodes, nil
+}
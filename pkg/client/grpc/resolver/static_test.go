@@ -0,0 +1,85 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	_ "github.com/douyu/jupiter/pkg/client/grpc/balancer" // swr balancer
+	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/resolver"
+)
+
+func TestParseStaticNodes(t *testing.T) {
+	nodes, err := ParseStaticNodes("10.0.0.1:9091;weight=200;zone=z1;group=blue;color=red, 10.0.0.2:9091")
+	assert.Nil(t, err)
+	assert.Equal(t, []server.ServiceInfo{
+		{
+			Address: "10.0.0.1:9091", Scheme: "grpc", Weight: 200, Enable: true, Healthy: true,
+			Zone: "z1", Group: "blue", Metadata: map[string]string{"color": "red"},
+		},
+		{Address: "10.0.0.2:9091", Scheme: "grpc", Weight: 100, Enable: true, Healthy: true, Metadata: map[string]string{}},
+	}, nodes)
+
+	for _, endpoint := range []string{"", ",", "10.0.0.1:9091;weight", "10.0.0.1:9091;weight=-1", "10.0.0.1:9091;=1"} {
+		_, err := ParseStaticNodes(endpoint)
+		assert.NotNil(t, err, endpoint)
+	}
+}
+
+func TestStaticResolver(t *testing.T) {
+	cc := &fakeClientConn{states: make(chan resolver.State, 10), errs: make(chan error, 10)}
+	target := resolver.Target{}
+	target.URL.Scheme = StaticScheme
+	target.URL.Path = "/10.0.0.2:9091,10.0.0.1:9091;deployment=gray"
+	r, err := staticBuilder{}.Build(target, cc, resolver.BuildOptions{})
+	assert.Nil(t, err)
+	defer r.Close()
+
+	state := <-cc.states
+	assert.Len(t, state.Addresses, 2)
+	for _, addr := range state.Addresses {
+		node := addr.Attributes.Value(constant.KeyServiceInfo).(server.ServiceInfo)
+		assert.Equal(t, addr.Addr, node.Address)
+		if addr.Addr == "10.0.0.1:9091" {
+			assert.Equal(t, "gray", node.Deployment)
+		}
+	}
+
+	// dial through the registered scheme
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go func() { _ = s.Serve(l) }()
+	defer s.Stop()
+
+	conn, err := grpc.Dial("static:///"+l.Addr().String()+";weight=10",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingPolicy": "swr"}`),
+	)
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	assert.Nil(t, err)
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+
+	"github.com/douyu/jupiter/pkg/util/xerror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// statusUnaryClientInterceptor 还原服务端记录在ErrorInfo中的原始错误码，
+// 之后xerror.Convert和ecode.ExtractCodes可以拿到服务端返回的错误码和数据
+func statusUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return restoreStatusError(invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+// statusStreamClientInterceptor 还原流式调用的原始错误码
+func statusStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		s, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, restoreStatusError(err)
+		}
+		return &statusClientStream{ClientStream: s}, nil
+	}
+}
+
+type statusClientStream struct {
+	grpc.ClientStream
+}
+
+// RecvMsg ...
+func (s *statusClientStream) RecvMsg(m interface{}) error {
+	return restoreStatusError(s.ClientStream.RecvMsg(m))
+}
+
+func restoreStatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if s, ok := status.FromError(err); ok {
+		if rs := xerror.Restore(s); rs != s {
+			return rs.Err()
+		}
+	}
+	return err
+}
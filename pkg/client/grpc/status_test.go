@@ -0,0 +1,68 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/douyu/jupiter/pkg/core/ecode"
+	"github.com/douyu/jupiter/pkg/server/xgrpc"
+	"github.com/douyu/jupiter/pkg/util/xerror"
+	helloworldv1 "github.com/douyu/jupiter/proto/helloworld/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type errorGreeter struct {
+	helloworldv1.UnimplementedGreeterServiceServer
+}
+
+func (errorGreeter) SayHello(_ context.Context, req *helloworldv1.SayHelloRequest) (*helloworldv1.SayHelloResponse, error) {
+	switch req.Name {
+	case "xerror":
+		return nil, &xerror.Err{Ecode: 10001, Msg: "biz error", Data: map[string]interface{}{"id": "1"}}
+	case "ecode":
+		return nil, status.Error(codes.Code(10002), "ecode error")
+	}
+	panic("boom")
+}
+
+func TestStatusMapping(t *testing.T) {
+	config := xgrpc.DefaultConfig()
+	config.Host = "127.0.0.1"
+	config.Port = 0
+	s := config.MustBuild()
+	defer s.Stop()
+	helloworldv1.RegisterGreeterServiceServer(s.Server, errorGreeter{})
+	go func() { _ = s.Serve() }()
+
+	cfg := DefaultConfig()
+	cfg.Addr = s.Address()
+	client := helloworldv1.NewGreeterServiceClient(cfg.MustBuild())
+
+	_, err := client.SayHello(context.Background(), &helloworldv1.SayHelloRequest{Name: "xerror"})
+	assert.Equal(t, &xerror.Err{Ecode: 10001, Msg: "biz error", Data: map[string]interface{}{"id": "1"}}, xerror.Convert(err))
+	assert.Equal(t, int32(10001), ecode.ExtractCodes(err).Code)
+
+	_, err = client.SayHello(context.Background(), &helloworldv1.SayHelloRequest{Name: "ecode"})
+	assert.Equal(t, int32(10002), xerror.Convert(err).Ecode)
+	assert.Equal(t, "ecode error", xerror.Convert(err).Msg)
+
+	_, err = client.SayHello(context.Background(), &helloworldv1.SayHelloRequest{Name: "panic"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, xerror.Internal.Ecode, xerror.Convert(err).Ecode)
+}
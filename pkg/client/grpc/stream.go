@@ -0,0 +1,321 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/douyu/jupiter/pkg"
+	"github.com/douyu/jupiter/pkg/core/ecode"
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/douyu/jupiter/pkg/core/sentinel"
+	"github.com/douyu/jupiter/pkg/core/xtrace"
+	"github.com/douyu/jupiter/pkg/xlog"
+	"github.com/fatih/color"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// monitoredClientStream wraps grpc.ClientStream to count messages and
+// observe the end of stream, finish is called exactly once with the
+// final error, nil if the stream ended with OK. Streams abandoned by
+// canceling ctx are finished with ctx.Err().
+type monitoredClientStream struct {
+	grpc.ClientStream
+	desc *grpc.StreamDesc
+
+	sent     int64
+	received int64
+
+	once   sync.Once
+	stop   func() bool
+	finish func(s *monitoredClientStream, err error)
+}
+
+func newMonitoredClientStream(ctx context.Context, s grpc.ClientStream, desc *grpc.StreamDesc, finish func(*monitoredClientStream, error)) *monitoredClientStream {
+	ms := &monitoredClientStream{
+		ClientStream: s,
+		desc:         desc,
+		finish:       finish,
+	}
+	ms.stop = context.AfterFunc(ctx, func() {
+		ms.done(ctx.Err())
+	})
+	return ms
+}
+
+// SendMsg ...
+func (s *monitoredClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	switch err {
+	case nil:
+		atomic.AddInt64(&s.sent, 1)
+	case io.EOF:
+		// the stream is aborted, status will be returned by RecvMsg
+	default:
+		s.done(err)
+	}
+	return err
+}
+
+// RecvMsg ...
+func (s *monitoredClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch err {
+	case nil:
+		atomic.AddInt64(&s.received, 1)
+		// non server streaming stream ends with the only response
+		if !s.desc.ServerStreams {
+			s.done(nil)
+		}
+	case io.EOF:
+		s.done(nil)
+	default:
+		s.done(err)
+	}
+	return err
+}
+
+// Header ...
+func (s *monitoredClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.done(err)
+	}
+	return md, err
+}
+
+// Sent returns the number of messages sent
+func (s *monitoredClientStream) Sent() int64 {
+	return atomic.LoadInt64(&s.sent)
+}
+
+// Received returns the number of messages received
+func (s *monitoredClientStream) Received() int64 {
+	return atomic.LoadInt64(&s.received)
+}
+
+func (s *monitoredClientStream) done(err error) {
+	s.once.Do(func() {
+		s.stop()
+		s.finish(s, err)
+	})
+}
+
+func metricStreamClientInterceptor(name string, methods methodConfigs) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		beg := time.Now()
+		ctx, attempts := withAttempts(ctx)
+		observe := func(err error) {
+			if n := retries(attempts); n > 0 {
+				metric.ClientHandleCounter.Add(float64(n), metric.TypeGRPCStream, name, method, cc.Target(), methods.retryCode(method))
+			}
+
+			// 收敛err错误，将err过滤后，可以知道err是否为系统错误码
+			spbStatus := ecode.ExtractCodes(err)
+			if spbStatus.Code < ecode.EcodeNum {
+				metric.ClientHandleCounter.Inc(metric.TypeGRPCStream, name, method, cc.Target(), spbStatus.GetMessage())
+			} else {
+				metric.ClientHandleCounter.Inc(metric.TypeGRPCStream, name, method, cc.Target(), "biz error")
+			}
+			metric.ClientHandleHistogram.Observe(time.Since(beg).Seconds(), metric.TypeGRPCStream, name, method, cc.Target())
+		}
+
+		clientStream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			observe(err)
+			return nil, err
+		}
+
+		return newMonitoredClientStream(ctx, clientStream, desc, func(_ *monitoredClientStream, err error) {
+			observe(err)
+		}), nil
+	}
+}
+
+func sentinelStreamClientInterceptor(name string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		entry, blockerr := sentinel.Entry(name,
+			api.WithResourceType(base.ResTypeRPC),
+			api.WithTrafficType(base.Outbound))
+		if blockerr != nil {
+			return nil, blockerr
+		}
+
+		clientStream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			entry.Exit(base.WithError(err))
+			return nil, err
+		}
+
+		return newMonitoredClientStream(ctx, clientStream, desc, func(_ *monitoredClientStream, err error) {
+			entry.Exit(base.WithError(err))
+		}), nil
+	}
+}
+
+func debugStreamClientInterceptor(addr string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		prefix := fmt.Sprintf("[%s]", addr)
+
+		fmt.Printf("%-50s[%s] => %s\n", color.GreenString(prefix), time.Now().Format("04:05.000"), color.GreenString("Open: "+method))
+		clientStream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			fmt.Printf("%-50s[%s] => %s\n", color.RedString(prefix), time.Now().Format("04:05.000"), color.RedString("Erro: "+err.Error()))
+			return nil, err
+		}
+
+		return newMonitoredClientStream(ctx, clientStream, desc, func(s *monitoredClientStream, err error) {
+			if err != nil {
+				fmt.Printf("%-50s[%s] => %s\n", color.RedString(prefix), time.Now().Format("04:05.000"), color.RedString("Erro: "+err.Error()))
+				return
+			}
+			fmt.Printf("%-50s[%s] => %s\n", color.GreenString(prefix), time.Now().Format("04:05.000"),
+				color.GreenString(fmt.Sprintf("Done: %s | sent %d, received %d", method, s.Sent(), s.Received())))
+		}), nil
+	}
+}
+
+// TraceStreamClientInterceptor ...
+func TraceStreamClientInterceptor() grpc.StreamClientInterceptor {
+	tracer := xtrace.NewTracer(trace.SpanKindClient)
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemGRPC,
+	}
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if !ok {
+			md = metadata.New(nil)
+		} else {
+			md = md.Copy()
+		}
+
+		ctx, span := tracer.Start(ctx, method, xtrace.MetadataReaderWriter(md), trace.WithAttributes(attrs...))
+		ctx = metadata.NewOutgoingContext(ctx, md)
+		span.SetAttributes(
+			semconv.RPCMethodKey.String(method),
+		)
+
+		end := func(err error) {
+			span.SetStatus(codes.Ok, "ok")
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+
+		clientStream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			end(err)
+			return nil, err
+		}
+
+		return newMonitoredClientStream(ctx, clientStream, desc, func(s *monitoredClientStream, err error) {
+			span.SetAttributes(
+				attribute.Int64("rpc.message.sent", s.Sent()),
+				attribute.Int64("rpc.message.received", s.Received()),
+			)
+			end(err)
+		}), nil
+	}
+}
+
+func aidStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, ok := metadata.FromOutgoingContext(ctx)
+		clientAidMD := metadata.Pairs("aid", pkg.AppID())
+		if ok {
+			md = metadata.Join(md, clientAidMD)
+		} else {
+			md = clientAidMD
+		}
+		ctx = metadata.NewOutgoingContext(ctx, md)
+
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// timeoutStreamClientInterceptor 流式调用可能长期存在，不设置默认超时，只记录建立流的慢调用
+func timeoutStreamClientInterceptor(_logger *xlog.Logger, slowThreshold time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		now := time.Now()
+		clientStream, err := streamer(ctx, desc, cc, method, opts...)
+		du := time.Since(now)
+
+		if slowThreshold > time.Duration(0) && du > slowThreshold {
+			_logger.Error("slow",
+				xlog.FieldErr(errSlowCommand),
+				xlog.FieldType("stream"),
+				xlog.FieldMethod(method),
+				xlog.FieldName(cc.Target()),
+				xlog.FieldCost(du),
+			)
+		}
+		return clientStream, err
+	}
+}
+
+// loggerStreamClientInterceptor gRPC客户端流式调用日志中间件，在流结束时记录
+func loggerStreamClientInterceptor(_logger *xlog.Logger, name string, accessInterceptorLevel string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		beg := time.Now()
+		access := func(err error, sent, received int64) {
+			spbStatus := ecode.ExtractCodes(err)
+			fields := []xlog.Field{
+				xlog.FieldType("stream"),
+				xlog.FieldCode(spbStatus.Code),
+				xlog.FieldName(name),
+				xlog.FieldMethod(method),
+				xlog.FieldCost(time.Since(beg)),
+				xlog.Int64("sent", sent),
+				xlog.Int64("received", received),
+			}
+
+			switch {
+			case err != nil && spbStatus.Code < ecode.EcodeNum:
+				// 只记录系统级别错误
+				_logger.Error("access", append(fields, xlog.FieldStringErr(spbStatus.Message))...)
+			case err != nil:
+				// 业务报错只做warning
+				_logger.Warn("access", append(fields, xlog.FieldStringErr(spbStatus.Message))...)
+			case accessInterceptorLevel == "info":
+				_logger.Info("access", fields...)
+			}
+		}
+
+		clientStream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			access(err, 0, 0)
+			return nil, err
+		}
+
+		return newMonitoredClientStream(ctx, clientStream, desc, func(s *monitoredClientStream, err error) {
+			access(err, s.Sent(), s.Received())
+		}), nil
+	}
+}
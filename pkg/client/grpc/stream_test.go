@@ -0,0 +1,139 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type fakeClientStream struct {
+	grpc.ClientStream
+	recv []error
+}
+
+func (s *fakeClientStream) SendMsg(m interface{}) error { return nil }
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	err := s.recv[0]
+	s.recv = s.recv[1:]
+	return err
+}
+
+func TestMonitoredClientStream(t *testing.T) {
+	var calls int
+	var final error
+	finish := func(s *monitoredClientStream, err error) {
+		calls++
+		final = err
+	}
+
+	// server streaming ends with io.EOF
+	s := newMonitoredClientStream(context.Background(), &fakeClientStream{recv: []error{nil, nil, io.EOF}}, &grpc.StreamDesc{ServerStreams: true}, finish)
+	assert.Nil(t, s.SendMsg(nil))
+	assert.Nil(t, s.RecvMsg(nil))
+	assert.Nil(t, s.RecvMsg(nil))
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, io.EOF, s.RecvMsg(nil))
+	assert.Equal(t, 1, calls)
+	assert.Nil(t, final)
+	assert.Equal(t, int64(1), s.Sent())
+	assert.Equal(t, int64(2), s.Received())
+
+	// client streaming ends with the only response
+	calls = 0
+	s = newMonitoredClientStream(context.Background(), &fakeClientStream{recv: []error{nil}}, &grpc.StreamDesc{ClientStreams: true}, finish)
+	assert.Nil(t, s.RecvMsg(nil))
+	assert.Equal(t, 1, calls)
+
+	// error
+	calls = 0
+	errStream := status.Error(codes.Unavailable, "unavailable")
+	s = newMonitoredClientStream(context.Background(), &fakeClientStream{recv: []error{errStream, io.EOF}}, &grpc.StreamDesc{ServerStreams: true}, finish)
+	assert.Equal(t, errStream, s.RecvMsg(nil))
+	assert.Equal(t, io.EOF, s.RecvMsg(nil))
+	assert.Equal(t, 1, calls)
+	assert.True(t, errors.Is(final, errStream))
+
+	// abandoned by canceling ctx
+	calls = 0
+	done := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	s = newMonitoredClientStream(ctx, &fakeClientStream{recv: []error{nil}}, &grpc.StreamDesc{ServerStreams: true}, func(s *monitoredClientStream, err error) {
+		finish(s, err)
+		close(done)
+	})
+	assert.Nil(t, s.RecvMsg(nil))
+	cancel()
+	<-done
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, context.Canceled, final)
+}
+
+func TestStreamClientInterceptors(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	incoming := make(chan metadata.MD, 1)
+	srv := grpc.NewServer(grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		incoming <- md
+		return handler(srv, ss)
+	}))
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	defer srv.Stop()
+
+	config := DefaultConfig()
+	config.Name = "stream"
+	config.Addr = l.Addr().String()
+	conn, err := config.Build()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+
+	resp, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	resp, err = stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	md := <-incoming
+	assert.NotEmpty(t, md.Get("aid"))
+
+	cancel()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Canceled, status.Code(err))
+}
@@ -10,6 +10,8 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/core/inspect"
+	"github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/core/singleton"
 	"github.com/douyu/jupiter/pkg/util/xdebug"
 	"github.com/douyu/jupiter/pkg/xlog"
@@ -88,6 +90,7 @@ func (config *Config) Build() (*Client, error) {
 	if ins.master == nil && len(ins.slave) == 0 {
 		return ins, errors.New("no master or slaves for " + config.name)
 	}
+	inspect.Register(metric.TypeRedis, config.name, config, ins.stats)
 	return ins, nil
 }
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/core/inspect"
+	"github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/core/singleton"
 	"github.com/douyu/jupiter/pkg/util/xdebug"
 	"github.com/douyu/jupiter/pkg/xlog"
@@ -55,6 +57,7 @@ func (config *ClusterOptions) BuildCluster() (*ClusterClient, error) {
 	if err != nil {
 		return nil, err
 	}
+	inspect.Register(metric.TypeRedis, config.name, config, ins.stats)
 	return ins, nil
 }
 
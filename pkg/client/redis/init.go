@@ -3,12 +3,11 @@ package redis
 import (
 	"net/http"
 	"sync"
-	"time"
 
 	"github.com/go-redis/redis/v8"
 	jsoniter "github.com/json-iterator/go"
 
-	prome "github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/douyu/jupiter/pkg/core/inspect"
 	"github.com/douyu/jupiter/pkg/server/governor"
 )
 
@@ -23,33 +22,43 @@ func init() {
 	governor.HandleFunc("/debug/redis/stats", func(w http.ResponseWriter, r *http.Request) {
 		_ = jsoniter.NewEncoder(w).Encode(stats())
 	})
-	go monitor()
 }
-func monitor() {
-	for {
-		instances.Range(func(key, val interface{}) bool {
-			name := key.(string)
-			obj := val.(*storeRedis)
-			var poolStats *redis.PoolStats
-			if obj.ClientStub != nil {
-				poolStats = obj.ClientStub.PoolStats()
-			}
-			if obj.ClientCluster != nil {
-				poolStats = obj.ClientCluster.PoolStats()
-			}
 
-			if poolStats != nil {
-				prome.ClientStatsGauge.Set(float64(poolStats.Hits), prome.TypeRedis, name, "hits")
-				prome.ClientStatsGauge.Set(float64(poolStats.Misses), prome.TypeRedis, name, "misses")
-				prome.ClientStatsGauge.Set(float64(poolStats.Timeouts), prome.TypeRedis, name, "timeouts")
-				prome.ClientStatsGauge.Set(float64(poolStats.TotalConns), prome.TypeRedis, name, "total_conns")
-				prome.ClientStatsGauge.Set(float64(poolStats.IdleConns), prome.TypeRedis, name, "idle_conns")
-				prome.ClientStatsGauge.Set(float64(poolStats.StaleConns), prome.TypeRedis, name, "stale_conns")
-			}
-			return true
-		})
-		time.Sleep(time.Second * 10)
+// poolStats merges stats of all pools, keyed like client_stats_gauge index.
+func poolStats(pools ...*redis.PoolStats) inspect.Stats {
+	var total redis.PoolStats
+	for _, ps := range pools {
+		total.Hits += ps.Hits
+		total.Misses += ps.Misses
+		total.Timeouts += ps.Timeouts
+		total.TotalConns += ps.TotalConns
+		total.IdleConns += ps.IdleConns
+		total.StaleConns += ps.StaleConns
 	}
+
+	return inspect.Stats{
+		"hits":        total.Hits,
+		"misses":      total.Misses,
+		"timeouts":    total.Timeouts,
+		"total_conns": total.TotalConns,
+		"idle_conns":  total.IdleConns,
+		"stale_conns": total.StaleConns,
+	}
+}
+
+func (ins *Client) stats() inspect.Stats {
+	pools := make([]*redis.PoolStats, 0, len(ins.slave)+1)
+	if ins.master != nil {
+		pools = append(pools, ins.master.PoolStats())
+	}
+	for _, slave := range ins.slave {
+		pools = append(pools, slave.PoolStats())
+	}
+	return poolStats(pools...)
+}
+
+func (cc *ClusterClient) stats() inspect.Stats {
+	return poolStats(cc.PoolStats())
 }
 
 // stats
@@ -24,6 +24,7 @@ import (
 	"github.com/douyu/jupiter/pkg/conf"
 	"github.com/douyu/jupiter/pkg/core/constant"
 	"github.com/douyu/jupiter/pkg/core/ecode"
+	"github.com/douyu/jupiter/pkg/core/inspect"
 	"github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/core/sentinel"
 	"github.com/douyu/jupiter/pkg/core/singleton"
@@ -239,6 +240,7 @@ func (config *Config) Build() (*resty.Client, error) {
 		return nil
 	})
 
+	inspect.Register(metric.TypeResty, config.Name, config, nil)
 	return client, nil
 }
 
@@ -16,18 +16,21 @@ package rocketmq
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/apache/rocketmq-client-go/v2"
 	"github.com/apache/rocketmq-client-go/v2/primitive"
 	"github.com/apache/rocketmq-client-go/v2/producer"
 	"github.com/douyu/jupiter/pkg/core/hooks"
+	"github.com/douyu/jupiter/pkg/core/inspect"
+	"github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/util/xdebug"
 	"github.com/douyu/jupiter/pkg/xlog"
 	"github.com/samber/lo"
 )
 
 type Producer struct {
-	started bool
+	started *atomic.Bool
 
 	rocketmq.Producer
 	name string
@@ -50,6 +53,7 @@ func (conf *ProducerConfig) Build() *Producer {
 		name:           name,
 		ProducerConfig: *conf,
 		interceptors:   []primitive.Interceptor{},
+		started:        new(atomic.Bool),
 	}
 
 	cc.interceptors = append(cc.interceptors,
@@ -63,11 +67,19 @@ func (conf *ProducerConfig) Build() *Producer {
 		_ = cc.Start()
 	})
 
+	inspect.Register(metric.TypeRocketMQ, name+".producer", conf, func() inspect.Stats {
+		return inspect.Stats{
+			"started": cc.started.Load(),
+			"topic":   cc.Topic,
+			"group":   cc.Group,
+		}
+	})
+
 	return cc
 }
 
 func (pc *Producer) Start() error {
-	if pc.started {
+	if pc.started.Load() {
 		return nil
 	}
 
@@ -99,7 +111,7 @@ func (pc *Producer) Start() error {
 		)
 	}
 
-	pc.started = true
+	pc.started.Store(true)
 	pc.Producer = client
 	// 进程退出时，producer不Close，避免消息发失败
 	// defers.Register(pc.Close)
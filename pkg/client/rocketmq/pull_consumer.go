@@ -9,6 +9,8 @@ import (
 	"github.com/apache/rocketmq-client-go/v2/primitive"
 	"github.com/apache/rocketmq-client-go/v2/rlog"
 	"github.com/douyu/jupiter/pkg/core/hooks"
+	"github.com/douyu/jupiter/pkg/core/inspect"
+	"github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/core/xtrace"
 	"github.com/douyu/jupiter/pkg/util/xgo"
 	"github.com/douyu/jupiter/pkg/xlog"
@@ -57,6 +59,15 @@ func (conf *PullConsumerConfig) Build() *PullConsumer {
 		}
 	})
 
+	inspect.Register(metric.TypeRocketMQ, name+".pullConsumer", conf, func() inspect.Stats {
+		return inspect.Stats{
+			"started": cc.started.Load(),
+			"enable":  cc.Enable,
+			"topic":   cc.Topic,
+			"group":   cc.Group,
+		}
+	})
+
 	return cc
 }
 
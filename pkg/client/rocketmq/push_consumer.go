@@ -18,11 +18,14 @@ import (
 	"context"
 	"errors"
 	"runtime/debug"
+	"sync/atomic"
 
 	"github.com/apache/rocketmq-client-go/v2"
 	"github.com/apache/rocketmq-client-go/v2/consumer"
 	"github.com/apache/rocketmq-client-go/v2/primitive"
 	"github.com/douyu/jupiter/pkg/core/hooks"
+	"github.com/douyu/jupiter/pkg/core/inspect"
+	"github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/core/xtrace"
 	"github.com/douyu/jupiter/pkg/xlog"
 	"github.com/juju/ratelimit"
@@ -42,7 +45,7 @@ type PushConsumer struct {
 	subscribers  map[string]func(context.Context, ...*primitive.MessageExt) (consumer.ConsumeResult, error)
 	interceptors []primitive.Interceptor
 	bucket       *ratelimit.Bucket
-	started      bool
+	started      *atomic.Bool
 }
 
 func (conf *PushConsumerConfig) Build() *PushConsumer {
@@ -66,6 +69,7 @@ func (conf *PushConsumerConfig) Build() *PushConsumer {
 		subscribers:        make(map[string]func(context.Context, ...*primitive.MessageExt) (consumer.ConsumeResult, error)),
 		interceptors:       []primitive.Interceptor{},
 		bucket:             bucket,
+		started:            new(atomic.Bool),
 	}
 	cc.interceptors = append(cc.interceptors,
 		consumerMetricInterceptor(),
@@ -81,6 +85,16 @@ func (conf *PushConsumerConfig) Build() *PushConsumer {
 		}
 	})
 
+	inspect.Register(metric.TypeRocketMQ, name+".consumer", conf, func() inspect.Stats {
+		return inspect.Stats{
+			"started":       cc.started.Load(),
+			"enable":        cc.Enable,
+			"topic":         cc.Topic,
+			"group":         cc.Group,
+			"subscriptions": len(cc.subscribers),
+		}
+	})
+
 	return cc
 }
 
@@ -213,7 +227,7 @@ func (cc *PushConsumer) RegisterBatchMessage(f func(context.Context, ...*primiti
 }
 
 func (cc *PushConsumer) Start() error {
-	if cc.started {
+	if cc.started.Load() {
 		return nil
 	}
 
@@ -278,7 +292,7 @@ func (cc *PushConsumer) Start() error {
 		})
 	}
 
-	cc.started = true
+	cc.started.Store(true)
 
 	return nil
 }
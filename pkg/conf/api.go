@@ -30,6 +30,11 @@ func OnChange(fn func(*Configuration)) {
 	defaultConfiguration.OnChange(fn)
 }
 
+// Watch 注册key的变更回调函数，返回注销回调的函数
+func Watch(key string, fn func(*Configuration)) func() {
+	return defaultConfiguration.Watch(key, fn)
+}
+
 func OnLoaded(fn func(*Configuration)) {
 	defaultConfiguration.OnLoaded(fn)
 }
@@ -42,7 +42,7 @@ type Configuration struct {
 	onChanges []func(*Configuration)
 	onLoadeds []func(*Configuration)
 
-	watchers map[string][]func(*Configuration)
+	watchers map[string][]*watcher
 	// TODO: concurrency protect
 	loaded bool
 }
@@ -59,7 +59,7 @@ func New() *Configuration {
 		keyMap:    &sync.Map{},
 		onChanges: make([]func(*Configuration), 0),
 		onLoadeds: make([]func(*Configuration), 0),
-		watchers:  make(map[string][]func(*Configuration)),
+		watchers:  make(map[string][]*watcher),
 		loaded:    false,
 	}
 }
@@ -88,6 +88,35 @@ func (c *Configuration) OnChange(fn func(*Configuration)) {
 	c.onChanges = append(c.onChanges, fn)
 }
 
+type watcher struct {
+	fn func(*Configuration)
+}
+
+// Watch 注册key的变更回调，key下的配置项新增、修改或删除时调用，返回注销回调的函数。
+// 数据源包含key的上一级配置时，key下的配置以该数据源为准整体替换，数据源中删除的配置项随之删除
+func (c *Configuration) Watch(key string, fn func(*Configuration)) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &watcher{fn: fn}
+	c.watchers[key] = append(c.watchers[key], w)
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		watchers := c.watchers[key]
+		for i := range watchers {
+			if watchers[i] == w {
+				c.watchers[key] = append(watchers[:i:i], watchers[i+1:]...)
+				break
+			}
+		}
+		if len(c.watchers[key]) == 0 {
+			delete(c.watchers, key)
+		}
+	}
+}
+
 func (c *Configuration) OnLoaded(fn func(*Configuration)) {
 	if c.loaded {
 		fn(c)
@@ -174,42 +203,95 @@ func (c *Configuration) LoadFromReader(reader io.Reader, unmarshaller Unmarshall
 func (c *Configuration) apply(conf map[string]interface{}) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	var changes = make(map[string]interface{})
+
+	watched := make(map[string]map[string]interface{}, len(c.watchers))
+	for key := range c.watchers {
+		watched[key] = c.leaves(key)
+	}
 
 	xmap.MergeStringMap(c.override, conf)
+	for key := range c.watchers {
+		c.replace(key, conf)
+	}
+
 	for k, v := range c.traverse(c.keyDelim) {
-		orig, ok := c.keyMap.Load(k)
-		if ok && !reflect.DeepEqual(orig, v) {
-			changes[k] = v
-		}
 		c.keyMap.Store(k, v)
 	}
 
-	if len(changes) > 0 {
-		c.notifyChanges(changes)
+	for key, leaves := range watched {
+		if !reflect.DeepEqual(leaves, c.leaves(key)) {
+			for _, w := range c.watchers[key] {
+				go w.fn(c)
+			}
+		}
 	}
 
 	return nil
 }
 
-func (c *Configuration) notifyChanges(changes map[string]interface{}) {
-	var changedWatchPrefixMap = map[string]struct{}{}
+// replace 用数据源src中key的配置替换合并后的配置，src不包含key的上一级配置时不处理
+func (c *Configuration) replace(key string, src map[string]interface{}) {
+	paths := strings.Split(key, c.keyDelim)
+	parent, ok := subMap(src, paths[:len(paths)-1])
+	if !ok {
+		return
+	}
+
+	last := paths[len(paths)-1]
+	dest := deepSearch(c.override, paths[:len(paths)-1])
+	if v, ok := parent[last]; ok {
+		dest[last] = v
+	} else {
+		delete(dest, last)
+	}
 
-	for watchPrefix := range c.watchers {
-		for key := range changes {
-			// 前缀匹配即可
-			// todo 可能产生错误匹配
-			if strings.HasPrefix(key, watchPrefix) {
-				changedWatchPrefixMap[watchPrefix] = struct{}{}
-			}
+	// 删除key下缓存的配置，包括已经删除的配置项
+	c.keyMap.Range(func(k, _ interface{}) bool {
+		if k == key || strings.HasPrefix(k.(string), key+c.keyDelim) {
+			c.keyMap.Delete(k)
 		}
+		return true
+	})
+}
+
+// leaves 返回key下的所有配置项，c.mu must be held
+func (c *Configuration) leaves(key string) map[string]interface{} {
+	data := make(map[string]interface{})
+	v, ok := subValue(c.override, strings.Split(key, c.keyDelim))
+	if !ok {
+		return data
 	}
+	if m, err := cast.ToStringMapE(v); err == nil {
+		lookup(key, m, data, c.keyDelim)
+	} else {
+		data[key] = v
+	}
+	return data
+}
 
-	for changedWatchPrefix := range changedWatchPrefixMap {
-		for _, handle := range c.watchers[changedWatchPrefix] {
-			go handle(c)
+// subValue returns the value of m at path without modifying m
+func subValue(m map[string]interface{}, path []string) (interface{}, bool) {
+	var v interface{} = m
+	var ok bool
+	for _, k := range path {
+		mm, err := cast.ToStringMapE(v)
+		if err != nil {
+			return nil, false
+		}
+		if v, ok = mm[k]; !ok {
+			return nil, false
 		}
 	}
+	return v, true
+}
+
+func subMap(m map[string]interface{}, path []string) (map[string]interface{}, bool) {
+	v, ok := subValue(m, path)
+	if !ok {
+		return nil, false
+	}
+	mm, err := cast.ToStringMapE(v)
+	return mm, err == nil
 }
 
 // Set ...
@@ -415,13 +497,17 @@ func (c *Configuration) UnmarshalKey(key string, rawVal interface{}, opts ...Get
 	if err != nil {
 		return err
 	}
+	// value may be a sub map of override, which is merged in place by apply
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	if key == "" {
-		c.mu.RLock()
-		defer c.mu.RUnlock()
 		return decoder.Decode(c.override)
 	}
 
-	value := c.Get(key)
+	value, ok := c.keyMap.Load(key)
+	if !ok {
+		value = c.search(key)
+	}
 	if value == nil {
 		return errors.Wrap(ErrInvalidKey, key)
 	}
@@ -435,11 +521,16 @@ func (c *Configuration) find(key string) interface{} {
 		return dd
 	}
 
-	paths := strings.Split(key, c.keyDelim)
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	return c.search(key)
+}
+
+// search looks up key in override and caches it, c.mu must be held
+func (c *Configuration) search(key string) interface{} {
+	paths := strings.Split(key, c.keyDelim)
 	m := xmap.DeepSearchInMap(c.override, paths[:len(paths)-1]...)
-	dd = m[paths[len(paths)-1]]
+	dd := m[paths[len(paths)-1]]
 	c.keyMap.Store(key, dd)
 	return dd
 }
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -115,3 +116,76 @@ func TestGetInt64Slice(t *testing.T) {
 		})
 	}
 }
+
+func TestWatch(t *testing.T) {
+	c := New()
+	load := func(content string) {
+		if err := c.Load([]byte(content), toml.Unmarshal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	load(`
+	[server]
+		port = 80
+	[server.methods."/a"]
+		timeout = "1s"
+	`)
+
+	notified := make(chan struct{}, 10)
+	unwatch := c.Watch("server.methods", func(*Configuration) { notified <- struct{}{} })
+	expect := func(name string, want bool) {
+		select {
+		case <-notified:
+			if !want {
+				t.Fatal(name, ": unexpected notification")
+			}
+		case <-time.After(100 * time.Millisecond):
+			if want {
+				t.Fatal(name, ": not notified")
+			}
+		}
+	}
+
+	load(`
+	[server]
+		port = 81
+	[server.methods."/a"]
+		timeout = "1s"
+	`)
+	expect("change outside", false)
+
+	load(`
+	[server]
+		port = 81
+	[server.methods."/a"]
+		timeout = "1s"
+	[server.methods."/b/*"]
+		timeout = "2s"
+	`)
+	expect("add", true)
+	if c.GetString("server.methods./b/*.timeout") != "2s" {
+		t.Fatal("added entry not loaded")
+	}
+
+	load(`
+	[server]
+		port = 81
+	[server.methods."/b/*"]
+		timeout = "3s"
+	`)
+	expect("change and remove", true)
+	var methods map[string]map[string]string
+	if err := c.UnmarshalKey("server.methods", &methods); err != nil || !reflect.DeepEqual(methods, map[string]map[string]string{"/b/*": {"timeout": "3s"}}) {
+		t.Fatal("unexpected methods", methods, err)
+	}
+	if c.Get("server.methods./a.timeout") != nil {
+		t.Fatal("removed entry not dropped")
+	}
+
+	unwatch()
+	load(`
+	[server]
+		port = 81
+	`)
+	expect("unwatched", false)
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	//go-lint
@@ -25,6 +26,7 @@ import (
 	_ "github.com/douyu/jupiter/pkg/conf/datasource/file"
 	_ "github.com/douyu/jupiter/pkg/conf/datasource/http"
 	_ "github.com/douyu/jupiter/pkg/core/autoproc"
+	_ "github.com/douyu/jupiter/pkg/core/profiling"
 	_ "github.com/douyu/jupiter/pkg/core/rocketmq"
 	_ "github.com/douyu/jupiter/pkg/core/xgrpclog"
 	_ "github.com/douyu/jupiter/pkg/registry/etcdv3"
@@ -33,12 +35,14 @@ import (
 	"github.com/douyu/jupiter/pkg/conf"
 	"github.com/douyu/jupiter/pkg/core/component"
 	"github.com/douyu/jupiter/pkg/core/ecode"
+	"github.com/douyu/jupiter/pkg/core/graceful"
 	"github.com/douyu/jupiter/pkg/core/hooks"
 	"github.com/douyu/jupiter/pkg/core/signals"
 	"github.com/douyu/jupiter/pkg/executor"
 	"github.com/douyu/jupiter/pkg/flag"
 	"github.com/douyu/jupiter/pkg/registry"
 	"github.com/douyu/jupiter/pkg/server"
+	"github.com/douyu/jupiter/pkg/server/governor"
 	"github.com/douyu/jupiter/pkg/util/xcycle"
 	"github.com/douyu/jupiter/pkg/util/xdebug"
 	"github.com/douyu/jupiter/pkg/util/xgo"
@@ -67,6 +71,8 @@ type Application struct {
 	HideBanner   bool
 	stopped      chan struct{}
 	components   []component.Component
+	// upgraded 新进程已经接管listener，退出时不注销服务
+	upgraded atomic.Bool
 }
 
 // New create a new Application instance
@@ -175,6 +181,7 @@ func (app *Application) Serve(s ...server.Server) error {
 	app.smu.Lock()
 	defer app.smu.Unlock()
 	app.servers = append(app.servers, s...)
+	governor.AddServers(s...)
 	return nil
 }
 
@@ -234,6 +241,7 @@ func (app *Application) Run(servers ...server.Server) error {
 	app.smu.Lock()
 	app.servers = append(app.servers, servers...)
 	app.smu.Unlock()
+	governor.AddServers(servers...)
 
 	hooks.Do(hooks.Stage_BeforeRun)
 
@@ -245,6 +253,10 @@ func (app *Application) Run(servers ...server.Server) error {
 
 	// start servers and govern server
 	app.cycle.Run(app.startServers)
+	// 由父进程升级启动时，通知父进程退出
+	if err := graceful.Ready(); err != nil {
+		app.logger.Error("notify parent ready", xlog.FieldMod(ecode.ModApp), xlog.FieldErr(err))
+	}
 	// start workers
 	app.cycle.Run(app.startWorkers)
 	// start executors
@@ -274,9 +286,11 @@ func (app *Application) Stop() (err error) {
 			func(s server.Server) {
 				app.smu.RLock()
 				// unregister before stop
-				e := registry.DefaultRegisterer.UnregisterService(context.Background(), s.Info())
-				if e != nil {
-					app.logger.Error("exit server", xlog.FieldMod(ecode.ModApp), xlog.FieldEvent("stop"), xlog.FieldName(s.Info().Name), xlog.FieldAddr(s.Info().Label()), xlog.FieldErr(err))
+				if !s.Info().Local() {
+					e := registry.DefaultRegisterer.UnregisterService(context.Background(), s.Info())
+					if e != nil {
+						app.logger.Error("exit server", xlog.FieldMod(ecode.ModApp), xlog.FieldEvent("stop"), xlog.FieldName(s.Info().Name), xlog.FieldAddr(s.Info().Label()), xlog.FieldErr(err))
+					}
 				}
 				app.logger.Info("exit server", xlog.FieldMod(ecode.ModApp), xlog.FieldEvent("stop"), xlog.FieldName(s.Info().Name), xlog.FieldAddr(s.Info().Label()))
 
@@ -311,10 +325,13 @@ func (app *Application) GracefulStop(ctx context.Context) (err error) {
 				app.cycle.Run(func() error {
 					app.smu.RLock()
 					defer app.smu.RUnlock()
-					// unregister before graceful stop
-					e := registry.DefaultRegisterer.UnregisterService(ctx, s.Info())
-					if e != nil {
-						app.logger.Error("exit server", xlog.FieldMod(ecode.ModApp), xlog.FieldEvent("graceful stop"), xlog.FieldName(s.Info().Name), xlog.FieldAddr(s.Info().Label()), xlog.FieldErr(err))
+					// unregister before graceful stop, the new process registers
+					// the same address after upgrade
+					if !app.upgraded.Load() && !s.Info().Local() {
+						e := registry.DefaultRegisterer.UnregisterService(ctx, s.Info())
+						if e != nil {
+							app.logger.Error("exit server", xlog.FieldMod(ecode.ModApp), xlog.FieldEvent("graceful stop"), xlog.FieldName(s.Info().Name), xlog.FieldAddr(s.Info().Label()), xlog.FieldErr(err))
+						}
 					}
 					app.logger.Info("exit server", xlog.FieldMod(ecode.ModApp), xlog.FieldEvent("graceful stop"), xlog.FieldName(s.Info().Name), xlog.FieldAddr(s.Info().Label()))
 
@@ -350,6 +367,25 @@ func (app *Application) waitSignals() {
 			_ = app.Stop()
 		}
 	})
+	signals.Upgrade(app.upgrade)
+}
+
+// upgrade 启动新的进程接管listener，新进程就绪后优雅退出
+func (app *Application) upgrade() {
+	app.logger.Info("upgrade", xlog.FieldMod(ecode.ModApp), xlog.FieldEvent("upgrade"))
+	config := graceful.StdConfig()
+	ctx, cancel := context.WithTimeout(context.Background(), config.ReadyTimeout)
+	defer cancel()
+	if err := graceful.Upgrade(ctx); err != nil {
+		app.logger.Error("upgrade", xlog.FieldMod(ecode.ModApp), xlog.FieldEvent("upgrade"), xlog.FieldErr(err))
+		return
+	}
+
+	app.logger.Info("upgrade success, graceful stop", xlog.FieldMod(ecode.ModApp), xlog.FieldEvent("upgrade"))
+	app.upgraded.Store(true)
+	ctx, cancel = context.WithTimeout(context.Background(), config.DrainTimeout)
+	defer cancel()
+	_ = app.GracefulStop(ctx)
 }
 
 func (app *Application) startServers() error {
@@ -365,7 +401,10 @@ func (app *Application) startServers() error {
 		s := s
 		eg.Go(func() (err error) {
 			time.AfterFunc(time.Second, func() {
-				_ = registry.DefaultRegisterer.RegisterService(ctx, s.Info())
+				// unix socket的服务只能在本机访问，不注册
+				if !s.Info().Local() {
+					_ = registry.DefaultRegisterer.RegisterService(ctx, s.Info())
+				}
 				app.logger.Info("start server", xlog.FieldMod(ecode.ModApp), xlog.FieldEvent("init"), xlog.FieldName(s.Info().Name), xlog.FieldAddr(s.Info().Label()), xlog.Any("scheme", s.Info().Scheme))
 			})
 			err = s.Serve()
@@ -0,0 +1,62 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graceful
+
+import (
+	"time"
+
+	"github.com/douyu/jupiter/pkg/conf"
+	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/xlog"
+)
+
+// ModName ..
+const ModName = "upgrade"
+
+// Config 平滑升级的配置
+type Config struct {
+	// ReadyTimeout 等待新进程就绪的时间，超时后结束新进程，旧进程继续提供服务
+	ReadyTimeout time.Duration
+	// DrainTimeout 新进程就绪后，旧进程处理正在进行的请求的时间
+	DrainTimeout time.Duration
+}
+
+// StdConfig ...
+func StdConfig() *Config {
+	return RawConfig(constant.ConfigKey(ModName))
+}
+
+// RawConfig ...
+func RawConfig(key string) *Config {
+	var config = DefaultConfig()
+	if conf.Get(key) == nil {
+		return config
+	}
+	if err := conf.UnmarshalKey(key, config); err != nil {
+		xlog.Jupiter().Panic("upgrade parse config panic",
+			xlog.FieldErr(err), xlog.FieldKey(key),
+			xlog.FieldValueAny(config),
+		)
+	}
+	return config
+}
+
+// DefaultConfig ...
+func DefaultConfig() *Config {
+	return &Config{
+		ReadyTimeout: time.Minute,
+		DrainTimeout: 3 * time.Second,
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graceful 管理服务的listener，支持不中断服务的进程升级：
+// 收到升级信号后启动新的进程，并通过文件描述符传递所有的listener，
+// 新进程就绪后旧进程停止接收请求并优雅退出
+package graceful
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// envListeners 父进程传递的listener，按顺序对应从 fdListeners 开始的文件描述符
+	envListeners = "JUPITER_GRACEFUL_LISTENERS"
+	// fdReady 通知父进程就绪的管道
+	fdReady = 3
+	// fdListeners 第一个listener的文件描述符
+	fdListeners = 4
+)
+
+var (
+	// ErrNotSupported 当前平台不支持升级
+	ErrNotSupported = errors.New("graceful: upgrade is not supported")
+	// ErrUpgrading 正在升级或已经升级
+	ErrUpgrading = errors.New("graceful: upgrade in progress or completed")
+)
+
+// listenerKey 按Listen的参数匹配父进程传递的listener
+type listenerKey struct {
+	Network string `json:"network"`
+	Address string `json:"address"`
+}
+
+// fileListener 可以取得文件描述符的listener，如 *net.TCPListener 和 *net.UnixListener
+type fileListener interface {
+	net.Listener
+	File() (*os.File, error)
+}
+
+// Manager 管理进程中的listener
+type Manager struct {
+	mu        sync.Mutex
+	inherited map[listenerKey][]net.Listener
+	active    []*listener
+	ready     *os.File
+	upgrading bool
+
+	// args 新进程的参数，默认为当前进程的参数
+	args []string
+}
+
+var defaultManager = newManager(os.Getenv(envListeners), func(fd uintptr) *os.File {
+	return os.NewFile(fd, fmt.Sprintf("graceful-%d", fd))
+})
+
+// newManager 解析父进程传递的listener，file 根据文件描述符打开文件
+func newManager(env string, file func(fd uintptr) *os.File) *Manager {
+	m := &Manager{
+		inherited: make(map[listenerKey][]net.Listener),
+		args:      os.Args[1:],
+	}
+	if env == "" {
+		return m
+	}
+	_ = os.Unsetenv(envListeners)
+
+	var keys []listenerKey
+	if err := json.Unmarshal([]byte(env), &keys); err != nil {
+		return m
+	}
+	m.ready = file(fdReady)
+	for i, key := range keys {
+		f := file(uintptr(fdListeners + i))
+		if f == nil {
+			continue
+		}
+		l, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			continue
+		}
+		m.inherited[key] = append(m.inherited[key], l)
+	}
+	return m
+}
+
+// Listen 优先使用父进程传递的listener，否则创建新的listener
+func Listen(network, address string) (net.Listener, error) {
+	return defaultManager.Listen(network, address)
+}
+
+// Upgrade 启动新的进程并传递所有的listener，新进程就绪后返回nil，调用方随后优雅退出；
+// 新进程启动失败、退出或者ctx超时时返回错误，当前进程继续服务
+func Upgrade(ctx context.Context) error {
+	return defaultManager.Upgrade(ctx)
+}
+
+// Ready 服务启动后通知父进程退出，并关闭没有使用的listener
+func Ready() error {
+	return defaultManager.Ready()
+}
+
+// HasParent 是否由父进程升级启动
+func HasParent() bool {
+	return defaultManager.HasParent()
+}
+
+// Listen ...
+func (m *Manager) Listen(network, address string) (net.Listener, error) {
+	key := listenerKey{Network: network, Address: address}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var l net.Listener
+	if list := m.inherited[key]; len(list) > 0 {
+		l, m.inherited[key] = list[0], list[1:]
+		// 继承的unix socket默认关闭时不删除文件，由当前进程负责清理
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(true)
+		}
+	} else {
+		if network == "unix" {
+			removeStale(address)
+		}
+		var err error
+		if l, err = net.Listen(network, address); err != nil {
+			return nil, err
+		}
+	}
+
+	ln := &listener{Listener: l, key: key, manager: m}
+	m.active = append(m.active, ln)
+	return ln, nil
+}
+
+// Ready ...
+func (m *Manager) Ready() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, list := range m.inherited {
+		for _, l := range list {
+			_ = l.Close()
+		}
+		delete(m.inherited, key)
+	}
+	if m.ready == nil {
+		return nil
+	}
+	defer func() {
+		_ = m.ready.Close()
+		m.ready = nil
+	}()
+	_, err := m.ready.Write([]byte{1})
+	return err
+}
+
+// HasParent ...
+func (m *Manager) HasParent() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ready != nil
+}
+
+// files 返回传递给新进程的listener和文件描述符，调用方负责关闭文件
+func (m *Manager) files() ([]listenerKey, []*os.File, error) {
+	keys := make([]listenerKey, 0, len(m.active))
+	files := make([]*os.File, 0, len(m.active))
+	for _, ln := range m.active {
+		fl, ok := ln.Listener.(fileListener)
+		if !ok {
+			closeFiles(files)
+			return nil, nil, fmt.Errorf("graceful: listener %s %s can not be passed", ln.key.Network, ln.key.Address)
+		}
+		f, err := fl.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, err
+		}
+		keys = append(keys, ln.key)
+		files = append(files, f)
+	}
+	return keys, files, nil
+}
+
+func (m *Manager) remove(ln *listener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, l := range m.active {
+		if l == ln {
+			m.active = append(m.active[:i], m.active[i+1:]...)
+			return
+		}
+	}
+}
+
+// removeStale 删除没有进程监听的socket文件，如进程异常退出时遗留的文件
+func removeStale(address string) {
+	fi, err := os.Lstat(address)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if conn, err := net.DialTimeout("unix", address, time.Second); err == nil {
+		_ = conn.Close()
+		return
+	}
+	_ = os.Remove(address)
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+// listener 关闭后不再传递给新进程
+type listener struct {
+	net.Listener
+	key     listenerKey
+	manager *Manager
+	once    sync.Once
+}
+
+// Close ...
+func (ln *listener) Close() error {
+	ln.once.Do(func() {
+		ln.manager.remove(ln)
+	})
+	return ln.Listener.Close()
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graceful
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/douyu/jupiter/pkg/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdConfig(t *testing.T) {
+	assert.Nil(t, conf.LoadFromReader(bytes.NewBufferString(`
+[jupiter.upgrade]
+	readyTimeout = "10s"
+`), toml.Unmarshal))
+	config := StdConfig()
+	assert.Equal(t, 10*time.Second, config.ReadyTimeout)
+	assert.Equal(t, 3*time.Second, config.DrainTimeout)
+}
+
+func TestInherit(t *testing.T) {
+	parent := newManager("", nil)
+	l, err := parent.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	closed, err := parent.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	assert.Nil(t, closed.Close())
+
+	// 关闭的listener不再传递
+	keys, files, err := parent.files()
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	defer closeFiles(files)
+	env, _ := json.Marshal(keys)
+
+	readyR, readyW, err := os.Pipe()
+	assert.Nil(t, err)
+	defer readyR.Close()
+	child := newManager(string(env), func(fd uintptr) *os.File {
+		if fd == fdReady {
+			return readyW
+		}
+		return files[fd-fdListeners]
+	})
+	assert.True(t, child.HasParent())
+
+	inherited, err := child.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer inherited.Close()
+	assert.Equal(t, l.Addr().String(), inherited.Addr().String())
+	another, err := child.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer another.Close()
+	assert.NotEqual(t, l.Addr().String(), another.Addr().String())
+
+	assert.Nil(t, child.Ready())
+	assert.False(t, child.HasParent())
+	b := make([]byte, 1)
+	_, err = readyR.Read(b)
+	assert.Nil(t, err)
+}
+
+func TestUpgrade(t *testing.T) {
+	m := newManager("", nil)
+	m.args = []string{"-test.run=^TestHelperProcess$"}
+	l, err := m.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		_ = http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "parent")
+		}))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.Nil(t, m.Upgrade(ctx))
+	assert.Equal(t, ErrUpgrading, m.Upgrade(ctx))
+	assert.Nil(t, l.Close())
+
+	// 旧进程关闭listener后，请求由新进程处理
+	resp, err := http.Get("http://" + l.Addr().String())
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "child", string(body))
+}
+
+// TestHelperProcess 由TestUpgrade启动的新进程
+func TestHelperProcess(t *testing.T) {
+	if !HasParent() {
+		t.Skip("not started by upgrade")
+	}
+
+	l, err := Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	served := make(chan struct{}, 1)
+	go func() {
+		_ = http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "child")
+			served <- struct{}{}
+		}))
+	}()
+	assert.Nil(t, Ready())
+
+	select {
+	case <-served:
+	case <-time.After(10 * time.Second):
+	}
+	_ = l.Close()
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package graceful
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Upgrade ...
+func (m *Manager) Upgrade(ctx context.Context) (err error) {
+	m.mu.Lock()
+	if m.upgrading {
+		m.mu.Unlock()
+		return ErrUpgrading
+	}
+	m.upgrading = true
+	keys, files, err := m.files()
+	m.mu.Unlock()
+
+	defer func() {
+		if err != nil {
+			m.mu.Lock()
+			m.upgrading = false
+			m.mu.Unlock()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	defer closeFiles(files)
+
+	env, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+
+	// 直接传递文件描述符启动新进程：os/exec 会调用 File.Fd() 把socket切换为阻塞模式，
+	// 导致当前进程正在进行的Accept无法被Close打断
+	fds := []uintptr{uintptr(syscall.Stdin), uintptr(syscall.Stdout), uintptr(syscall.Stderr), readyW.Fd()}
+	for _, f := range files {
+		fd, err := rawFd(f)
+		if err != nil {
+			_ = readyW.Close()
+			return err
+		}
+		fds = append(fds, fd)
+	}
+	pid, err := syscall.ForkExec(exe, append([]string{os.Args[0]}, m.args...), &syscall.ProcAttr{
+		Env:   append(environ(), envListeners+"="+string(env)),
+		Files: fds,
+	})
+	_ = readyW.Close()
+	if err != nil {
+		return err
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		state, err := process.Wait()
+		if err == nil {
+			err = errors.New(state.String())
+		}
+		exited <- err
+	}()
+	readied := make(chan error, 1)
+	go func() {
+		_, err := readyR.Read(make([]byte, 1))
+		readied <- err
+	}()
+
+	select {
+	case err = <-readied:
+		if err != nil {
+			_ = process.Kill()
+			return fmt.Errorf("graceful: process %d exited before ready: %w", pid, err)
+		}
+	case err = <-exited:
+		return fmt.Errorf("graceful: process %d exited before ready: %v", pid, err)
+	case <-ctx.Done():
+		_ = process.Kill()
+		return ctx.Err()
+	}
+
+	// 新进程使用同一个socket文件，关闭时不删除
+	m.mu.Lock()
+	for _, ln := range m.active {
+		if ul, ok := ln.Listener.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// environ 当前进程的环境变量，去掉父进程传递的listener
+func environ() []string {
+	env := make([]string, 0)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envListeners+"=") {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// rawFd 取得文件描述符，不改变其阻塞模式
+func rawFd(f *os.File) (fd uintptr, err error) {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	if cerr := rc.Control(func(v uintptr) { fd = v }); cerr != nil {
+		return 0, cerr
+	}
+	return fd, nil
+}
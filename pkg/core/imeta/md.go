@@ -5,6 +5,9 @@ import (
 	"strings"
 )
 
+// KeyShadow 压测和影子流量的标记，值为1
+const KeyShadow = "x-jupiter-shadow"
+
 // MD is a mapping from metadata keys to values. Users should use the following
 // two convenience functions New and Pairs to generate MD.
 type MD map[string][]string
@@ -95,7 +98,7 @@ func (md MD) Append(k string, vals ...string) {
 
 // IsShadow MD中是否包含压测标记
 func (md MD) IsShadow() bool {
-	if values, ok := md["x-jupiter-shadow"]; ok {
+	if values, ok := md[KeyShadow]; ok {
 		for _, value := range values {
 			if value == "1" {
 				return true
@@ -0,0 +1,171 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inspect keeps track of every constructed client, so that their
+// config and live stats can be enumerated by governor and exported as metrics.
+package inspect
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Stats is a snapshot of live client stats. Top level numeric values are
+// exported through metric.ClientStatsGauge with the key as index label.
+type Stats map[string]interface{}
+
+// Info ...
+type Info struct {
+	Type   string      `json:"type"`
+	Name   string      `json:"name"`
+	Config interface{} `json:"config"`
+	Stats  Stats       `json:"stats,omitempty"`
+}
+
+type entry struct {
+	typ    string
+	name   string
+	config interface{}
+	stats  func() Stats
+}
+
+var (
+	mu      sync.RWMutex
+	entries = make(map[string]*entry)
+
+	// exportMu keeps export from setting the series of a client which is
+	// being unregistered.
+	exportMu sync.Mutex
+)
+
+func init() {
+	go monitor()
+}
+
+func genkey(typ, name string) string {
+	return typ + "/" + name
+}
+
+// Register records a constructed client. stats may be nil for clients
+// without live stats. Registering the same type and name again replaces
+// the previous record.
+func Register(typ, name string, config interface{}, stats func() Stats) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	entries[genkey(typ, name)] = &entry{
+		typ:    typ,
+		name:   name,
+		config: config,
+		stats:  stats,
+	}
+}
+
+// Unregister removes a client record, e.g. after the client is closed,
+// along with its series of metric.ClientStatsGauge.
+func Unregister(typ, name string) {
+	mu.Lock()
+	delete(entries, genkey(typ, name))
+	mu.Unlock()
+
+	exportMu.Lock()
+	defer exportMu.Unlock()
+	metric.ClientStatsGauge.DeletePartialMatch(prometheus.Labels{"type": typ, "name": name})
+}
+
+// List returns every registered client with redacted config and live stats,
+// sorted by type and name.
+func List() []Info {
+	mu.RLock()
+	list := make([]*entry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].typ != list[j].typ {
+			return list[i].typ < list[j].typ
+		}
+		return list[i].name < list[j].name
+	})
+
+	infos := make([]Info, 0, len(list))
+	for _, e := range list {
+		info := Info{
+			Type:   e.typ,
+			Name:   e.name,
+			Config: Redact(e.config),
+		}
+		if e.stats != nil {
+			info.Stats = e.stats()
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func monitor() {
+	for {
+		export()
+		time.Sleep(time.Second * 10)
+	}
+}
+
+// export sets numeric stats to metric.ClientStatsGauge.
+func export() {
+	exportMu.Lock()
+	defer exportMu.Unlock()
+
+	for _, info := range List() {
+		for index, val := range info.Stats {
+			if v, ok := toFloat64(val); ok {
+				metric.ClientStatsGauge.Set(v, info.Type, info.Name, index)
+			}
+		}
+	}
+}
+
+func toFloat64(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case time.Duration:
+		return v.Seconds(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package limiter
+
+import "time"
+
+// Config 自适应限流配置
+type Config struct {
+	// Enable 开启自适应限流，默认false
+	Enable bool
+	// Window 统计通过数和RT的滑动窗口，默认10s
+	Window time.Duration
+	// Buckets 窗口的桶数，默认100
+	Buckets int
+	// CPUThreshold CPU使用率(千分比)达到阈值后开始限流，默认800
+	CPUThreshold int64
+	// CoolDown 限流后的冷却时间，冷却期内即使CPU低于阈值，并发超过容量时也会拒绝，默认1s
+	CoolDown time.Duration
+	// PriorityKey 请求优先级的header或metadata，取值 critical、sheddable，默认 x-criticality
+	PriorityKey string
+}
+
+// DefaultConfig ...
+func DefaultConfig() Config {
+	return Config{
+		Window:       10 * time.Second,
+		Buckets:      100,
+		CPUThreshold: 800,
+		CoolDown:     time.Second,
+		PriorityKey:  "x-criticality",
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package limiter
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/douyu/jupiter/pkg/xlog"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const (
+	sampleInterval = 250 * time.Millisecond
+	// cpu使用率的滑动平均系数
+	decay = 0.95
+)
+
+var (
+	cpu         int64
+	samplerOnce sync.Once
+)
+
+// cpuUsage 进程的CPU使用率(千分比)，按GOMAXPROCS归一化
+func cpuUsage() int64 {
+	return atomic.LoadInt64(&cpu)
+}
+
+// startSampler 启动CPU采样，同时定时上报限流器的状态
+func startSampler() {
+	samplerOnce.Do(func() {
+		proc, err := process.NewProcess(int32(os.Getpid()))
+		if err != nil {
+			xlog.Jupiter().Error("limiter cpu sampler", xlog.FieldErr(err))
+			return
+		}
+
+		go func() {
+			ticker := time.NewTicker(sampleInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				sample(proc)
+				report()
+			}
+		}()
+	})
+}
+
+func sample(proc *process.Process) {
+	// 距离上次调用的CPU使用率，多核时可能超过100
+	percent, err := proc.Percent(0)
+	if err != nil {
+		return
+	}
+	usage := int64(percent * 10 / float64(runtime.GOMAXPROCS(0)))
+	prev := atomic.LoadInt64(&cpu)
+	atomic.StoreInt64(&cpu, int64(float64(prev)*decay+float64(usage)*(1-decay)))
+}
+
+func report() {
+	limiters.Range(func(_, value interface{}) bool {
+		stat := value.(*Limiter).Stat()
+		metric.ServerLimiterGauge.Set(float64(stat.CPU), stat.Name, "cpu")
+		metric.ServerLimiterGauge.Set(float64(stat.InFlight), stat.Name, "inflight")
+		metric.ServerLimiterGauge.Set(float64(stat.MaxInFlight), stat.Name, "max_inflight")
+		return true
+	})
+}
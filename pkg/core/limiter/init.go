@@ -0,0 +1,29 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package limiter
+
+import (
+	"net/http"
+
+	"github.com/douyu/jupiter/pkg/server/governor"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func init() {
+	// 列出全部自适应限流器的状态
+	governor.HandleFunc("/debug/limiter", func(w http.ResponseWriter, r *http.Request) {
+		_ = jsoniter.NewEncoder(w).Encode(Limiters())
+	})
+}
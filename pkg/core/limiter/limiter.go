@@ -0,0 +1,298 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package limiter 实现BBR风格的自适应限流:
+// 根据窗口内每个桶的最大通过数和最小RT估算服务容量 maxInFlight = maxPass * minRT / bucket，
+// CPU使用率超过阈值(或处于限流后的冷却期)且并发超过容量时拒绝请求。
+package limiter
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/metric"
+)
+
+// ErrLimitExceed 请求被自适应限流拒绝
+var ErrLimitExceed = errors.New("limiter: limit exceed")
+
+// Priority 请求优先级，容量不足时低优先级的请求先被拒绝
+type Priority int
+
+const (
+	// PriorityDefault 并发达到容量时拒绝
+	PriorityDefault Priority = iota
+	// PriorityCritical 并发达到容量的125%时拒绝
+	PriorityCritical
+	// PrioritySheddable 并发达到容量的75%时拒绝
+	PrioritySheddable
+)
+
+// ParsePriority 解析header或metadata中的优先级，未知取值为PriorityDefault
+func ParsePriority(value string) Priority {
+	switch strings.ToLower(value) {
+	case "critical":
+		return PriorityCritical
+	case "sheddable":
+		return PrioritySheddable
+	}
+	return PriorityDefault
+}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityCritical:
+		return "critical"
+	case PrioritySheddable:
+		return "sheddable"
+	}
+	return "default"
+}
+
+func (p Priority) factor() float64 {
+	switch p {
+	case PriorityCritical:
+		return 1.25
+	case PrioritySheddable:
+		return 0.75
+	}
+	return 1
+}
+
+// Stat 限流器状态
+type Stat struct {
+	Name        string  `json:"name"`
+	CPU         int64   `json:"cpu"`
+	InFlight    int64   `json:"inFlight"`
+	MaxInFlight int64   `json:"maxInFlight"`
+	MaxPass     int64   `json:"maxPass"`
+	MinRT       float64 `json:"minRT"` // 毫秒
+	Shedding    bool    `json:"shedding"`
+}
+
+// Limiter 自适应限流器
+type Limiter struct {
+	name   string
+	config Config
+
+	inFlight int64
+	prevDrop int64 // 上次拒绝请求的时间，UnixNano
+
+	window *window
+
+	// 测试时替换
+	now func() time.Time
+	cpu func() int64
+}
+
+var limiters sync.Map
+
+// New 创建限流器，同名的限流器会被替换，governor /debug/limiter 列出全部限流器的状态
+func New(name string, config Config) *Limiter {
+	l := newLimiter(name, config)
+	limiters.Store(name, l)
+	startSampler()
+	return l
+}
+
+func newLimiter(name string, config Config) *Limiter {
+	def := DefaultConfig()
+	if config.Window <= 0 {
+		config.Window = def.Window
+	}
+	if config.Buckets <= 0 {
+		config.Buckets = def.Buckets
+	}
+	if config.CPUThreshold <= 0 {
+		config.CPUThreshold = def.CPUThreshold
+	}
+	if config.CoolDown <= 0 {
+		config.CoolDown = def.CoolDown
+	}
+	if config.PriorityKey == "" {
+		config.PriorityKey = def.PriorityKey
+	}
+
+	l := &Limiter{
+		name:   name,
+		config: config,
+		now:    time.Now,
+		cpu:    cpuUsage,
+	}
+	l.window = newWindow(config.Window/time.Duration(config.Buckets), config.Buckets, l.now())
+	return l
+}
+
+// Limiters 返回全部限流器的状态
+func Limiters() []Stat {
+	stats := make([]Stat, 0)
+	limiters.Range(func(_, value interface{}) bool {
+		stats = append(stats, value.(*Limiter).Stat())
+		return true
+	})
+	return stats
+}
+
+// PriorityKey 请求优先级的header或metadata
+func (l *Limiter) PriorityKey() string {
+	return l.config.PriorityKey
+}
+
+// Allow 判断请求能否通过，通过时返回的done必须在请求结束后调用
+func (l *Limiter) Allow(priority Priority) (done func(), err error) {
+	if l.shouldDrop(priority) {
+		metric.ServerShedCounter.Inc(l.name, priority.String())
+		return nil, ErrLimitExceed
+	}
+
+	atomic.AddInt64(&l.inFlight, 1)
+	start := l.now()
+	return func() {
+		now := l.now()
+		atomic.AddInt64(&l.inFlight, -1)
+		l.window.add(now, now.Sub(start))
+	}, nil
+}
+
+// Stat 返回限流器当前的状态
+func (l *Limiter) Stat() Stat {
+	now := l.now()
+	maxPass, minRT := l.window.stat(now)
+	return Stat{
+		Name:        l.name,
+		CPU:         l.cpu(),
+		InFlight:    atomic.LoadInt64(&l.inFlight),
+		MaxInFlight: l.maxInFlight(maxPass, minRT),
+		MaxPass:     maxPass,
+		MinRT:       float64(minRT) / float64(time.Millisecond),
+		Shedding:    l.cooling(now),
+	}
+}
+
+func (l *Limiter) shouldDrop(priority Priority) bool {
+	now := l.now()
+	if l.cpu() < l.config.CPUThreshold {
+		// 刚拒绝过请求，说明负载仍然较高，冷却期内继续按容量限流
+		return l.cooling(now) && l.overloaded(now, priority)
+	}
+
+	if l.overloaded(now, priority) {
+		atomic.StoreInt64(&l.prevDrop, now.UnixNano())
+		return true
+	}
+	return false
+}
+
+func (l *Limiter) cooling(now time.Time) bool {
+	prev := atomic.LoadInt64(&l.prevDrop)
+	return prev != 0 && now.Sub(time.Unix(0, prev)) <= l.config.CoolDown
+}
+
+func (l *Limiter) overloaded(now time.Time, priority Priority) bool {
+	inFlight := atomic.LoadInt64(&l.inFlight)
+	if inFlight <= 1 {
+		return false
+	}
+
+	maxInFlight := l.maxInFlight(l.window.stat(now))
+	// 窗口内还没有完成的请求，无法估算容量
+	if maxInFlight <= 0 {
+		return false
+	}
+	return float64(inFlight) >= float64(maxInFlight)*priority.factor()
+}
+
+func (l *Limiter) maxInFlight(maxPass int64, minRT time.Duration) int64 {
+	if maxPass == 0 || minRT == 0 {
+		return 0
+	}
+	return int64(math.Ceil(float64(maxPass) * float64(minRT) / float64(l.window.size)))
+}
+
+type bucket struct {
+	pass  int64
+	rtSum time.Duration
+}
+
+// window 滑动窗口，记录每个桶内完成的请求数和RT
+type window struct {
+	mu      sync.Mutex
+	size    time.Duration
+	buckets []bucket
+	offset  int
+	start   time.Time // 当前桶的开始时间
+}
+
+func newWindow(size time.Duration, n int, now time.Time) *window {
+	if size <= 0 {
+		size = time.Millisecond
+	}
+	return &window{
+		size:    size,
+		buckets: make([]bucket, n),
+		start:   now,
+	}
+}
+
+func (w *window) add(now time.Time, rt time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rotate(now)
+	w.buckets[w.offset].pass++
+	w.buckets[w.offset].rtSum += rt
+}
+
+// stat 返回除当前桶之外的最大通过数和最小平均RT
+func (w *window) stat(now time.Time) (maxPass int64, minRT time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rotate(now)
+	for i, b := range w.buckets {
+		if i == w.offset || b.pass == 0 {
+			continue
+		}
+		if b.pass > maxPass {
+			maxPass = b.pass
+		}
+		rt := b.rtSum / time.Duration(b.pass)
+		if rt <= 0 {
+			rt = 1
+		}
+		if minRT == 0 || rt < minRT {
+			minRT = rt
+		}
+	}
+	return maxPass, minRT
+}
+
+func (w *window) rotate(now time.Time) {
+	n := int(now.Sub(w.start) / w.size)
+	if n <= 0 {
+		return
+	}
+	w.start = w.start.Add(time.Duration(n) * w.size)
+	if n > len(w.buckets) {
+		n = len(w.buckets)
+	}
+	for i := 0; i < n; i++ {
+		w.offset = (w.offset + 1) % len(w.buckets)
+		w.buckets[w.offset] = bucket{}
+	}
+}
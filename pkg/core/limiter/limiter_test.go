@@ -0,0 +1,110 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	var cpu int64 = 900
+
+	l := newLimiter("limiter.test", Config{Window: time.Second, Buckets: 10, CoolDown: 200 * time.Millisecond})
+	l.now = clock.Now
+	l.cpu = func() int64 { return cpu }
+	l.window = newWindow(100*time.Millisecond, 10, clock.Now())
+
+	// 没有统计数据时不限流
+	done, err := l.Allow(PriorityDefault)
+	assert.Nil(t, err)
+	clock.Add(50 * time.Millisecond)
+	done()
+	clock.Add(50 * time.Millisecond)
+	cpu = 100
+
+	// 每个桶完成10个请求，RT 50ms，容量为 10 * 50ms / 100ms = 5
+	for i := 0; i < 5; i++ {
+		dones := make([]func(), 0, 10)
+		for j := 0; j < 10; j++ {
+			done, err := l.Allow(PriorityDefault)
+			assert.Nil(t, err)
+			dones = append(dones, done)
+		}
+		clock.Add(50 * time.Millisecond)
+		for _, done := range dones {
+			done()
+		}
+		clock.Add(50 * time.Millisecond)
+	}
+
+	stat := l.Stat()
+	assert.Equal(t, int64(5), stat.MaxInFlight)
+	assert.Equal(t, int64(10), stat.MaxPass)
+	assert.Equal(t, float64(50), stat.MinRT)
+	assert.False(t, stat.Shedding)
+
+	cpu = 900
+	for i := 0; i < 5; i++ {
+		_, err := l.Allow(PriorityDefault)
+		assert.Nil(t, err)
+	}
+
+	shed := metric.ServerShedCounter.WithLabelValues("limiter.test", "default")
+	before := testutil.ToFloat64(shed)
+	_, err = l.Allow(PriorityDefault)
+	assert.Equal(t, ErrLimitExceed, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(shed)-before)
+
+	// 高优先级的请求可以超过容量，低优先级的先被拒绝
+	_, err = l.Allow(PriorityCritical)
+	assert.Nil(t, err)
+	_, err = l.Allow(PrioritySheddable)
+	assert.Equal(t, ErrLimitExceed, err)
+	assert.True(t, l.Stat().Shedding)
+
+	// CPU下降后冷却期内仍然限流
+	cpu = 100
+	_, err = l.Allow(PriorityDefault)
+	assert.Equal(t, ErrLimitExceed, err)
+
+	clock.Add(300 * time.Millisecond)
+	_, err = l.Allow(PriorityDefault)
+	assert.Nil(t, err)
+}
+
+func TestParsePriority(t *testing.T) {
+	assert.Equal(t, PriorityCritical, ParsePriority("CRITICAL"))
+	assert.Equal(t, PrioritySheddable, ParsePriority("sheddable"))
+	assert.Equal(t, PriorityDefault, ParsePriority(""))
+	assert.Equal(t, "sheddable", PrioritySheddable.String())
+}
@@ -37,6 +37,8 @@ var (
 	TypeGRPCUnary = "unary"
 	// TypeGRPCStream ...
 	TypeGRPCStream = "stream"
+	// TypeGRPC ...
+	TypeGRPC = "grpc"
 	// TypeRedis ...
 	TypeRedis = "redis"
 	TypeGorm  = "gorm"
@@ -52,6 +54,12 @@ var (
 
 	// TypeTableStore
 	TypeTableStore = "tstore"
+	// TypeEtcd ...
+	TypeEtcd = "etcd"
+	// TypeResty ...
+	TypeResty = "resty"
+	// TypeMongo ...
+	TypeMongo = "mongo"
 	// CodeJob
 	CodeJobSuccess = "ok"
 	// CodeJobFail ...
@@ -94,6 +102,62 @@ var (
 		Labels:    []string{"type", "name", "method", "server"},
 	}.Build()
 
+	// ClientPickCounter 客户端负载均衡选中的节点所在位置
+	ClientPickCounter = CounterVecOpts{
+		Namespace: constant.DefaultNamespace,
+		Name:      "client_pick_total",
+		Labels:    []string{"balancer", "server", "locality"},
+	}.Build()
+
+	// ClientEjectionCounter 客户端异常检测摘除节点的次数
+	ClientEjectionCounter = CounterVecOpts{
+		Namespace: constant.DefaultNamespace,
+		Name:      "client_ejection_total",
+		Labels:    []string{"balancer", "server", "addr", "reason"},
+	}.Build()
+
+	// ClientEjectedGauge 客户端异常检测摘除中的节点，摘除中为1
+	ClientEjectedGauge = GaugeVecOpts{
+		Namespace: constant.DefaultNamespace,
+		Name:      "client_ejected",
+		Labels:    []string{"balancer", "server", "addr"},
+	}.Build()
+
+	// ServerShedCounter 服务端自适应限流丢弃的请求
+	ServerShedCounter = CounterVecOpts{
+		Namespace: constant.DefaultNamespace,
+		Name:      "server_shed_total",
+		Labels:    []string{"name", "priority"},
+	}.Build()
+
+	// ServerLimiterGauge 服务端自适应限流的状态，stat为cpu、inflight、max_inflight
+	ServerLimiterGauge = GaugeVecOpts{
+		Namespace: constant.DefaultNamespace,
+		Name:      "server_limiter",
+		Labels:    []string{"name", "stat"},
+	}.Build()
+
+	// ServerAuthDeniedCounter 服务端认证或访问控制拒绝的请求，reason为unauthenticated、permission_denied
+	ServerAuthDeniedCounter = CounterVecOpts{
+		Namespace: constant.DefaultNamespace,
+		Name:      "server_auth_denied_total",
+		Labels:    []string{"method", "reason"},
+	}.Build()
+
+	// ServerValidationFailedCounter 服务端请求校验失败的次数
+	ServerValidationFailedCounter = CounterVecOpts{
+		Namespace: constant.DefaultNamespace,
+		Name:      "server_validation_failed_total",
+		Labels:    []string{"type", "method"},
+	}.Build()
+
+	// ServerShadowCounter 服务端复制到影子服务的请求，result为ok、error、match、mismatch、rate_limited、concurrency_limited
+	ServerShadowCounter = CounterVecOpts{
+		Namespace: constant.DefaultNamespace,
+		Name:      "server_shadow_total",
+		Labels:    []string{"method", "result"},
+	}.Build()
+
 	// JobHandleCounter ...
 	JobHandleCounter = CounterVecOpts{
 		Namespace: constant.DefaultNamespace,
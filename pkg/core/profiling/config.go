@@ -0,0 +1,131 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiling
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/douyu/jupiter/pkg"
+	"github.com/douyu/jupiter/pkg/conf"
+	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/xlog"
+)
+
+// ModName ..
+const ModName = "profiling"
+
+// Config ...
+type Config struct {
+	// Enable 开启持续采集
+	Enable bool
+	// Dir profile文件存放目录
+	Dir string
+	// MaxFiles 目录中最多保留的profile数量，超过后删除最旧的
+	MaxFiles int
+	// Types 采集类型: cpu, heap, goroutine, mutex, block
+	Types []string
+	// Interval 周期采集间隔，0表示不做周期采集
+	Interval time.Duration
+	// CPUDuration 单次cpu采样时长
+	CPUDuration time.Duration
+	// MaxCaptureDuration 手动采集时 seconds 参数的上限，超过时返回400
+	MaxCaptureDuration time.Duration
+	// MutexProfileFraction 开启mutex采集时的采样率
+	MutexProfileFraction int
+	// BlockProfileRate 开启block采集时的采样率
+	BlockProfileRate int
+	// Threshold 超过阈值时自动采集
+	Threshold ThresholdConfig
+	// Pusher 推送至Pyroscope兼容的服务端
+	Pusher PusherConfig
+
+	logger *xlog.Logger
+}
+
+// ThresholdConfig ...
+type ThresholdConfig struct {
+	// CheckInterval 阈值检测间隔，0表示不检测
+	CheckInterval time.Duration
+	// CPUPercent 进程cpu使用率(百分比，多核累加)
+	CPUPercent float64
+	// Goroutines goroutine数量
+	Goroutines int
+	// RSS 进程常驻内存(MB)
+	RSS uint64
+	// Cooldown 同一指标两次触发采集的最小间隔
+	Cooldown time.Duration
+}
+
+// PusherConfig ...
+type PusherConfig struct {
+	Enable bool
+	// Endpoint 服务端地址，如 http://127.0.0.1:4040
+	Endpoint string
+	// AppName 应用名称，默认为pkg.Name()
+	AppName string
+	// Tags 附加标签
+	Tags    map[string]string
+	Timeout time.Duration
+}
+
+// StdConfig ...
+func StdConfig() *Config {
+	return RawConfig(constant.ConfigKey(ModName))
+}
+
+// RawConfig ...
+func RawConfig(key string) *Config {
+	var config = DefaultConfig()
+	if conf.Get(key) == nil {
+		return config
+	}
+	if err := conf.UnmarshalKey(key, config); err != nil {
+		config.logger.Panic("profiling parse config panic",
+			xlog.FieldErr(err), xlog.FieldKey(key),
+			xlog.FieldValueAny(config),
+		)
+	}
+	return config
+}
+
+// DefaultConfig ...
+func DefaultConfig() *Config {
+	return &Config{
+		Enable:               false,
+		Dir:                  filepath.Join(pkg.LogDir(), "profiling"),
+		MaxFiles:             100,
+		Types:                []string{TypeCPU, TypeHeap, TypeGoroutine},
+		Interval:             10 * time.Minute,
+		CPUDuration:          10 * time.Second,
+		MaxCaptureDuration:   time.Minute,
+		MutexProfileFraction: 5,
+		BlockProfileRate:     int(time.Millisecond),
+		Threshold: ThresholdConfig{
+			CheckInterval: 10 * time.Second,
+			Cooldown:      5 * time.Minute,
+		},
+		Pusher: PusherConfig{
+			AppName: pkg.Name(),
+			Timeout: 10 * time.Second,
+		},
+		logger: xlog.Jupiter().With(xlog.FieldMod(ModName)),
+	}
+}
+
+// Build ...
+func (config *Config) Build() *Profiler {
+	return newProfiler(config)
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiling
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/conf"
+	"github.com/douyu/jupiter/pkg/core/hooks"
+	"github.com/douyu/jupiter/pkg/server/governor"
+	jsoniter "github.com/json-iterator/go"
+)
+
+var defaultProfiler *Profiler
+
+func init() {
+	conf.OnLoaded(func(c *conf.Configuration) {
+		log.Print("hook config, init profiling")
+
+		config := StdConfig()
+		if !config.Enable {
+			return
+		}
+
+		defaultProfiler = config.Build()
+		defaultProfiler.Start()
+		hooks.Register(hooks.Stage_BeforeStop, defaultProfiler.Stop)
+	})
+
+	registerHandlers()
+}
+
+// Default returns the profiler built from config, nil if not enabled.
+func Default() *Profiler {
+	return defaultProfiler
+}
+
+func registerHandlers() {
+	// 列出已采集的profile
+	governor.HandleFunc("/debug/profiling/list", withProfiler(func(p *Profiler, w http.ResponseWriter, r *http.Request) {
+		_ = jsoniter.NewEncoder(w).Encode(p.List())
+	}))
+
+	// 下载profile，可直接用 go tool pprof 分析
+	governor.HandleFunc("/debug/profiling/download", withProfiler(func(p *Profiler, w http.ResponseWriter, r *http.Request) {
+		f, prof, err := p.Open(r.URL.Query().Get("id"))
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, prof.ID))
+		_, _ = io.Copy(w, f)
+	}))
+
+	// 手动采集，如 /debug/profiling/capture?label=release&types=cpu,heap&seconds=5
+	governor.HandleFunc("/debug/profiling/capture", withProfiler(func(p *Profiler, w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		types := p.config.Types
+		if v := query.Get("types"); v != "" {
+			types = strings.Split(v, ",")
+		}
+
+		duration := p.config.CPUDuration
+		if v := query.Get("seconds"); v != "" {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds <= 0 {
+				http.Error(w, "invalid seconds", http.StatusBadRequest)
+				return
+			}
+			// compare before multiplying, which may overflow
+			if seconds > int(p.config.MaxCaptureDuration/time.Second) {
+				http.Error(w, fmt.Sprintf("seconds exceeds %v", p.config.MaxCaptureDuration), http.StatusBadRequest)
+				return
+			}
+			duration = time.Duration(seconds) * time.Second
+		}
+
+		profiles, err := p.captureAll(duration, ReasonManual, query.Get("label"), types...)
+		if errors.Is(err, ErrUnknownType) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = jsoniter.NewEncoder(w).Encode(profiles)
+	}))
+}
+
+func withProfiler(fn func(*Profiler, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := Default()
+		if p == nil {
+			http.Error(w, "profiling not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		fn(p, w, r)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiling
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/xlog"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// profile types
+const (
+	TypeCPU       = "cpu"
+	TypeHeap      = "heap"
+	TypeGoroutine = "goroutine"
+	TypeMutex     = "mutex"
+	TypeBlock     = "block"
+)
+
+// capture reasons
+const (
+	ReasonPeriodic  = "periodic"
+	ReasonThreshold = "threshold"
+	ReasonManual    = "manual"
+)
+
+var (
+	// ErrUnknownType ...
+	ErrUnknownType = errors.New("unknown profile type")
+	// ErrStopped ...
+	ErrStopped = errors.New("profiler stopped")
+)
+
+// Profiler captures profiles periodically, on threshold and on demand.
+type Profiler struct {
+	config *Config
+	logger *xlog.Logger
+	store  *store
+	pusher *pusher
+	proc   *process.Process
+
+	// cpuMu serializes cpu profiling, runtime only allows one at a time.
+	cpuMu sync.Mutex
+
+	lastTriggered map[string]time.Time
+	stop          chan struct{}
+	stopOnce      sync.Once
+	// mu guards stopped, so that wg.Add never races with wg.Wait in Stop.
+	mu      sync.Mutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func newProfiler(config *Config) *Profiler {
+	store, err := newStore(config.Dir, config.MaxFiles)
+	if err != nil {
+		config.logger.Panic("new profiling store", xlog.FieldErr(err), xlog.String("dir", config.Dir))
+	}
+
+	p := &Profiler{
+		config:        config,
+		logger:        config.logger,
+		store:         store,
+		lastTriggered: make(map[string]time.Time),
+		stop:          make(chan struct{}),
+	}
+	if config.Pusher.Enable {
+		p.pusher = newPusher(config.Pusher)
+	}
+	if proc, err := process.NewProcess(int32(os.Getpid())); err == nil {
+		p.proc = proc
+	} else {
+		p.logger.Warn("profiling self process", xlog.FieldErr(err))
+	}
+
+	for _, typ := range config.Types {
+		switch typ {
+		case TypeMutex:
+			runtime.SetMutexProfileFraction(config.MutexProfileFraction)
+		case TypeBlock:
+			runtime.SetBlockProfileRate(config.BlockProfileRate)
+		}
+	}
+
+	return p
+}
+
+// Start starts the periodic and threshold loops.
+func (p *Profiler) Start() {
+	if p.config.Interval > 0 {
+		_ = p.spawn(func() {
+			p.loop(p.config.Interval, func() {
+				_, _ = p.Capture(ReasonPeriodic, "", p.config.Types...)
+			})
+		})
+	}
+	if p.config.Threshold.CheckInterval > 0 {
+		_ = p.spawn(func() {
+			p.loop(p.config.Threshold.CheckInterval, p.checkThreshold)
+		})
+	}
+}
+
+// Stop stops all loops and waits for running captures.
+func (p *Profiler) Stop() {
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		p.stopped = true
+		p.mu.Unlock()
+
+		close(p.stop)
+		p.wg.Wait()
+	})
+}
+
+// spawn runs fn in a goroutine waited by Stop, or returns ErrStopped if p
+// is stopped.
+func (p *Profiler) spawn(fn func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return ErrStopped
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		fn()
+	}()
+	return nil
+}
+
+// List returns stored profiles, newest first.
+func (p *Profiler) List() []Profile {
+	return p.store.list()
+}
+
+// Open opens a stored profile by id.
+func (p *Profiler) Open(id string) (*os.File, *Profile, error) {
+	return p.store.open(id)
+}
+
+// Capture captures every type in types and stores them with label.
+func (p *Profiler) Capture(reason, label string, types ...string) ([]Profile, error) {
+	return p.captureAll(p.config.CPUDuration, reason, label, types...)
+}
+
+func (p *Profiler) captureAll(cpuDuration time.Duration, reason, label string, types ...string) ([]Profile, error) {
+	profiles := make([]Profile, 0, len(types))
+	for _, typ := range types {
+		prof, err := p.capture(cpuDuration, reason, label, typ)
+		if err != nil {
+			p.logger.Error("capture profile", xlog.FieldErr(err), xlog.FieldType(typ), xlog.String("reason", reason))
+			return profiles, err
+		}
+		profiles = append(profiles, *prof)
+	}
+	return profiles, nil
+}
+
+func (p *Profiler) capture(cpuDuration time.Duration, reason, label, typ string) (*Profile, error) {
+	var buf bytes.Buffer
+	start := time.Now()
+
+	switch typ {
+	case TypeCPU:
+		if err := p.captureCPU(&buf, cpuDuration); err != nil {
+			return nil, err
+		}
+	case TypeHeap, TypeGoroutine, TypeMutex, TypeBlock:
+		if err := pprof.Lookup(typ).WriteTo(&buf, 0); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, typ)
+	}
+
+	prof := &Profile{
+		Type:   typ,
+		Reason: reason,
+		Label:  label,
+		Time:   start,
+	}
+	if err := p.store.save(prof, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	p.logger.Info("capture profile", xlog.FieldType(typ), xlog.String("reason", reason), xlog.String("id", prof.ID), xlog.FieldCost(time.Since(start)))
+
+	if p.pusher != nil {
+		data, until := buf.Bytes(), time.Now()
+		err := p.spawn(func() {
+			if err := p.pusher.push(prof, data, until); err != nil {
+				p.logger.Error("push profile", xlog.FieldErr(err), xlog.String("id", prof.ID))
+			}
+		})
+		if err != nil {
+			p.logger.Warn("push profile", xlog.FieldErr(err), xlog.String("id", prof.ID))
+		}
+	}
+
+	return prof, nil
+}
+
+func (p *Profiler) captureCPU(buf *bytes.Buffer, duration time.Duration) error {
+	p.cpuMu.Lock()
+	defer p.cpuMu.Unlock()
+
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-p.stop:
+	}
+	pprof.StopCPUProfile()
+
+	return nil
+}
+
+func (p *Profiler) loop(interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			fn()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *Profiler) checkThreshold() {
+	th := p.config.Threshold
+
+	if th.Goroutines > 0 {
+		if n := runtime.NumGoroutine(); n > th.Goroutines {
+			p.trigger("goroutines", fmt.Sprintf("goroutines-%d", n), TypeGoroutine)
+		}
+	}
+
+	if p.proc == nil {
+		return
+	}
+
+	if th.CPUPercent > 0 {
+		// Percent(0) compares with the previous call.
+		if percent, err := p.proc.Percent(0); err == nil && percent > th.CPUPercent {
+			p.trigger("cpu", fmt.Sprintf("cpu-%.0f", percent), TypeCPU)
+		}
+	}
+
+	if th.RSS > 0 {
+		if mem, err := p.proc.MemoryInfo(); err == nil && mem.RSS > th.RSS<<20 {
+			p.trigger("rss", fmt.Sprintf("rss-%dm", mem.RSS>>20), TypeHeap)
+		}
+	}
+}
+
+func (p *Profiler) trigger(metric, label string, types ...string) {
+	if last, ok := p.lastTriggered[metric]; ok && time.Since(last) < p.config.Threshold.Cooldown {
+		return
+	}
+	p.lastTriggered[metric] = time.Now()
+
+	p.logger.Warn("profiling threshold exceeded", xlog.String("metric", metric), xlog.String("label", label))
+	_, _ = p.Capture(ReasonThreshold, label, types...)
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiling
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/server/governor"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConfig(t *testing.T) *Config {
+	config := DefaultConfig()
+	config.Dir = t.TempDir()
+	config.MaxFiles = 3
+	config.CPUDuration = 100 * time.Millisecond
+	return config
+}
+
+func TestStoreRing(t *testing.T) {
+	config := newTestConfig(t)
+	p := config.Build()
+	defer p.Stop()
+
+	for i := 0; i < 5; i++ {
+		_, err := p.Capture(ReasonManual, "round "+string(rune('a'+i)), TypeGoroutine)
+		assert.Nil(t, err)
+	}
+
+	list := p.List()
+	assert.Equal(t, 3, len(list))
+	assert.Equal(t, "round-e", list[0].Label)
+	assert.Equal(t, "round-c", list[2].Label)
+
+	// reload from disk
+	reloaded := config.Build().List()
+	assert.Equal(t, len(list), len(reloaded))
+	for i := range list {
+		assert.Equal(t, list[i].ID, reloaded[i].ID)
+		assert.Equal(t, list[i].Size, reloaded[i].Size)
+		assert.True(t, list[i].Time.Equal(reloaded[i].Time))
+	}
+
+	_, _, err := p.Open("../" + list[0].ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	f, prof, err := p.Open(list[0].ID)
+	assert.Nil(t, err)
+	defer f.Close()
+	assert.Equal(t, TypeGoroutine, prof.Type)
+}
+
+func TestCapture(t *testing.T) {
+	p := newTestConfig(t).Build()
+	defer p.Stop()
+
+	profiles, err := p.Capture(ReasonManual, "all", TypeCPU, TypeHeap, TypeGoroutine, TypeMutex, TypeBlock)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(profiles))
+	for _, prof := range profiles {
+		assert.Greater(t, prof.Size, int64(0), prof.Type)
+	}
+
+	_, err = p.Capture(ReasonManual, "", "unknown")
+	assert.ErrorIs(t, err, ErrUnknownType)
+}
+
+func TestThreshold(t *testing.T) {
+	config := newTestConfig(t)
+	config.Threshold.Goroutines = 1
+	p := config.Build()
+	defer p.Stop()
+
+	p.checkThreshold()
+	p.checkThreshold()
+
+	list := p.List()
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, ReasonThreshold, list[0].Reason)
+	assert.Equal(t, TypeGoroutine, list[0].Type)
+}
+
+func TestPusher(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		names []string
+		sizes []int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("profile")
+		assert.Nil(t, err)
+		data, _ := io.ReadAll(f)
+
+		mu.Lock()
+		names = append(names, r.URL.Query().Get("name"))
+		sizes = append(sizes, len(data))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	config := newTestConfig(t)
+	config.Pusher.Enable = true
+	config.Pusher.Endpoint = srv.URL
+	config.Pusher.AppName = "demo"
+	config.Pusher.Tags = map[string]string{"env": "test"}
+	p := config.Build()
+
+	_, err := p.Capture(ReasonManual, "v1.2", TypeHeap)
+	assert.Nil(t, err)
+	p.Stop()
+
+	// captured after Stop, saved but not pushed
+	_, err = p.Capture(ReasonManual, "v1.3", TypeHeap)
+	assert.Nil(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"demo.heap{env=test,label=v1-2,reason=manual}"}, names)
+	assert.Greater(t, sizes[0], 0)
+}
+
+func TestHandlers(t *testing.T) {
+	defaultProfiler = newTestConfig(t).Build()
+	defer func() {
+		defaultProfiler.Stop()
+		defaultProfiler = nil
+	}()
+
+	srv := httptest.NewServer(governor.DefaultServeMux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/debug/profiling/capture")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL+"/debug/profiling/capture?types=cpu&seconds=86400", "", nil)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// overflows time.Duration once multiplied by time.Second
+	resp, err = http.Post(srv.URL+"/debug/profiling/capture?types=cpu&seconds=18446744074", "", nil)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL+"/debug/profiling/capture?label=manual&types=heap,goroutine", "", nil)
+	assert.Nil(t, err)
+	var captured []Profile
+	assert.Nil(t, jsoniter.NewDecoder(resp.Body).Decode(&captured))
+	resp.Body.Close()
+	assert.Equal(t, 2, len(captured))
+
+	resp, err = http.Get(srv.URL + "/debug/profiling/list")
+	assert.Nil(t, err)
+	var list []Profile
+	assert.Nil(t, jsoniter.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	assert.Equal(t, 2, len(list))
+
+	resp, err = http.Get(srv.URL + "/debug/profiling/download?id=" + list[0].ID)
+	assert.Nil(t, err)
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, list[0].Size, int64(len(data)))
+	assert.True(t, strings.Contains(resp.Header.Get("Content-Disposition"), list[0].ID))
+
+	resp, err = http.Get(srv.URL + "/debug/profiling/download?id=missing")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiling
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var tagReplacer = regexp.MustCompile(`[^a-zA-Z0-9_.\-]+`)
+
+// pusher uploads profiles to the Pyroscope compatible /ingest API.
+type pusher struct {
+	config PusherConfig
+	client *http.Client
+}
+
+func newPusher(config PusherConfig) *pusher {
+	return &pusher{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// appName returns name like "app.cpu{k1=v1,k2=v2}".
+func (p *pusher) appName(prof *Profile) string {
+	tags := make(map[string]string, len(p.config.Tags)+2)
+	for k, v := range p.config.Tags {
+		tags[k] = v
+	}
+	tags["reason"] = prof.Reason
+	if prof.Label != "" {
+		tags["label"] = prof.Label
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, tagReplacer.ReplaceAllString(k, "_")+"="+tagReplacer.ReplaceAllString(tags[k], "_"))
+	}
+
+	return fmt.Sprintf("%s.%s{%s}", p.config.AppName, prof.Type, strings.Join(pairs, ","))
+}
+
+func (p *pusher) push(prof *Profile, data []byte, until time.Time) error {
+	query := url.Values{}
+	query.Set("name", p.appName(prof))
+	query.Set("from", strconv.FormatInt(prof.Time.Unix(), 10))
+	query.Set("until", strconv.FormatInt(until.Unix(), 10))
+	query.Set("spyName", "gospy")
+	if prof.Type == TypeCPU {
+		query.Set("sampleRate", "100")
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("profile", "profile.pprof")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(p.config.Endpoint, "/")+"/ingest?"+query.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("push profile: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiling
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fileExt = ".pprof"
+
+var (
+	// ErrNotFound ...
+	ErrNotFound = errors.New("profile not found")
+
+	labelReplacer = regexp.MustCompile(`[^a-zA-Z0-9\-]+`)
+)
+
+// Profile describes a captured profile on disk.
+type Profile struct {
+	ID     string    `json:"id"`
+	Type   string    `json:"type"`
+	Reason string    `json:"reason"`
+	Label  string    `json:"label"`
+	Time   time.Time `json:"time"`
+	Size   int64     `json:"size"`
+}
+
+func (p *Profile) filename() string {
+	return fmt.Sprintf("%d_%s_%s_%s%s", p.Time.UnixNano(), p.Type, p.Reason, p.Label, fileExt)
+}
+
+// parseProfile parses file name like "{unixnano}_{type}_{reason}_{label}.pprof".
+func parseProfile(name string) (*Profile, bool) {
+	if !strings.HasSuffix(name, fileExt) {
+		return nil, false
+	}
+	parts := strings.SplitN(strings.TrimSuffix(name, fileExt), "_", 4)
+	if len(parts) != 4 {
+		return nil, false
+	}
+	nano, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	return &Profile{
+		ID:     name,
+		Type:   parts[1],
+		Reason: parts[2],
+		Label:  parts[3],
+		Time:   time.Unix(0, nano),
+	}, true
+}
+
+func sanitizeLabel(label string) string {
+	return strings.Trim(labelReplacer.ReplaceAllString(label, "-"), "-")
+}
+
+// store keeps at most max profiles in dir, evicting the oldest ones.
+type store struct {
+	dir string
+	max int
+
+	mu       sync.RWMutex
+	profiles []*Profile // oldest first
+}
+
+func newStore(dir string, max int) (*store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &store{dir: dir, max: max, profiles: make([]*Profile, 0)}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		p, ok := parseProfile(entry.Name())
+		if !ok {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			p.Size = info.Size()
+		}
+		s.profiles = append(s.profiles, p)
+	}
+	sort.Slice(s.profiles, func(i, j int) bool {
+		return s.profiles[i].Time.Before(s.profiles[j].Time)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evict()
+
+	return s, nil
+}
+
+func (s *store) save(p *Profile, data []byte) error {
+	p.Label = sanitizeLabel(p.Label)
+	p.ID = p.filename()
+	p.Size = int64(len(data))
+
+	path := filepath.Join(s.dir, p.ID)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles = append(s.profiles, p)
+	s.evict()
+
+	return nil
+}
+
+// evict must be called with mu held.
+func (s *store) evict() {
+	if s.max <= 0 {
+		return
+	}
+	for len(s.profiles) > s.max {
+		_ = os.Remove(filepath.Join(s.dir, s.profiles[0].ID))
+		s.profiles = s.profiles[1:]
+	}
+}
+
+// list returns profiles newest first.
+func (s *store) list() []Profile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]Profile, 0, len(s.profiles))
+	for i := len(s.profiles) - 1; i >= 0; i-- {
+		list = append(list, *s.profiles[i])
+	}
+	return list
+}
+
+// open only opens files known to the store, so id can't escape dir.
+func (s *store) open(id string) (*os.File, *Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.profiles {
+		if p.ID == id {
+			f, err := os.Open(filepath.Join(s.dir, p.ID))
+			if err != nil {
+				return nil, nil, err
+			}
+			return f, p, nil
+		}
+	}
+	return nil, nil, ErrNotFound
+}
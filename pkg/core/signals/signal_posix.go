@@ -23,3 +23,6 @@ import (
 )
 
 var shutdownSignals = []os.Signal{syscall.SIGQUIT, os.Interrupt, syscall.SIGTERM}
+
+// upgradeSignals 升级进程的信号
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
@@ -23,3 +23,6 @@ import (
 )
 
 var shutdownSignals = []os.Signal{syscall.SIGQUIT, os.Interrupt}
+
+// upgradeSignals windows不支持升级
+var upgradeSignals []os.Signal
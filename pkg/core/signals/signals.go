@@ -34,3 +34,17 @@ func Shutdown(stop func(grace bool)) {
 		os.Exit(128 + int(s.(syscall.Signal))) // second signal. Exit directly.
 	}()
 }
+
+// Upgrade 收到升级信号(SIGUSR2)时调用upgrade
+func Upgrade(upgrade func()) {
+	if len(upgradeSignals) == 0 {
+		return
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, upgradeSignals...)
+	go func() {
+		for range sig {
+			upgrade()
+		}
+	}()
+}
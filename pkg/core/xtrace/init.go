@@ -21,6 +21,7 @@ import (
 	"github.com/douyu/jupiter/pkg/core/constant"
 	"github.com/douyu/jupiter/pkg/core/xtrace/jaeger"
 	"github.com/douyu/jupiter/pkg/core/xtrace/otelgrpc"
+	"github.com/douyu/jupiter/pkg/xlog"
 )
 
 func init() {
@@ -41,6 +42,12 @@ func init() {
 			var config = otelgrpc.RawConfig(key)
 			SetGlobalTracer(config.Build())
 
+			if config.Logs.Enable {
+				core := config.BuildLogCore()
+				xlog.SetDefault(xlog.Default().WithOptions(xlog.Tee(core)))
+				xlog.SetJupiter(xlog.Jupiter().WithOptions(xlog.Tee(core)))
+			}
+
 			return
 		}
 	})
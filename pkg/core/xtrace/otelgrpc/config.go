@@ -21,6 +21,8 @@ type Config struct {
 	Name     string
 	Endpoint string
 	Sampler  float64
+	// Logs 日志导出配置，与trace共用Resource
+	Logs LogsConfig
 }
 
 // RawConfig ...
@@ -38,9 +40,18 @@ func DefaultConfig() *Config {
 		Name:     pkg.Name(),
 		Endpoint: "localhost:4317",
 		Sampler:  0,
+		Logs:     DefaultLogsConfig(),
 	}
 }
 
+// Resource returns the resource shared by traces and logs.
+func (config *Config) Resource() *resource.Resource {
+	return resource.NewSchemaless(
+		semconv.TelemetrySDKLanguageGo,
+		semconv.ServiceNameKey.String(config.Name),
+	)
+}
+
 // Build ...
 func (config *Config) Build() trace.TracerProvider {
 
@@ -66,10 +77,7 @@ func (config *Config) Build() trace.TracerProvider {
 		// Always be sure to batch in production.
 		tracesdk.WithBatcher(traceExporter),
 		// Record information about this application in an Resource.
-		tracesdk.WithResource(resource.NewSchemaless(
-			semconv.TelemetrySDKLanguageGo,
-			semconv.ServiceNameKey.String(config.Name),
-		)),
+		tracesdk.WithResource(config.Resource()),
 	)
 	otel.SetTracerProvider(tp)
 	return tp
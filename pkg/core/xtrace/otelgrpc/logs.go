@@ -0,0 +1,399 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelgrpc
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/hooks"
+	"github.com/douyu/jupiter/pkg/xlog"
+	"go.opentelemetry.io/otel/attribute"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// LogsConfig OTLP日志导出配置
+type LogsConfig struct {
+	// Enable 开启后default和jupiter日志同时导出到Endpoint
+	Enable bool
+	// Level 导出的最低日志等级
+	Level string
+	// QueueSize 待导出日志队列长度，队列满时丢弃
+	QueueSize int
+	// BatchSize 单次导出的最大日志条数
+	BatchSize int
+	// FlushInterval 导出间隔
+	FlushInterval time.Duration
+	// Timeout 单次导出超时时间
+	Timeout time.Duration
+}
+
+// DefaultLogsConfig ...
+func DefaultLogsConfig() LogsConfig {
+	return LogsConfig{
+		Enable:        false,
+		Level:         "info",
+		QueueSize:     2048,
+		BatchSize:     512,
+		FlushInterval: time.Second,
+		Timeout:       5 * time.Second,
+	}
+}
+
+// BuildLogCore builds a zapcore.Core exporting entries to the OTLP endpoint
+// with the same resource as the tracer provider. The connection is made in
+// the background, so the endpoint doesn't need to be reachable on startup.
+func (config *Config) BuildLogCore() zapcore.Core {
+	conn, err := grpc.NewClient(config.Endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		xlog.Jupiter().Panic("new otelgrpc logs", xlog.FieldMod("build"), xlog.FieldErr(err))
+		return nil
+	}
+
+	lv := zapcore.InfoLevel
+	if err := lv.UnmarshalText([]byte(config.Logs.Level)); err != nil {
+		xlog.Jupiter().Panic("new otelgrpc logs", xlog.FieldMod("build"), xlog.FieldErr(err))
+		return nil
+	}
+
+	exporter := newLogExporter(collogspb.NewLogsServiceClient(conn), config.Resource().Attributes(), config.Logs)
+	hooks.Register(hooks.Stage_AfterStop, func() {
+		exporter.stop()
+		_ = conn.Close()
+	})
+
+	return newLogCore(lv, exporter)
+}
+
+type logRecord struct {
+	scope  string
+	record *logspb.LogRecord
+}
+
+// logExporter batches log records and exports them to the collector.
+type logExporter struct {
+	client   collogspb.LogsServiceClient
+	resource *resourcepb.Resource
+	config   LogsConfig
+
+	queue   chan logRecord
+	flushCh chan chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+func newLogExporter(client collogspb.LogsServiceClient, attrs []attribute.KeyValue, config LogsConfig) *logExporter {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultLogsConfig().QueueSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultLogsConfig().BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultLogsConfig().FlushInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultLogsConfig().Timeout
+	}
+
+	e := &logExporter{
+		client:   client,
+		resource: &resourcepb.Resource{Attributes: toKeyValues(attrs)},
+		config:   config,
+		queue:    make(chan logRecord, config.QueueSize),
+		flushCh:  make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	e.wg.Add(1)
+	go e.run()
+
+	return e
+}
+
+// enqueue never blocks the caller, records are dropped when queue is full.
+func (e *logExporter) enqueue(r logRecord) {
+	select {
+	case e.queue <- r:
+	default:
+	}
+}
+
+// flush blocks until all queued records have been exported.
+func (e *logExporter) flush() {
+	ch := make(chan struct{})
+	select {
+	case e.flushCh <- ch:
+		<-ch
+	case <-e.done:
+	}
+}
+
+func (e *logExporter) stop() {
+	e.once.Do(func() {
+		close(e.done)
+		e.wg.Wait()
+	})
+}
+
+func (e *logExporter) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]logRecord, 0, e.config.BatchSize)
+	drain := func() {
+		for {
+			select {
+			case r := <-e.queue:
+				batch = append(batch, r)
+				if len(batch) >= e.config.BatchSize {
+					e.export(batch)
+					batch = batch[:0]
+				}
+			default:
+				e.export(batch)
+				batch = batch[:0]
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case r := <-e.queue:
+			batch = append(batch, r)
+			if len(batch) >= e.config.BatchSize {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			e.export(batch)
+			batch = batch[:0]
+		case ch := <-e.flushCh:
+			drain()
+			close(ch)
+		case <-e.done:
+			drain()
+			return
+		}
+	}
+}
+
+func (e *logExporter) export(batch []logRecord) {
+	if len(batch) == 0 {
+		return
+	}
+
+	scopes := make([]*logspb.ScopeLogs, 0)
+	index := make(map[string]*logspb.ScopeLogs)
+	for _, r := range batch {
+		sl, ok := index[r.scope]
+		if !ok {
+			sl = &logspb.ScopeLogs{Scope: &commonpb.InstrumentationScope{Name: r.scope}}
+			index[r.scope] = sl
+			scopes = append(scopes, sl)
+		}
+		sl.LogRecords = append(sl.LogRecords, r.record)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.Timeout)
+	defer cancel()
+
+	// errors are not logged here, as it would feed back into this exporter.
+	_, _ = e.client.Export(ctx, &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource:  e.resource,
+			ScopeLogs: scopes,
+		}},
+	})
+}
+
+// logCore converts zap entries into OTLP log records.
+type logCore struct {
+	zapcore.LevelEnabler
+	fields   []zapcore.Field
+	exporter *logExporter
+}
+
+func newLogCore(enab zapcore.LevelEnabler, exporter *logExporter) *logCore {
+	return &logCore{LevelEnabler: enab, exporter: exporter}
+}
+
+// With implements zapcore.Core.
+func (c *logCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+// Check implements zapcore.Core.
+func (c *logCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core.
+func (c *logCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(ent.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       severityNumber(ent.Level),
+		SeverityText:         ent.Level.CapitalString(),
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: ent.Message}},
+	}
+
+	if id, ok := enc.Fields["trace_id"].(string); ok {
+		if b, err := hex.DecodeString(id); err == nil && len(b) == 16 {
+			record.TraceId = b
+			delete(enc.Fields, "trace_id")
+		}
+	}
+	if id, ok := enc.Fields["span_id"].(string); ok {
+		if b, err := hex.DecodeString(id); err == nil && len(b) == 8 {
+			record.SpanId = b
+			delete(enc.Fields, "span_id")
+		}
+	}
+	if ent.Caller.Defined {
+		enc.Fields["caller"] = ent.Caller.TrimmedPath()
+	}
+	if ent.Stack != "" {
+		enc.Fields["stack"] = ent.Stack
+	}
+
+	record.Attributes = make([]*commonpb.KeyValue, 0, len(enc.Fields))
+	for k, v := range enc.Fields {
+		record.Attributes = append(record.Attributes, &commonpb.KeyValue{Key: k, Value: toAnyValue(v)})
+	}
+
+	c.exporter.enqueue(logRecord{scope: ent.LoggerName, record: record})
+	return nil
+}
+
+// Sync implements zapcore.Core.
+func (c *logCore) Sync() error {
+	c.exporter.flush()
+	return nil
+}
+
+func severityNumber(lv zapcore.Level) logspb.SeverityNumber {
+	switch lv {
+	case zapcore.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case zapcore.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case zapcore.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case zapcore.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	}
+	return logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED
+}
+
+func toKeyValues(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		kvs = append(kvs, &commonpb.KeyValue{
+			Key:   string(attr.Key),
+			Value: toAnyValue(attr.Value.AsInterface()),
+		})
+	}
+	return kvs
+}
+
+func toAnyValue(v interface{}) *commonpb.AnyValue {
+	switch val := v.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: val}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: val}}
+	case int:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(val)}}
+	case int8:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(val)}}
+	case int16:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(val)}}
+	case int32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(val)}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: val}}
+	case uint:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(val)}}
+	case uint8:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(val)}}
+	case uint16:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(val)}}
+	case uint32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(val)}}
+	case uint64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(val)}}
+	case float32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: float64(val)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: val}}
+	case []byte:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: val}}
+	case time.Time:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: val.Format(time.RFC3339Nano)}}
+	case time.Duration:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: val.String()}}
+	case map[string]interface{}:
+		kvs := make([]*commonpb.KeyValue, 0, len(val))
+		for k, item := range val {
+			kvs = append(kvs, &commonpb.KeyValue{Key: k, Value: toAnyValue(item)})
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: kvs}}}
+	case error:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: val.Error()}}
+	case fmt.Stringer:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: val.String()}}
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		values := make([]*commonpb.AnyValue, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, toAnyValue(rv.Index(i).Interface()))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v)}}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelgrpc
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+	"testing"
+
+	"github.com/douyu/jupiter/pkg/xlog"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+)
+
+type fakeLogsClient struct {
+	mu       sync.Mutex
+	requests []*collogspb.ExportLogsServiceRequest
+}
+
+func (c *fakeLogsClient) Export(ctx context.Context, in *collogspb.ExportLogsServiceRequest, opts ...grpc.CallOption) (*collogspb.ExportLogsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, in)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func TestLogCore(t *testing.T) {
+	client := &fakeLogsClient{}
+	config := DefaultConfig()
+	config.Name = "demo"
+
+	exporter := newLogExporter(client, config.Resource().Attributes(), config.Logs)
+	defer exporter.stop()
+
+	logger := zap.New(xlog.NewTraceCore(newLogCore(zapcore.InfoLevel, exporter))).Named("biz")
+
+	traceID, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanID, _ := trace.SpanIDFromHex("0102030405060708")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	logger.Debug("skipped")
+	logger.With(xlog.String("a", "b")).Warn("hello", xlog.FieldContext(ctx), xlog.Int("n", 1))
+	assert.Nil(t, logger.Sync())
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	assert.Equal(t, 1, len(client.requests))
+	rl := client.requests[0].ResourceLogs[0]
+	resource := make(map[string]string)
+	for _, kv := range rl.Resource.Attributes {
+		resource[kv.Key] = kv.Value.GetStringValue()
+	}
+	assert.Equal(t, "demo", resource["service.name"])
+
+	assert.Equal(t, "biz", rl.ScopeLogs[0].Scope.Name)
+	assert.Equal(t, 1, len(rl.ScopeLogs[0].LogRecords))
+
+	record := rl.ScopeLogs[0].LogRecords[0]
+	assert.Equal(t, "hello", record.Body.GetStringValue())
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_WARN, record.SeverityNumber)
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", hex.EncodeToString(record.TraceId))
+	assert.Equal(t, "0102030405060708", hex.EncodeToString(record.SpanId))
+
+	attrs := make(map[string]interface{})
+	for _, kv := range record.Attributes {
+		switch {
+		case kv.Value.GetStringValue() != "":
+			attrs[kv.Key] = kv.Value.GetStringValue()
+		default:
+			attrs[kv.Key] = kv.Value.GetIntValue()
+		}
+	}
+	assert.Equal(t, "b", attrs["a"])
+	assert.Equal(t, int64(1), attrs["n"])
+	assert.NotContains(t, attrs, "trace_id")
+}
@@ -108,3 +108,13 @@ func GracefulStop() error {
 	xgo.Parallel(executors...)()
 	return nil
 }
+
+// Range calls fn for each registered executor, stops if fn returns false
+func Range(fn func(address string, e Executor) bool) {
+	_instances.Range(func(key, val interface{}) bool {
+		if e, ok := val.(Executor); ok {
+			return fn(key.(string), e)
+		}
+		return true
+	})
+}
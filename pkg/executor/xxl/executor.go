@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -200,17 +201,47 @@ func (e *JobExecutor) killTaskHandler(writer http.ResponseWriter, request *http.
 	defer request.Body.Close()
 	param := &killReq{}
 	_ = json.Unmarshal(req, &param)
-	if !e.runList.Exists(Int64ToStr(param.JobID)) {
+	if err := e.Kill(param.JobID); err != nil {
 		_, _ = writer.Write(returnKill(param, http.StatusInternalServerError))
 		log.Println("任务[" + Int64ToStr(param.JobID) + "]没有运行")
 		return
 	}
-	task := e.runList.Get(Int64ToStr(param.JobID))
-	task.Cancel()
-	e.runList.Del(Int64ToStr(param.JobID))
 	_, _ = writer.Write(returnGeneral())
 }
 
+// Kill 终止正在运行的任务，并丢弃其排队中的任务
+func (e *JobExecutor) Kill(jobID int64) error {
+	task := e.runList.Get(Int64ToStr(jobID))
+	if task == nil {
+		return ErrTaskNotRunning
+	}
+	task.Cancel()
+	e.runList.Del(Int64ToStr(jobID))
+	return nil
+}
+
+// Tasks 列出正在运行及排队中的任务
+func (e *JobExecutor) Tasks() []TaskInfo {
+	list := make([]TaskInfo, 0)
+	for _, task := range e.runList.All() {
+		param := task.GetParam()
+		list = append(list, TaskInfo{
+			JobID:         task.GetId(),
+			Name:          task.GetName(),
+			Params:        param.ExecutorParams,
+			BlockStrategy: param.ExecutorBlockStrategy,
+			LogID:         param.LogID,
+			Running:       task.IsRunning(),
+			Pending:       len(task.pending),
+			StartTime:     task.GetStartTime(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].JobID < list[j].JobID
+	})
+	return list
+}
+
 // 任务日志
 func (e *JobExecutor) taskLogHandler(writer http.ResponseWriter, request *http.Request) {
 	data, _ := ioutil.ReadAll(request.Body)
@@ -472,3 +472,38 @@ func (ins *TestIJob) Run(ctx context.Context, param *executor.RunReq) (msg strin
 	fmt.Println("test")
 	return "success", nil
 }
+
+func Test_TasksAndKill(t *testing.T) {
+	e := (&Options{ServerAddr: "http://127.0.0.1:8080/xxl-job-admin"}).Build()
+
+	started := make(chan struct{})
+	task := &Task{
+		Id:   12,
+		Name: "task1",
+		Param: &executor.RunReq{
+			JobID:                 12,
+			ExecutorHandler:       "task1",
+			ExecutorParams:        "p",
+			ExecutorBlockStrategy: SerialExecution,
+		},
+		fn: func(ctx context.Context, param *executor.RunReq) (string, error) {
+			close(started)
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	}
+	e.runList.Set("12", task)
+	go task.Run(context.Background(), func(ctx context.Context, status int, msg string) error { return nil })
+	<-started
+
+	tasks := e.Tasks()
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, int64(12), tasks[0].JobID)
+	assert.Equal(t, "task1", tasks[0].Name)
+	assert.Equal(t, "p", tasks[0].Params)
+	assert.True(t, tasks[0].Running)
+
+	assert.Nil(t, e.Kill(12))
+	assert.Empty(t, e.Tasks())
+	assert.Equal(t, ErrTaskNotRunning, e.Kill(12))
+}
@@ -0,0 +1,58 @@
+package xxl
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/douyu/jupiter/pkg/executor"
+	"github.com/douyu/jupiter/pkg/server/governor"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// 执行器的任务列表
+type executorTasks struct {
+	Address string     `json:"address"`
+	Tasks   []TaskInfo `json:"tasks"`
+}
+
+func init() {
+	// 列出全部执行器中正在运行及排队中的任务
+	governor.HandleFunc("/debug/xxl/tasks", func(w http.ResponseWriter, r *http.Request) {
+		list := make([]executorTasks, 0)
+		executor.Range(func(address string, e executor.Executor) bool {
+			if je, ok := e.(*JobExecutor); ok {
+				list = append(list, executorTasks{Address: address, Tasks: je.Tasks()})
+			}
+			return true
+		})
+		_ = jsoniter.NewEncoder(w).Encode(list)
+	})
+
+	// 终止任务，如 /debug/xxl/kill?id=12
+	governor.HandleFunc("/debug/xxl/kill", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		jobID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		err = ErrTaskNotRunning
+		executor.Range(func(address string, e executor.Executor) bool {
+			if je, ok := e.(*JobExecutor); ok && je.Kill(jobID) == nil {
+				err = nil
+			}
+			return true
+		})
+		if errors.Is(err, ErrTaskNotRunning) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("SUCCESS"))
+	})
+}
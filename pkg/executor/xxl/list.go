@@ -110,3 +110,14 @@ func (t *taskList) Exists(key string) bool {
 	_, ok := t.data[key]
 	return ok
 }
+
+// 获取全部任务
+func (t *taskList) All() []*TaskWithPending {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	r := make([]*TaskWithPending, 0, len(t.data))
+	for _, task := range t.data {
+		r = append(r, task)
+	}
+	return r
+}
@@ -2,6 +2,7 @@ package xxl
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"runtime/debug"
@@ -26,6 +27,21 @@ type TaskResult struct {
 	err            error
 }
 
+// 任务未在运行
+var ErrTaskNotRunning = errors.New("task not running")
+
+// 任务运行信息，见治理接口 /debug/xxl/tasks
+type TaskInfo struct {
+	JobID         int64  `json:"jobId"`
+	Name          string `json:"name"`
+	Params        string `json:"params"`
+	BlockStrategy string `json:"blockStrategy"`
+	LogID         int64  `json:"logId"`
+	Running       bool   `json:"running"`   // 是否在运行
+	Pending       int    `json:"pending"`   // 排队中的任务数
+	StartTime     int64  `json:"startTime"` // 最近一次开始时间
+}
+
 const (
 	TaskResultTypeDone = iota
 	TaskResultTypeFailed
@@ -167,6 +183,13 @@ func (t *Task) GetName() string {
 	return t.Name
 }
 
+// 获取开始时间
+func (t *Task) GetStartTime() int64 {
+	t.lock.RLocker().Lock()
+	defer t.lock.RLocker().Unlock()
+	return t.StartTime
+}
+
 // 获取context
 func (t *Task) GetContext() context.Context {
 	t.lock.RLocker().Lock()
@@ -114,10 +114,17 @@ func (reg *etcdv3Registry) ListServices(ctx context.Context, prefix string) (ser
 				xlog.FieldKey(string(kv.Key)), xlog.FieldValue(string(kv.Value)))
 			continue
 		}
+		// configs under the prefix
+		if service.Addr == "" {
+			continue
+		}
 
-		services = append(services, &server.ServiceInfo{
-			Address: service.Addr,
-		})
+		info := &server.ServiceInfo{}
+		if service.MetadataX != nil {
+			info = service.MetadataX
+		}
+		info.Address = service.Addr
+		services = append(services, info)
 	}
 
 	return
@@ -418,34 +425,74 @@ func deleteAddrList(al *registry.Endpoints, prefix, scheme string, kvs ...*mvccp
 	for _, kv := range kvs {
 		var addr = strings.TrimPrefix(string(kv.Key), prefix)
 
-		if isIPPort(addr) {
-			// 直接删除addr 因为Delete操作的value值为空
-			delete(al.Nodes, addr)
-			delete(al.RouteConfigs, addr)
+		kind, id, _ := strings.Cut(addr, "/")
+		switch registry.ToKind(kind) {
+		case registry.KindConfigurator:
+			delete(al.RouteConfigs, id)
+		case registry.KindProvider:
+			delete(al.ProviderConfigs, id)
+		case registry.KindConsumer:
+			delete(al.ConsumerConfigs, id)
+		default:
+			if isIPPort(addr) {
+				// 直接删除addr 因为Delete操作的value值为空
+				delete(al.Nodes, addr)
+			}
 		}
 	}
 }
 
+// updateAddrList updates nodes by keys prefix/addr, and configs by keys
+// prefix/configurators/id for route configs, prefix/providers/addr for
+// provider configs and prefix/consumers/id for consumer configs
 func updateAddrList(al *registry.Endpoints, prefix, scheme string, kvs ...*mvccpb.KeyValue) {
 	for _, kv := range kvs {
 		var addr = strings.TrimPrefix(string(kv.Key), prefix)
-		if isIPPort(addr) {
-			var meta registry.Update
-			if err := json.Unmarshal(kv.Value, &meta); err != nil {
-				xlog.Jupiter().Error("unmarshal meta", xlog.FieldErr(err),
-					xlog.FieldExtMessage("value", string(kv.Value), "key", string(kv.Key)))
+
+		var err error
+		kind, id, _ := strings.Cut(addr, "/")
+		switch registry.ToKind(kind) {
+		case registry.KindConfigurator:
+			var config registry.RouteConfig
+			if err = json.Unmarshal(kv.Value, &config); err == nil {
+				al.RouteConfigs[id] = config
+			}
+		case registry.KindProvider:
+			var config registry.ProviderConfig
+			if err = json.Unmarshal(kv.Value, &config); err == nil {
+				al.ProviderConfigs[id] = config
+			}
+		case registry.KindConsumer:
+			var config registry.ConsumerConfig
+			if err = json.Unmarshal(kv.Value, &config); err == nil {
+				al.ConsumerConfigs[id] = config
+			}
+		default:
+			if !isIPPort(addr) {
 				continue
 			}
 
+			var meta registry.Update
+			if err = json.Unmarshal(kv.Value, &meta); err != nil {
+				break
+			}
+
 			switch meta.Op {
 			case registry.Add:
-				al.Nodes[addr] = server.ServiceInfo{
-					Address: addr,
+				node := server.ServiceInfo{}
+				if meta.MetadataX != nil {
+					node = *meta.MetadataX
 				}
+				node.Address = addr
+				al.Nodes[addr] = node
 			case registry.Delete:
 				delete(al.Nodes, addr)
 			}
 		}
+		if err != nil {
+			xlog.Jupiter().Error("unmarshal meta", xlog.FieldErr(err),
+				xlog.FieldExtMessage("value", string(kv.Value), "key", string(kv.Key)))
+		}
 	}
 }
 
@@ -16,7 +16,6 @@ package etcdv3
 
 import (
 	"context"
-	"fmt"
 	"testing"
 	"time"
 
@@ -118,19 +117,44 @@ func Test_etcdv3registry_UpdateAddressList(t *testing.T) {
 			},
 		},
 	}
-	_, err = reg.client.Put(context.Background(), "/jupiter/service_1/configurators/grpc:///routes/1", routeConfig.String())
+	info := &server.ServiceInfo{
+		Name:    "service_3",
+		Scheme:  "grpc",
+		Address: "10.10.10.1:9091",
+		Weight:  10,
+		Group:   "blue",
+	}
+	prefix := info.ServicePrefix()
+	assert.Nil(t, reg.RegisterService(context.Background(), info))
+	_, err = reg.client.Put(context.Background(), prefix+"configurators/1", routeConfig.String())
+	assert.Nil(t, err)
+	_, err = reg.client.Put(context.Background(), prefix+"providers/10.10.10.1:9091", `{"enable":true}`)
 	assert.Nil(t, err)
+
 	ctx, cancel := context.WithCancel(context.Background())
-	go func() {
-		services, err := reg.WatchServices(ctx, "grpc:service_1")
-		assert.Nil(t, err)
-		fmt.Printf("len(services) = %+v\n", len(services))
-		for service := range services {
-			fmt.Printf("service = %+v\n", service)
-		}
-	}()
-	time.Sleep(time.Second * 3)
-	cancel()
+	defer cancel()
+	endpoints, err := reg.WatchServices(ctx, prefix)
+	assert.Nil(t, err)
+
+	endpoint := <-endpoints
+	assert.Equal(t, routeConfig, endpoint.RouteConfigs["1"])
+	assert.True(t, endpoint.ProviderConfigs["10.10.10.1:9091"].Enable)
+	assert.Equal(t, "blue", endpoint.Nodes["10.10.10.1:9091"].Group)
+	assert.Equal(t, float64(10), endpoint.Nodes["10.10.10.1:9091"].Weight)
+
+	services, err := reg.ListServices(context.Background(), prefix)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(services))
+	assert.Equal(t, "blue", services[0].Group)
+
+	_, err = reg.client.Delete(context.Background(), prefix+"configurators/1")
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		endpoint = <-endpoints
+		return len(endpoint.RouteConfigs) == 0
+	}, 3*time.Second, 10*time.Millisecond)
+
+	_, _ = reg.client.Delete(context.Background(), prefix+"providers/10.10.10.1:9091")
 	_ = reg.Close()
 	time.Sleep(time.Second * 1)
 }
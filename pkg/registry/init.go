@@ -15,7 +15,9 @@
 package registry
 
 import (
+	"fmt"
 	"log"
+	"sync"
 
 	"github.com/douyu/jupiter/pkg/conf"
 	"github.com/douyu/jupiter/pkg/core/constant"
@@ -31,10 +33,15 @@ type Config map[string]struct {
 	DeplaySeconds int    `json:"deplaySeconds" description:"延迟注册"`
 }
 
+// local registry shared in process
+var local = &Local{}
+
 // default register
-var DefaultRegisterer Registry = &Local{}
+var DefaultRegisterer Registry = local
 
 func init() {
+	RegisterBuilder("local", func(string) Registry { return local })
+
 	// 初始化注册中心
 	conf.OnLoaded(func(c *conf.Configuration) {
 		xlog.Jupiter().Sugar().Info("hook config, init registry")
@@ -66,6 +73,48 @@ func init() {
 	})
 }
 
+var (
+	mu         sync.Mutex
+	registries = make(map[string]Registry)
+)
+
+// Get returns the registry configured at key, e.g. jupiter.registry.default.
+// It's built by the builder of key.kind (etcdv3 by default) with
+// key.configKey (key itself by default), and shared by key.
+func Get(key string) (reg Registry, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if reg, ok := registries[key]; ok {
+		return reg, nil
+	}
+
+	kind := conf.GetString(key + ".kind")
+	if kind == "" {
+		kind = "etcdv3"
+	}
+	configKey := conf.GetString(key + ".configKey")
+	if configKey == "" {
+		configKey = key
+	}
+
+	build, ok := registryBuilder[kind]
+	if !ok {
+		return nil, fmt.Errorf("invalid registry kind: %s", kind)
+	}
+
+	// builders panic on invalid config
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("build registry %s failed: %v", key, r)
+		}
+	}()
+
+	reg = build(configKey)
+	registries[key] = reg
+	return reg, nil
+}
+
 type Builder func(string) Registry
 
 type BuildFunc func(string) (Registry, error)
@@ -16,38 +16,121 @@ package registry
 
 import (
 	"context"
+	"strings"
+	"sync"
 
 	"github.com/douyu/jupiter/pkg/server"
 	"github.com/douyu/jupiter/pkg/xlog"
 )
 
-// Nop registry, used for local development/debugging
-type Local struct{}
+// Local registry keeps services in memory, used for local development/debugging,
+// services registered are visible to clients in the same process
+type Local struct {
+	mu       sync.RWMutex
+	services map[string]server.ServiceInfo
+	watchers map[*localWatcher]struct{}
+}
+
+type localWatcher struct {
+	prefix string
+	ch     chan Endpoints
+}
 
 // ListServices ...
-func (n Local) ListServices(ctx context.Context, s string) ([]*server.ServiceInfo, error) {
-	panic("implement me")
+func (n *Local) ListServices(ctx context.Context, prefix string) ([]*server.ServiceInfo, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	services := make([]*server.ServiceInfo, 0)
+	for key, info := range n.services {
+		if strings.HasPrefix(key, prefix) {
+			info := info
+			services = append(services, &info)
+		}
+	}
+	return services, nil
 }
 
 // WatchServices ...
-func (n Local) WatchServices(ctx context.Context, s string) (chan Endpoints, error) {
-	panic("implement me")
+func (n *Local) WatchServices(ctx context.Context, prefix string) (chan Endpoints, error) {
+	w := &localWatcher{prefix: prefix, ch: make(chan Endpoints, 10)}
+
+	n.mu.Lock()
+	if n.watchers == nil {
+		n.watchers = make(map[*localWatcher]struct{})
+	}
+	n.watchers[w] = struct{}{}
+	w.ch <- *n.endpoints(prefix)
+	n.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.watchers, w)
+		close(w.ch)
+	}()
+	return w.ch, nil
 }
 
 // RegisterService ...
-func (n Local) RegisterService(ctx context.Context, si *server.ServiceInfo) error {
+func (n *Local) RegisterService(ctx context.Context, si *server.ServiceInfo) error {
 	xlog.Jupiter().Info("register service locally", xlog.FieldMod("registry"), xlog.FieldName(si.Name), xlog.FieldAddr(si.Label()))
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.services == nil {
+		n.services = make(map[string]server.ServiceInfo)
+	}
+	n.services[si.RegistryName()] = *si
+	n.notify(si.RegistryName())
 	return nil
 }
 
 // UnregisterService ...
-func (n Local) UnregisterService(ctx context.Context, si *server.ServiceInfo) error {
+func (n *Local) UnregisterService(ctx context.Context, si *server.ServiceInfo) error {
 	xlog.Jupiter().Info("unregister service locally", xlog.FieldMod("registry"), xlog.FieldName(si.Name), xlog.FieldAddr(si.Label()))
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.services, si.RegistryName())
+	n.notify(si.RegistryName())
 	return nil
 }
 
 // Close ...
-func (n Local) Close() error { return nil }
+func (n *Local) Close() error { return nil }
 
-// Close ...
-func (n Local) Kind() string { return "local" }
+// Kind ...
+func (n *Local) Kind() string { return "local" }
+
+// notify sends the latest endpoints to watchers of key, the stale one
+// is dropped if the watcher is slow. n.mu must be held.
+func (n *Local) notify(key string) {
+	for w := range n.watchers {
+		if !strings.HasPrefix(key, w.prefix) {
+			continue
+		}
+
+		endpoints := *n.endpoints(w.prefix)
+		select {
+		case w.ch <- endpoints:
+		default:
+			select {
+			case <-w.ch:
+			default:
+			}
+			w.ch <- endpoints
+		}
+	}
+}
+
+func (n *Local) endpoints(prefix string) *Endpoints {
+	endpoints := newEndpoints()
+	for key, info := range n.services {
+		if strings.HasPrefix(key, prefix) {
+			endpoints.Nodes[strings.TrimPrefix(key, prefix)] = info
+		}
+	}
+	return endpoints
+}
@@ -22,6 +22,11 @@ type Config struct {
 
 	// ServiceAddress service address in registry info, default to 'Host:Port'
 	ServiceAddress string
+
+	// SocketPath Network为unix时socket文件的路径，服务停止时删除
+	SocketPath string
+	// SocketMode socket文件的权限，如 0660，为空时不修改
+	SocketMode string
 }
 
 // StdConfig represents Standard gRPC Server config
@@ -66,5 +71,8 @@ func (config *Config) Build() *Server {
 
 // Address ...
 func (config Config) Address() string {
+	if config.Network == "unix" {
+		return config.SocketPath
+	}
 	return fmt.Sprintf("%s:%d", config.Host, config.Port)
 }
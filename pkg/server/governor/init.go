@@ -22,6 +22,7 @@ import (
 
 	"github.com/douyu/jupiter/pkg"
 	"github.com/douyu/jupiter/pkg/conf"
+	"github.com/douyu/jupiter/pkg/core/inspect"
 	"github.com/douyu/jupiter/pkg/util/xstring"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -77,6 +78,15 @@ func registerHandlers() {
 		_ = jsoniter.NewEncoder(w).Encode(serverStats)
 	})
 
+	// 列出全部已创建的客户端，配置中的敏感字段已脱敏
+	HandleFunc("/clients", func(w http.ResponseWriter, r *http.Request) {
+		encoder := json.NewEncoder(w)
+		if r.URL.Query().Get("pretty") == "true" {
+			encoder.SetIndent("", "    ")
+		}
+		_ = encoder.Encode(inspect.List())
+	})
+
 	HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
 		promhttp.Handler().ServeHTTP(w, r)
 	})
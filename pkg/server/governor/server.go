@@ -19,7 +19,7 @@ type Server struct {
 }
 
 func newServer(config *Config) *Server {
-	var listener, err = net.Listen("tcp4", config.Address())
+	var listener, err = server.Listen(config.Network, config.Address(), config.SocketMode)
 	if err != nil {
 		xlog.Jupiter().Panic("governor start error", xlog.FieldErr(err))
 	}
@@ -66,6 +66,7 @@ func (s *Server) Info() *server.ServiceInfo {
 	info := server.ApplyOptions(
 		server.WithScheme("govern"),
 		server.WithAddress(xnet.Address(s.listener)),
+		server.WithNetwork(s.listener.Addr().Network()),
 		server.WithKind(constant.ServiceGovernor),
 	)
 	// info.Name = info.Name + "." + ModName
@@ -0,0 +1,145 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package governor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/douyu/jupiter/pkg/util/xnet"
+)
+
+var (
+	smu     sync.RWMutex
+	servers []server.Server
+)
+
+// ServerSummary is an item of /servers
+type ServerSummary struct {
+	Name    string `json:"name"`
+	Scheme  string `json:"scheme"`
+	Address string `json:"address"`
+	Routes  int    `json:"routes"`
+}
+
+func init() {
+	// 列出全部业务服务
+	HandleFunc("/servers", func(w http.ResponseWriter, r *http.Request) {
+		details := describeServers()
+		list := make([]ServerSummary, 0, len(details))
+		for _, d := range details {
+			list = append(list, ServerSummary{
+				Name:    d.Name,
+				Scheme:  d.Scheme,
+				Address: d.Address,
+				Routes:  len(d.Routes),
+			})
+		}
+		writeJSON(w, r, list)
+	})
+
+	// 列出服务的路由及其中间件、拦截器: /servers/{name}/routes
+	HandleFunc("/servers/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/servers/")
+		if !strings.HasSuffix(name, "/routes") {
+			http.NotFound(w, r)
+			return
+		}
+		name = strings.TrimSuffix(name, "/routes")
+
+		for _, d := range describeServers() {
+			if d.Name == name {
+				writeJSON(w, r, d.Routes)
+				return
+			}
+		}
+		http.Error(w, "server not found: "+name, http.StatusNotFound)
+	})
+}
+
+// AddServers adds servers to be listed by /servers, it's called by
+// Application.Serve
+func AddServers(s ...server.Server) {
+	smu.Lock()
+	defer smu.Unlock()
+	servers = append(servers, s...)
+}
+
+// describeServers describes all servers, servers without config name
+// are named by scheme, duplicated names are suffixed by their index.
+func describeServers() []server.Detail {
+	smu.RLock()
+	defer smu.RUnlock()
+
+	details := make([]server.Detail, 0, len(servers))
+	seen := make(map[string]int)
+	for _, s := range servers {
+		var d server.Detail
+		if describer, ok := s.(server.Describer); ok {
+			d = describer.Describe()
+		} else {
+			info := s.Info()
+			d = server.Detail{
+				Scheme:  info.Scheme,
+				Address: info.Address,
+				Routes:  make([]server.RouteInfo, 0),
+			}
+		}
+
+		if d.Name == "" {
+			d.Name = d.Scheme
+		}
+		if n := seen[d.Name]; n > 0 {
+			seen[d.Name]++
+			d.Name = fmt.Sprintf("%s-%d", d.Name, n)
+		} else {
+			seen[d.Name] = 1
+		}
+
+		details = append(details, d)
+	}
+	return details
+}
+
+// Describe implements server.Describer interface.
+func (s *Server) Describe() server.Detail {
+	list := make([]server.RouteInfo, 0, len(routes))
+	for _, pattern := range routes {
+		list = append(list, server.RouteInfo{
+			Method:      "*",
+			Path:        pattern,
+			Middlewares: make([]string, 0),
+		})
+	}
+
+	return server.Detail{
+		Name:    ModName,
+		Scheme:  "govern",
+		Address: xnet.Address(s.listener),
+		Routes:  list,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	encoder := json.NewEncoder(w)
+	if r.URL.Query().Get("pretty") == "true" {
+		encoder.SetIndent("", "    ")
+	}
+	_ = encoder.Encode(v)
+}
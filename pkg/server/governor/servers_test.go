@@ -0,0 +1,72 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package governor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeServer struct {
+	server.Server
+	detail server.Detail
+}
+
+func (s *fakeServer) Describe() server.Detail {
+	return s.detail
+}
+
+func TestServers(t *testing.T) {
+	c := DefaultConfig()
+	c.Port = 0
+	gov := c.Build()
+	defer gov.Stop()
+
+	api := &fakeServer{detail: server.Detail{
+		Name:   "http",
+		Scheme: "http",
+		Routes: []server.RouteInfo{
+			{Method: "GET", Path: "/hello", Handler: "main.hello", Middlewares: []string{"main.auth"}},
+		},
+	}}
+	AddServers(gov, api, api)
+	defer func() { servers = nil }()
+
+	w := httptest.NewRecorder()
+	DefaultServeMux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/servers", nil))
+	var list []ServerSummary
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list, 3)
+	assert.Equal(t, ModName, list[0].Name)
+	assert.Equal(t, len(routes), list[0].Routes)
+	assert.Equal(t, "http", list[1].Name)
+	assert.Equal(t, 1, list[1].Routes)
+	assert.Equal(t, "http-1", list[2].Name)
+
+	w = httptest.NewRecorder()
+	DefaultServeMux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/servers/http/routes", nil))
+	var list2 []server.RouteInfo
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &list2))
+	assert.Equal(t, api.detail.Routes, list2)
+
+	w = httptest.NewRecorder()
+	DefaultServeMux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/servers/unknown/routes", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/douyu/jupiter/pkg/core/graceful"
+)
+
+// Listen 创建服务的listener，支持平滑升级。network为空时使用tcp，为unix时address为socket文件的路径，
+// mode为socket文件的权限，如 0660，为空时不修改；服务停止时删除socket文件
+func Listen(network, address, mode string) (net.Listener, error) {
+	if network == "" {
+		network = "tcp"
+	}
+	listener, err := graceful.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if network != "unix" || mode == "" {
+		return listener, nil
+	}
+
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err == nil {
+		err = os.Chmod(address, os.FileMode(perm))
+	}
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+// RouteInfo describes a route exposed by a server
+type RouteInfo struct {
+	// Method HTTP method, or UNARY/STREAM for gRPC
+	Method string `json:"method"`
+	// Path HTTP path, or full gRPC method name /package.Service/Method
+	Path string `json:"path"`
+	// Handler name of the handler func
+	Handler string `json:"handler,omitempty"`
+	// Middlewares middleware or interceptor chain in execution order,
+	// the handler itself excluded
+	Middlewares []string `json:"middlewares"`
+}
+
+// Detail describes a server and the routes it exposes
+type Detail struct {
+	// Name config name of the server, e.g. "http" for jupiter.server.http
+	Name    string      `json:"name"`
+	Scheme  string      `json:"scheme"`
+	Address string      `json:"address"`
+	Routes  []RouteInfo `json:"routes"`
+}
+
+// Describer is implemented by servers which can list their routes,
+// used by governor /servers
+type Describer interface {
+	Describe() Detail
+}
@@ -50,6 +50,13 @@ type ServiceInfo struct {
 	Deployment string `json:"deployment"`
 	// Group 流量组: 流量在Group之间进行负载均衡
 	Group string `json:"group"`
+	// Network 监听的网络类型，unix socket的服务只能在本机访问，不注册到注册中心
+	Network string `json:"network,omitempty"`
+}
+
+// Local 服务是否只能在本机访问
+func (s *ServiceInfo) Local() bool {
+	return s.Network == "unix"
 }
 
 // RegistryName returns the registry name of the service
@@ -127,6 +134,12 @@ func WithAddress(address string) Option {
 	}
 }
 
+func WithNetwork(network string) Option {
+	return func(c *ServiceInfo) {
+		c.Network = network
+	}
+}
+
 func WithKind(kind constant.ServiceKind) Option {
 	return func(c *ServiceInfo) {
 		c.Kind = kind
@@ -21,6 +21,7 @@ import (
 	"github.com/douyu/jupiter/pkg/conf"
 	"github.com/douyu/jupiter/pkg/core/constant"
 	"github.com/douyu/jupiter/pkg/core/ecode"
+	"github.com/douyu/jupiter/pkg/core/limiter"
 	"github.com/douyu/jupiter/pkg/flag"
 	"github.com/douyu/jupiter/pkg/xlog"
 	"github.com/pkg/errors"
@@ -29,6 +30,8 @@ import (
 
 // Config HTTP config
 type Config struct {
+	// Name server name, listed by governor /servers
+	Name            string
 	Host            string
 	Port            int
 	Deployment      string
@@ -40,7 +43,16 @@ type Config struct {
 	PrivateFile     string
 	EnableTLS       bool
 
+	// Network 监听的网络类型，如 tcp、tcp4、tcp6 和 unix，默认 tcp
+	Network string `json:"network" toml:"network"`
+	// SocketPath Network为unix时socket文件的路径，服务停止时删除
+	SocketPath string
+	// SocketMode socket文件的权限，如 0660，为空时不修改
+	SocketMode string
+
 	SlowQueryThresholdInMilli int64
+	// Limiter 自适应限流，过载时拒绝请求并返回503
+	Limiter limiter.Config
 
 	logger *xlog.Logger
 }
@@ -50,9 +62,11 @@ func DefaultConfig() *Config {
 	return &Config{
 		Host:                      flag.String("host"),
 		Port:                      9091,
+		Network:                   "tcp",
 		Debug:                     false,
 		Deployment:                constant.DefaultDeployment,
 		SlowQueryThresholdInMilli: 500, // 500ms
+		Limiter:                   limiter.DefaultConfig(),
 		logger:                    xlog.Jupiter().Named(ecode.ModEchoServer),
 		EnableTLS:                 false,
 		CertFile:                  "cert.pem",
@@ -62,7 +76,11 @@ func DefaultConfig() *Config {
 
 // StdConfig Jupiter Standard HTTP Server config
 func StdConfig(name string) *Config {
-	return RawConfig(constant.ConfigKey("server." + name))
+	config := RawConfig(constant.ConfigKey("server." + name))
+	if config.Name == "" {
+		config.Name = name
+	}
+	return config
 }
 
 // RawConfig ...
@@ -108,6 +126,11 @@ func (config *Config) Build() (*Server, error) {
 		return nil, err
 	}
 	server.Use(recoveryMiddleware())
+
+	if config.Limiter.Enable {
+		server.Use(limiterMiddleware(limiter.New(config.Name, config.Limiter)))
+	}
+
 	server.Use(slowLogMiddleware(time.Duration(config.SlowQueryThresholdInMilli) * time.Millisecond))
 
 	if !config.DisableMetric {
@@ -127,5 +150,8 @@ func (config *Config) Build() (*Server, error) {
 
 // Address ...
 func (config *Config) Address() string {
+	if config.Network == "unix" {
+		return config.SocketPath
+	}
 	return fmt.Sprintf("%s:%d", config.Host, config.Port)
 }
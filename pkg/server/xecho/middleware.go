@@ -22,6 +22,7 @@ import (
 
 	"github.com/alibaba/sentinel-golang/core/base"
 	"github.com/douyu/jupiter/pkg"
+	"github.com/douyu/jupiter/pkg/core/limiter"
 	"github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/core/sentinel"
 	"github.com/douyu/jupiter/pkg/core/xtrace"
@@ -154,3 +155,17 @@ func sentinelServerInterceptor() echo.MiddlewareFunc {
 		}
 	}
 }
+
+// limiterMiddleware 自适应限流，请求优先级取自header
+func limiterMiddleware(l *limiter.Limiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			done, err := l.Allow(limiter.ParsePriority(c.Request().Header.Get(l.PriorityKey())))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusServiceUnavailable, "server overloaded")
+			}
+			defer done()
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xecho
+
+import (
+	"sort"
+
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/douyu/jupiter/pkg/util/xnet"
+	"github.com/douyu/jupiter/pkg/util/xstring"
+	"github.com/labstack/echo/v4"
+)
+
+// Pre adds middlewares to the chain which is run before router, see echo.Echo.Pre
+func (s *Server) Pre(middleware ...echo.MiddlewareFunc) {
+	s.Echo.Pre(middleware...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.preMiddlewares = append(s.preMiddlewares, middlewareNames(middleware)...)
+}
+
+// Use adds middlewares to the chain which is run after router, see echo.Echo.Use
+func (s *Server) Use(middleware ...echo.MiddlewareFunc) {
+	s.Echo.Use(middleware...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middlewares = append(s.middlewares, middlewareNames(middleware)...)
+}
+
+// onAddRoute records group and route level middlewares, which echo
+// doesn't keep once the route is added.
+func (s *Server) onAddRoute(host string, route echo.Route, handler echo.HandlerFunc, middleware []echo.MiddlewareFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routeMiddlewares[route.Method+" "+route.Path] = middlewareNames(middleware)
+}
+
+// Describe implements server.Describer interface.
+func (s *Server) Describe() server.Detail {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	routes := make([]server.RouteInfo, 0)
+	for _, r := range s.Echo.Routes() {
+		middlewares := make([]string, 0)
+		middlewares = append(middlewares, s.preMiddlewares...)
+		middlewares = append(middlewares, s.middlewares...)
+		middlewares = append(middlewares, s.routeMiddlewares[r.Method+" "+r.Path]...)
+
+		routes = append(routes, server.RouteInfo{
+			Method:      r.Method,
+			Path:        r.Path,
+			Handler:     r.Name,
+			Middlewares: middlewares,
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+
+	return server.Detail{
+		Name:    s.config.Name,
+		Scheme:  "http",
+		Address: xnet.Address(s.listener),
+		Routes:  routes,
+	}
+}
+
+func middlewareNames(middleware []echo.MiddlewareFunc) []string {
+	names := make([]string, 0, len(middleware))
+	for _, m := range middleware {
+		names = append(names, xstring.FunctionName(m))
+	}
+	return names
+}
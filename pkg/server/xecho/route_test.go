@@ -0,0 +1,56 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xecho
+
+import (
+	"testing"
+
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func groupMiddleware(next echo.HandlerFunc) echo.HandlerFunc { return next }
+func hello(c echo.Context) error                             { return nil }
+
+func TestServer_Describe(t *testing.T) {
+	c := DefaultConfig()
+	c.Name = "http"
+	c.Port = 0
+	s := c.MustBuild()
+	defer s.listener.Close()
+
+	s.GET("/hello", hello)
+	s.Group("/api", groupMiddleware).POST("/users/:id", hello)
+
+	detail := s.Describe()
+	assert.Equal(t, "http", detail.Name)
+
+	routes := make(map[string]server.RouteInfo)
+	for _, r := range detail.Routes {
+		routes[r.Method+" "+r.Path] = r
+	}
+
+	// recovery, slowlog, metric, trace and sentinel are used by default
+	get := routes["GET /hello"]
+	assert.Contains(t, get.Handler, "xecho.hello")
+	assert.Len(t, get.Middlewares, 5)
+	assert.Contains(t, get.Middlewares[0], "recoveryMiddleware")
+
+	post := routes["POST /api/users/:id"]
+	assert.Contains(t, post.Handler, "xecho.hello")
+	assert.Len(t, post.Middlewares, 6)
+	assert.Contains(t, post.Middlewares[5], "xecho.groupMiddleware")
+}
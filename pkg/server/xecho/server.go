@@ -22,6 +22,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sync"
 
 	"github.com/douyu/jupiter/pkg/core/constant"
 	"github.com/douyu/jupiter/pkg/server"
@@ -38,6 +39,11 @@ type Server struct {
 	config   *Config
 	listener net.Listener
 	// registerer registry.Registry
+
+	mu               sync.RWMutex
+	preMiddlewares   []string
+	middlewares      []string
+	routeMiddlewares map[string][]string
 }
 
 func newServer(config *Config) (*Server, error) {
@@ -64,20 +70,27 @@ func newServer(config *Config) (*Server, error) {
 		if tlsConfig.Certificates[0], err = tls.X509KeyPair(cert, key); err != nil {
 			return nil, errors.Wrap(err, "X509KeyPair failed")
 		}
-		listener, err = tls.Listen("tcp", config.Address(), tlsConfig)
+		if listener, err = server.Listen(config.Network, config.Address(), config.SocketMode); err == nil {
+			listener = tls.NewListener(listener, tlsConfig)
+		}
 	} else {
-		listener, err = net.Listen("tcp", config.Address())
+		listener, err = server.Listen(config.Network, config.Address(), config.SocketMode)
 	}
 	if err != nil {
 		// config.logger.Panic("new xecho server err", xlog.FieldErrKind(ecode.ErrKindListenErr), xlog.FieldErr(err))
 		return nil, errors.Wrapf(err, "create xecho server failed")
 	}
-	config.Port = listener.Addr().(*net.TCPAddr).Port
-	return &Server{
-		Echo:     echo.New(),
-		config:   config,
-		listener: listener,
-	}, nil
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		config.Port = addr.Port
+	}
+	s := &Server{
+		Echo:             echo.New(),
+		config:           config,
+		listener:         listener,
+		routeMiddlewares: make(map[string][]string),
+	}
+	s.Echo.OnAddRouteHandler = s.onAddRoute
+	return s, nil
 }
 
 func (s *Server) Healthz() bool {
@@ -130,6 +143,7 @@ func (s *Server) Info() *server.ServiceInfo {
 	info := server.ApplyOptions(
 		server.WithScheme("http"),
 		server.WithAddress(xnet.Address(s.listener)),
+		server.WithNetwork(s.listener.Addr().Network()),
 		server.WithKind(constant.ServiceProvider),
 	)
 	// info.Name = info.Name + "." + ModName
@@ -31,6 +31,8 @@ const ModName = "server.fasthttp"
 
 // Config HTTP config
 type Config struct {
+	// Name server name, listed by governor /servers
+	Name              string
 	Host              string
 	Port              int
 	Deployment        string
@@ -44,6 +46,13 @@ type Config struct {
 	PrivateFile    string
 	EnableTLS      bool
 
+	// Network 监听的网络类型，如 tcp、tcp4、tcp6 和 unix，默认 tcp
+	Network string `json:"network" toml:"network"`
+	// SocketPath Network为unix时socket文件的路径，服务停止时删除
+	SocketPath string
+	// SocketMode socket文件的权限，如 0660，为空时不修改
+	SocketMode string
+
 	SlowQueryThresholdInMilli int64
 	ReadBufferSize            int
 	WriteBufferSize           int
@@ -58,6 +67,7 @@ func DefaultConfig() *Config {
 	return &Config{
 		Host:                      flag.String("host"),
 		Port:                      9091,
+		Network:                   "tcp",
 		Debug:                     false,
 		Deployment:                constant.DefaultDeployment,
 		SlowQueryThresholdInMilli: 500,  // 500ms
@@ -75,7 +85,11 @@ func DefaultConfig() *Config {
 
 // StdConfig Jupiter Standard HTTP Server config
 func StdConfig(name string) *Config {
-	return RawConfig(constant.ConfigKey("server." + name))
+	config := RawConfig(constant.ConfigKey("server." + name))
+	if config.Name == "" {
+		config.Name = name
+	}
+	return config
 }
 
 // RawConfig ...
@@ -126,5 +140,8 @@ func (config *Config) Build() (*Server, error) {
 
 // Address ...
 func (config *Config) Address() string {
+	if config.Network == "unix" {
+		return config.SocketPath
+	}
 	return fmt.Sprintf("%s:%d", config.Host, config.Port)
 }
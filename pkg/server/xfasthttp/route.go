@@ -0,0 +1,53 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xfasthttp
+
+import (
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/douyu/jupiter/pkg/util/xnet"
+	"github.com/douyu/jupiter/pkg/util/xstring"
+)
+
+// Describe implements server.Describer interface.
+// fasthttp has no router, all requests go to a single handler.
+func (s *Server) Describe() server.Detail {
+	s.mu.RLock()
+	handler := s.handler
+	if handler == nil {
+		handler = s.Handler
+	}
+	s.mu.RUnlock()
+
+	routes := make([]server.RouteInfo, 0)
+	if handler != nil {
+		routes = append(routes, server.RouteInfo{
+			Method:  "*",
+			Path:    "/*",
+			Handler: xstring.FunctionName(handler),
+			// see Serve, slowLog wraps recovery
+			Middlewares: []string{
+				xstring.FunctionName(slowLogMiddleware),
+				xstring.FunctionName(recoveryMiddleware),
+			},
+		})
+	}
+
+	return server.Detail{
+		Name:    s.config.Name,
+		Scheme:  "http",
+		Address: xnet.Address(s.listener),
+		Routes:  routes,
+	}
+}
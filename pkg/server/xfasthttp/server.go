@@ -20,6 +20,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/douyu/jupiter/pkg/core/constant"
@@ -35,6 +36,10 @@ type Server struct {
 	*fasthttp.Server
 	config   *Config
 	listener net.Listener
+	// mu guards handler and Handler, which are swapped by Serve
+	mu sync.RWMutex
+	// handler the business handler, before wrapped by middlewares
+	handler fasthttp.RequestHandler
 }
 
 func newServer(config *Config) (*Server, error) {
@@ -63,13 +68,15 @@ func newServer(config *Config) (*Server, error) {
 
 	}
 
-	listener, err = net.Listen("tcp", config.Address())
+	listener, err = server.Listen(config.Network, config.Address(), config.SocketMode)
 
 	if err != nil {
 		// config.logger.Panic("new fasthttp server err", xlog.FieldErrKind(ecode.ErrKindListenErr), xlog.FieldErr(err))
 		return nil, errors.Wrapf(err, "create fasthttp server failed")
 	}
-	config.Port = listener.Addr().(*net.TCPAddr).Port
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		config.Port = addr.Port
+	}
 
 	return &Server{
 		Server: &fasthttp.Server{
@@ -92,8 +99,11 @@ func (s *Server) Healthz() bool {
 func (s *Server) Serve() error {
 	var err error
 
+	s.mu.Lock()
+	s.handler = s.Handler
 	s.Handler = recoveryMiddleware(s.config)(s.Handler)
 	s.Handler = slowLogMiddleware(s.config, time.Duration(s.config.SlowQueryThresholdInMilli)*time.Millisecond)(s.Handler)
+	s.mu.Unlock()
 
 	if s.config.EnableTLS {
 		err = s.Server.ServeTLS(s.listener, s.config.CertFile, s.config.PrivateFile)
@@ -125,6 +135,7 @@ func (s *Server) Info() *server.ServiceInfo {
 	info := server.ApplyOptions(
 		server.WithScheme("http"),
 		server.WithAddress(xnet.Address(s.listener)),
+		server.WithNetwork(s.listener.Addr().Network()),
 		server.WithKind(constant.ServiceProvider),
 	)
 	// info.Name = info.Name + "." + ModName
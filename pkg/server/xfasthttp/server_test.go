@@ -19,8 +19,11 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
 )
 
+func hello(ctx *fasthttp.RequestCtx) {}
+
 func Test_Server(t *testing.T) {
 	c := DefaultConfig()
 	c.Port = 0
@@ -33,3 +36,22 @@ func Test_Server(t *testing.T) {
 	assert.NotNil(t, s.Info())
 	s.Stop()
 }
+
+func TestServer_Describe(t *testing.T) {
+	c := DefaultConfig()
+	c.Name = "fasthttp"
+	c.Port = 0
+	s := c.MustBuild()
+	s.Handler = hello
+	go func() {
+		s.Serve()
+	}()
+	defer s.Stop()
+
+	// Describe may be called by governor while Serve wraps the handler
+	detail := s.Describe()
+	assert.Equal(t, "fasthttp", detail.Name)
+	assert.Len(t, detail.Routes, 1)
+	assert.Contains(t, detail.Routes[0].Handler, "xfasthttp.hello")
+	assert.Len(t, detail.Routes[0].Middlewares, 2)
+}
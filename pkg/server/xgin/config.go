@@ -21,6 +21,7 @@ import (
 	"github.com/douyu/jupiter/pkg/conf"
 	"github.com/douyu/jupiter/pkg/core/constant"
 	"github.com/douyu/jupiter/pkg/core/ecode"
+	"github.com/douyu/jupiter/pkg/core/limiter"
 	"github.com/douyu/jupiter/pkg/flag"
 	"github.com/douyu/jupiter/pkg/xlog"
 	"github.com/gin-gonic/gin"
@@ -32,6 +33,8 @@ const ModName = "server.gin"
 
 // Config HTTP config
 type Config struct {
+	// Name server name, listed by governor /servers
+	Name          string
 	Host          string
 	Port          int
 	Deployment    string
@@ -41,7 +44,16 @@ type Config struct {
 	// ServiceAddress service address in registry info, default to 'Host:Port'
 	ServiceAddress string
 
+	// Network 监听的网络类型，如 tcp、tcp4、tcp6 和 unix，默认 tcp
+	Network string `json:"network" toml:"network"`
+	// SocketPath Network为unix时socket文件的路径，服务停止时删除
+	SocketPath string
+	// SocketMode socket文件的权限，如 0660，为空时不修改
+	SocketMode string
+
 	SlowQueryThresholdInMilli int64
+	// Limiter 自适应限流，过载时拒绝请求并返回503
+	Limiter limiter.Config
 
 	logger *xlog.Logger
 }
@@ -51,15 +63,21 @@ func DefaultConfig() *Config {
 	return &Config{
 		Host:                      flag.String("host"),
 		Port:                      9091,
+		Network:                   "tcp",
 		Mode:                      gin.ReleaseMode,
 		SlowQueryThresholdInMilli: 500, // 500ms
+		Limiter:                   limiter.DefaultConfig(),
 		logger:                    xlog.Jupiter().With(xlog.FieldMod(ModName)),
 	}
 }
 
 // StdConfig Jupiter Standard HTTP Server config
 func StdConfig(name string) *Config {
-	return RawConfig(constant.ConfigKey("server." + name))
+	config := RawConfig(constant.ConfigKey("server." + name))
+	if config.Name == "" {
+		config.Name = name
+	}
+	return config
 }
 
 // RawConfig ...
@@ -94,6 +112,11 @@ func (config *Config) WithPort(port int) *Config {
 func (config *Config) MustBuild() *Server {
 	server := newServer(config)
 	server.Use(recoveryMiddleware(config.logger))
+
+	if config.Limiter.Enable {
+		server.Use(limiterMiddleware(limiter.New(config.Name, config.Limiter)))
+	}
+
 	server.Use(slowLogMiddleware(config.logger, time.Duration(config.SlowQueryThresholdInMilli)*time.Millisecond))
 
 	if !config.DisableMetric {
@@ -108,5 +131,8 @@ func (config *Config) MustBuild() *Server {
 
 // Address ...
 func (config *Config) Address() string {
+	if config.Network == "unix" {
+		return config.SocketPath
+	}
 	return fmt.Sprintf("%s:%d", config.Host, config.Port)
 }
@@ -31,6 +31,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/douyu/jupiter/pkg/core/limiter"
 	"github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/xlog"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
@@ -213,3 +214,16 @@ func traceServerInterceptor() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// limiterMiddleware 自适应限流，请求优先级取自header
+func limiterMiddleware(l *limiter.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		done, err := l.Allow(limiter.ParsePriority(c.GetHeader(l.PriorityKey())))
+		if err != nil {
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+		defer done()
+		c.Next()
+	}
+}
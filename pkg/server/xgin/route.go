@@ -0,0 +1,182 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgin
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/douyu/jupiter/pkg/server"
+	"github.com/douyu/jupiter/pkg/util/xnet"
+	"github.com/douyu/jupiter/pkg/util/xstring"
+	"github.com/gin-gonic/gin"
+)
+
+// anyMethods are the methods registered by Any, same as gin
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect,
+	http.MethodTrace,
+}
+
+// Group creates a new router group, see gin.RouterGroup.Group. The routes
+// of the group are described with its middlewares.
+func (s *Server) Group(relativePath string, handlers ...gin.HandlerFunc) *gin.RouterGroup {
+	group := s.Engine.Group(relativePath, handlers...)
+
+	s.mu.Lock()
+	s.groups = append(s.groups, group)
+	s.mu.Unlock()
+	return group
+}
+
+// Handle registers a new request handle, see gin.RouterGroup.Handle
+func (s *Server) Handle(httpMethod, relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return s.Match([]string{httpMethod}, relativePath, handlers...)
+}
+
+// Any registers a route that matches all the HTTP methods, see gin.RouterGroup.Any
+func (s *Server) Any(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return s.Match(anyMethods, relativePath, handlers...)
+}
+
+// Match registers a route that matches the specified methods, see gin.RouterGroup.Match
+func (s *Server) Match(methods []string, relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	routes := s.Engine.Match(methods, relativePath, handlers...)
+	s.recordRoute(methods, joinPaths(s.Engine.BasePath(), relativePath), s.Engine.Handlers, handlers)
+	return routes
+}
+
+// GET is a shortcut for Handle("GET", path, handlers)
+func (s *Server) GET(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return s.Handle(http.MethodGet, relativePath, handlers...)
+}
+
+// POST is a shortcut for Handle("POST", path, handlers)
+func (s *Server) POST(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return s.Handle(http.MethodPost, relativePath, handlers...)
+}
+
+// DELETE is a shortcut for Handle("DELETE", path, handlers)
+func (s *Server) DELETE(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return s.Handle(http.MethodDelete, relativePath, handlers...)
+}
+
+// PATCH is a shortcut for Handle("PATCH", path, handlers)
+func (s *Server) PATCH(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return s.Handle(http.MethodPatch, relativePath, handlers...)
+}
+
+// PUT is a shortcut for Handle("PUT", path, handlers)
+func (s *Server) PUT(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return s.Handle(http.MethodPut, relativePath, handlers...)
+}
+
+// OPTIONS is a shortcut for Handle("OPTIONS", path, handlers)
+func (s *Server) OPTIONS(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return s.Handle(http.MethodOptions, relativePath, handlers...)
+}
+
+// HEAD is a shortcut for Handle("HEAD", path, handlers)
+func (s *Server) HEAD(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return s.Handle(http.MethodHead, relativePath, handlers...)
+}
+
+// recordRoute records the middlewares of a route, which are the global
+// middlewares followed by every handler except the last one.
+func (s *Server) recordRoute(methods []string, absolutePath string, global, handlers gin.HandlersChain) {
+	middlewares := functionNames(global)
+	if len(handlers) > 0 {
+		middlewares = append(middlewares, functionNames(handlers[:len(handlers)-1])...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, method := range methods {
+		s.routeMiddlewares[method+" "+absolutePath] = middlewares
+	}
+}
+
+// Describe implements server.Describer interface.
+func (s *Server) Describe() server.Detail {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	routes := make([]server.RouteInfo, 0)
+	for _, r := range s.Engine.Routes() {
+		route := server.RouteInfo{
+			Method:  r.Method,
+			Path:    r.Path,
+			Handler: r.Handler,
+		}
+		if middlewares, ok := s.routeMiddlewares[r.Method+" "+r.Path]; ok {
+			route.Middlewares = middlewares
+		} else {
+			route.Middlewares = functionNames(s.groupHandlers(r.Path))
+		}
+		routes = append(routes, route)
+	}
+
+	return server.Detail{
+		Name:    s.config.Name,
+		Scheme:  "http",
+		Address: xnet.Address(s.listener),
+		Routes:  routes,
+	}
+}
+
+// groupHandlers returns the middlewares of the innermost group created by
+// Group which contains absolutePath, or the global middlewares if there's
+// none. Routes added to a group are registered by gin directly, so their
+// own middlewares are unknown. s.mu must be held.
+func (s *Server) groupHandlers(absolutePath string) gin.HandlersChain {
+	var found *gin.RouterGroup
+	for _, group := range s.groups {
+		base := group.BasePath()
+		if absolutePath != base && !strings.HasPrefix(absolutePath, strings.TrimSuffix(base, "/")+"/") {
+			continue
+		}
+		if found == nil || len(base) > len(found.BasePath()) {
+			found = group
+		}
+	}
+
+	if found == nil {
+		return s.Engine.Handlers
+	}
+	return found.Handlers
+}
+
+func functionNames(handlers gin.HandlersChain) []string {
+	names := make([]string, 0, len(handlers))
+	for _, h := range handlers {
+		names = append(names, xstring.FunctionName(h))
+	}
+	return names
+}
+
+// joinPaths is the same as gin, which keeps the trailing slash
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+
+	finalPath := path.Join(absolutePath, relativePath)
+	if relativePath[len(relativePath)-1] == '/' && finalPath[len(finalPath)-1] != '/' {
+		return finalPath + "/"
+	}
+	return finalPath
+}
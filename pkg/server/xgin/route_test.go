@@ -0,0 +1,62 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgin
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func globalMiddleware(c *gin.Context) { c.Next() }
+func groupMiddleware(c *gin.Context)  { c.Next() }
+func hello(c *gin.Context)            {}
+
+func TestServer_Describe(t *testing.T) {
+	c := DefaultConfig()
+	c.Name = "http"
+	c.Port = 0
+	s := c.MustBuild()
+	defer s.listener.Close()
+
+	s.Use(globalMiddleware)
+	s.GET("/hello", hello)
+	s.GET("/auth", groupMiddleware, hello)
+	var api *gin.RouterGroup = s.Group("/api", groupMiddleware)
+	api.POST("/users/:id", hello)
+	s.Group("/v1").Use(groupMiddleware).Any("/any/", hello)
+
+	detail := s.Describe()
+	assert.Equal(t, "http", detail.Name)
+	assert.Equal(t, "http", detail.Scheme)
+
+	routes := make(map[string][]string)
+	for _, r := range detail.Routes {
+		assert.Contains(t, r.Handler, "xgin.hello")
+		routes[r.Method+" "+r.Path] = r.Middlewares
+	}
+
+	assert.Len(t, routes, 3+len(anyMethods))
+
+	// MustBuild uses recovery, slowlog, etc. by default
+	get, post := routes["GET /hello"], routes["POST /api/users/:id"]
+	assert.Len(t, post, len(get)+1)
+	assert.Contains(t, get[len(get)-1], "xgin.globalMiddleware")
+	assert.Contains(t, post[len(post)-2], "xgin.globalMiddleware")
+	assert.Contains(t, post[len(post)-1], "xgin.groupMiddleware")
+	assert.Equal(t, post, routes["PUT /v1/any/"])
+	assert.Equal(t, post, routes["GET /auth"])
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"sync"
 
 	"github.com/douyu/jupiter/pkg/core/constant"
 	"github.com/douyu/jupiter/pkg/core/ecode"
@@ -33,19 +34,26 @@ type Server struct {
 	Server   *http.Server
 	config   *Config
 	listener net.Listener
+
+	mu               sync.RWMutex
+	routeMiddlewares map[string][]string
+	groups           []*gin.RouterGroup
 }
 
 func newServer(config *Config) *Server {
-	listener, err := net.Listen("tcp", config.Address())
+	listener, err := server.Listen(config.Network, config.Address(), config.SocketMode)
 	if err != nil {
 		config.logger.Panic("new xgin server err", xlog.FieldErrKind(ecode.ErrKindListenErr), xlog.FieldErr(err))
 	}
-	config.Port = listener.Addr().(*net.TCPAddr).Port
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		config.Port = addr.Port
+	}
 	gin.SetMode(config.Mode)
 	return &Server{
-		Engine:   gin.New(),
-		config:   config,
-		listener: listener,
+		Engine:           gin.New(),
+		config:           config,
+		listener:         listener,
+		routeMiddlewares: make(map[string][]string),
 	}
 }
 
@@ -92,6 +100,7 @@ func (s *Server) Info() *server.ServiceInfo {
 	info := server.ApplyOptions(
 		server.WithScheme("http"),
 		server.WithAddress(xnet.Address(s.listener)),
+		server.WithNetwork(s.listener.Addr().Network()),
 		server.WithKind(constant.ServiceProvider),
 	)
 	// info.Name = info.Name + "." + ModName
@@ -15,9 +15,15 @@
 package xgin
 
 import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,3 +42,32 @@ func Test_Server(t *testing.T) {
 	}()
 	<-stoped
 }
+
+func Test_ServerUnix(t *testing.T) {
+	c := DefaultConfig()
+	c.Network = "unix"
+	c.SocketPath = filepath.Join(t.TempDir(), "gin.sock")
+	s := c.MustBuild()
+	s.GET("/ping", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "pong")
+	})
+	go func() {
+		_ = s.Serve()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	defer s.Stop()
+
+	assert.Equal(t, c.SocketPath, s.Info().Address)
+	assert.True(t, s.Info().Local())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", c.SocketPath)
+		},
+	}}
+	resp, err := client.Get("http://unix/ping")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "pong", string(body))
+}
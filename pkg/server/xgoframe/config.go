@@ -31,6 +31,8 @@ const ModName = "server.goframe"
 
 // Config  HTTP config
 type Config struct {
+	// Name server name, listed by governor /servers
+	Name          string
 	Host          string
 	Port          int
 	Debug         bool
@@ -57,7 +59,11 @@ func DefaultConfig() *Config {
 
 // StdConfig Jupiter Standard HTTP Server config
 func StdConfig(name string) *Config {
-	return RawConfig(constant.ConfigKey("server." + name))
+	config := RawConfig(constant.ConfigKey("server." + name))
+	if config.Name == "" {
+		config.Name = name
+	}
+	return config
 }
 
 // RawConfig ...
@@ -0,0 +1,49 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgoframe
+
+import (
+	"strings"
+
+	"github.com/douyu/jupiter/pkg/server"
+)
+
+// Describe implements server.Describer interface.
+func (s *Server) Describe() server.Detail {
+	routes := make([]server.RouteInfo, 0)
+	for _, item := range s.GetRouterArray() {
+		// global middlewares are listed by goframe as routes too
+		if !item.IsServiceHandler {
+			continue
+		}
+
+		middlewares := make([]string, 0)
+		if item.Middleware != "" {
+			middlewares = strings.Split(item.Middleware, ",")
+		}
+		routes = append(routes, server.RouteInfo{
+			Method:      item.Method,
+			Path:        item.Route,
+			Middlewares: middlewares,
+		})
+	}
+
+	return server.Detail{
+		Name:    s.config.Name,
+		Scheme:  "http",
+		Address: s.config.Address(),
+		Routes:  routes,
+	}
+}
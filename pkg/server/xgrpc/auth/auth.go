@@ -0,0 +1,191 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Auth 认证调用方并按方法做访问控制
+type Auth struct {
+	authenticators []Authenticator
+	// rules 方法路径 "/package.Service/Method"、"/package.Service/" 和 "" 对应的规则
+	rules map[string]*rule
+}
+
+type rule struct {
+	principals map[string]bool
+	roles      map[string]bool
+	public     bool
+}
+
+// New 按 JWT、API Key、mTLS 以及自定义认证方式的顺序认证，第一个返回调用方的认证方式生效
+func New(config Config, authenticators ...Authenticator) (*Auth, error) {
+	a := &Auth{rules: make(map[string]*rule, len(config.Rules))}
+
+	if config.JWT.JWKSFile != "" {
+		jwtAuthenticator, err := NewJWTAuthenticator(config.JWT)
+		if err != nil {
+			return nil, err
+		}
+		a.authenticators = append(a.authenticators, jwtAuthenticator)
+	}
+	if len(config.APIKey.Keys) > 0 {
+		a.authenticators = append(a.authenticators, NewAPIKeyAuthenticator(config.APIKey))
+	}
+	if config.MTLS {
+		a.authenticators = append(a.authenticators, NewMTLSAuthenticator())
+	}
+	a.authenticators = append(a.authenticators, authenticators...)
+	if len(a.authenticators) == 0 {
+		return nil, errors.New("auth: no authenticator configured")
+	}
+
+	for _, r := range config.Rules {
+		path, err := methodPath(r.Method)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := a.rules[path]; ok {
+			return nil, fmt.Errorf("auth: duplicated rule %s", r.Method)
+		}
+		a.rules[path] = &rule{
+			principals: set(r.Principals),
+			roles:      set(r.Roles),
+			public:     r.Public,
+		}
+	}
+	return a, nil
+}
+
+// Check 认证并校验调用方能否访问method，返回存入调用方的context
+func (a *Auth) Check(ctx context.Context, method string) (context.Context, error) {
+	r := a.lookup(method)
+	if r != nil && r.public {
+		// 公开的方法不校验凭证
+		return ctx, nil
+	}
+
+	p, err := a.authenticate(ctx)
+	if err != nil {
+		metric.ServerAuthDeniedCounter.Inc(method, "unauthenticated")
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if p != nil {
+		ctx = NewContext(ctx, p)
+	}
+
+	switch {
+	case p == nil:
+		metric.ServerAuthDeniedCounter.Inc(method, "unauthenticated")
+		return ctx, status.Error(codes.Unauthenticated, "auth: credentials required")
+	case len(a.rules) == 0:
+		// 没有配置规则，只要求通过认证
+		return ctx, nil
+	case r != nil && (r.principals["*"] || r.principals[p.Name] || p.hasRole(r.roles)):
+		return ctx, nil
+	}
+	metric.ServerAuthDeniedCounter.Inc(method, "permission_denied")
+	return ctx, status.Errorf(codes.PermissionDenied, "auth: %s is not allowed to access %s", p.Name, method)
+}
+
+func (a *Auth) authenticate(ctx context.Context) (*Principal, error) {
+	for _, authenticator := range a.authenticators {
+		p, err := authenticator.Authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// lookup 返回最具体的规则，没有匹配的规则时返回nil
+func (a *Auth) lookup(method string) *rule {
+	if r, ok := a.rules[method]; ok {
+		return r
+	}
+	if i := strings.LastIndex(method, "/"); i > 0 {
+		if r, ok := a.rules[method[:i+1]]; ok {
+			return r
+		}
+	}
+	return a.rules[""]
+}
+
+// UnaryServerInterceptor 认证和访问控制
+func (a *Auth) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.Check(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 认证和访问控制
+func (a *Auth) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.Check(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context ...
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func methodPath(name string) (string, error) {
+	if name == "*" {
+		return "", nil
+	}
+
+	service, method, ok := strings.Cut(strings.TrimPrefix(name, "/"), "/")
+	if !strings.HasPrefix(name, "/") || !ok || service == "" || method == "" || strings.Contains(method, "/") {
+		return "", fmt.Errorf("auth: invalid rule method %q, expect /package.Service/Method, /package.Service/* or *", name)
+	}
+	if method == "*" {
+		return "/" + service + "/", nil
+	}
+	return name, nil
+}
+
+func set(list []string) map[string]bool {
+	m := make(map[string]bool, len(list))
+	for _, v := range list {
+		m[v] = true
+	}
+	return m
+}
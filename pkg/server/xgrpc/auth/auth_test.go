@@ -0,0 +1,162 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func writeJWKS(t *testing.T, key *rsa.PublicKey) string {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "k1", "n": %q, "e": %q},
+		{"kty": "oct", "kid": "k2", "k": %q}
+	]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		base64.RawURLEncoding.EncodeToString([]byte("secret")),
+	)
+	assert.Nil(t, os.WriteFile(path, []byte(jwks), 0644))
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) context.Context {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	assert.Nil(t, err)
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+signed))
+}
+
+func TestAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	config := DefaultConfig()
+	config.JWT.JWKSFile = writeJWKS(t, &key.PublicKey)
+	config.JWT.Issuer = "jupiter"
+	config.APIKey.Keys = []APIKey{{Key: "key-1", Name: "job", Roles: []string{"reader"}}}
+	config.Rules = []Rule{
+		{Method: "/helloworld.v1.GreeterService/*", Roles: []string{"admin"}},
+		{Method: "/helloworld.v1.GreeterService/SayHi", Roles: []string{"reader"}},
+		{Method: "/grpc.health.v1.Health/*", Public: true},
+		{Method: "*", Principals: []string{"bob"}},
+	}
+	a, err := New(config)
+	assert.Nil(t, err)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	alice := sign(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{"sub": "alice", "iss": "jupiter", "roles": []string{"admin"}, "exp": exp})
+	bob := sign(t, jwt.SigningMethodHS256, "k2", []byte("secret"), jwt.MapClaims{"sub": "bob", "iss": "jupiter", "roles": "guest", "exp": exp})
+	job := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key-1"))
+
+	ctx, err := a.Check(alice, "/helloworld.v1.GreeterService/SayHello")
+	assert.Nil(t, err)
+	p, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", p.Name)
+	assert.Equal(t, []string{"admin"}, p.Roles)
+	assert.Equal(t, MethodJWT, p.Method)
+
+	denied := metric.ServerAuthDeniedCounter.WithLabelValues("/helloworld.v1.GreeterService/SayHello", "permission_denied")
+	before := testutil.ToFloat64(denied)
+	_, err = a.Check(bob, "/helloworld.v1.GreeterService/SayHello")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, float64(1), testutil.ToFloat64(denied)-before)
+
+	_, err = a.Check(bob, "/other.Service/Method")
+	assert.Nil(t, err)
+
+	// 最具体的规则优先
+	_, err = a.Check(job, "/helloworld.v1.GreeterService/SayHi")
+	assert.Nil(t, err)
+	_, err = a.Check(alice, "/helloworld.v1.GreeterService/SayHi")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = a.Check(context.Background(), "/grpc.health.v1.Health/Check")
+	assert.Nil(t, err)
+	_, err = a.Check(context.Background(), "/other.Service/Method")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// 凭证无效
+	_, err = a.Check(sign(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{"sub": "alice", "iss": "other", "exp": exp}), "/grpc.health.v1.Health/Check")
+	assert.Nil(t, err)
+	_, err = a.Check(sign(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{"sub": "alice", "iss": "other", "exp": exp}), "/other.Service/Method")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = a.Check(sign(t, jwt.SigningMethodRS256, "k1", key, jwt.MapClaims{"iss": "jupiter", "roles": []string{"admin"}, "exp": exp}), "/helloworld.v1.GreeterService/SayHello")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = a.Check(sign(t, jwt.SigningMethodHS256, "k1", []byte("secret"), jwt.MapClaims{"sub": "alice", "iss": "jupiter", "exp": exp}), "/other.Service/Method")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = a.Check(metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key-2")), "/other.Service/Method")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestMTLSAuthenticator(t *testing.T) {
+	uri, _ := url.Parse("spiffe://jupiter/ns/default/sa/job")
+	cert := &x509.Certificate{URIs: []*url.URL{uri}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+	}})
+
+	a, err := New(Config{MTLS: true, Rules: []Rule{{Method: "*", Principals: []string{"spiffe://jupiter/ns/default/sa/job"}}}})
+	assert.Nil(t, err)
+
+	var principal *Principal
+	_, err = a.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			principal, _ = FromContext(ctx)
+			return nil, nil
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, &Principal{Name: "spiffe://jupiter/ns/default/sa/job", Method: MethodMTLS}, principal)
+
+	// 没有客户端证书
+	_, err = a.Check(context.Background(), "/test.Service/Method")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{})
+	assert.NotNil(t, err)
+
+	_, err = New(Config{MTLS: true, Rules: []Rule{{Method: "/invalid"}}})
+	assert.NotNil(t, err)
+
+	_, err = New(Config{JWT: JWTConfig{JWKSFile: "not_exist.json"}})
+	assert.NotNil(t, err)
+}
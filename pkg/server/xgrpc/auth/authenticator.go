@@ -0,0 +1,109 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ErrInvalidCredentials 请求携带的凭证无效
+var ErrInvalidCredentials = errors.New("auth: invalid credentials")
+
+// Authenticator 认证请求的调用方
+type Authenticator interface {
+	// Authenticate 请求没有携带该方式的凭证时返回 nil, nil，凭证无效时返回错误
+	Authenticate(ctx context.Context) (*Principal, error)
+}
+
+// AuthenticatorFunc ...
+type AuthenticatorFunc func(ctx context.Context) (*Principal, error)
+
+// Authenticate ...
+func (fn AuthenticatorFunc) Authenticate(ctx context.Context) (*Principal, error) {
+	return fn(ctx)
+}
+
+type apiKeyAuthenticator struct {
+	header string
+	keys   []APIKey
+}
+
+// NewAPIKeyAuthenticator 校验metadata中的API Key
+func NewAPIKeyAuthenticator(config APIKeyConfig) Authenticator {
+	if config.Header == "" {
+		config.Header = DefaultConfig().APIKey.Header
+	}
+	return &apiKeyAuthenticator{header: config.Header, keys: config.Keys}
+}
+
+// Authenticate ...
+func (a *apiKeyAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	key := metadataValue(ctx, a.header)
+	if key == "" {
+		return nil, nil
+	}
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 {
+			return &Principal{Name: k.Name, Roles: k.Roles, Method: MethodAPIKey}, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// NewMTLSAuthenticator 使用客户端证书的URI SAN、DNS SAN或CN作为调用方
+func NewMTLSAuthenticator() Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) (*Principal, error) {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return nil, nil
+		}
+		info, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok {
+			return nil, nil
+		}
+
+		// 只使用校验过的证书
+		if len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+			return nil, nil
+		}
+		cert := info.State.VerifiedChains[0][0]
+
+		name := cert.Subject.CommonName
+		if len(cert.URIs) > 0 {
+			name = cert.URIs[0].String()
+		} else if len(cert.DNSNames) > 0 {
+			name = cert.DNSNames[0]
+		}
+		if name == "" {
+			return nil, ErrInvalidCredentials
+		}
+		return &Principal{Name: name, Method: MethodMTLS}, nil
+	})
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(key); len(vals) > 0 {
+			return vals[0]
+		}
+	}
+	return ""
+}
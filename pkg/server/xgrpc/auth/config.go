@@ -0,0 +1,74 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+// Config 认证和方法级别的访问控制配置
+type Config struct {
+	// Enable 开启认证，默认false
+	Enable bool
+	// JWT 校验 authorization: Bearer <token>，JWKSFile为空时不启用
+	JWT JWTConfig
+	// APIKey 校验API Key，Keys为空时不启用
+	APIKey APIKeyConfig
+	// MTLS 使用客户端证书的SAN或CN作为调用方，需要服务端开启TLS
+	MTLS bool
+	// Rules 方法级别的访问控制，为空时只要求通过认证
+	Rules []Rule
+}
+
+// JWTConfig ...
+type JWTConfig struct {
+	// JWKSFile 本地JWKS文件，支持RSA、EC和oct(HMAC)密钥
+	JWKSFile string
+	// Issuer 非空时校验iss
+	Issuer string
+	// Audience 非空时校验aud
+	Audience string
+	// RolesClaim 角色所在的claim，数组或以空格分隔的字符串，默认roles
+	RolesClaim string
+}
+
+// APIKeyConfig ...
+type APIKeyConfig struct {
+	// Header API Key所在的metadata，默认x-api-key
+	Header string
+	Keys   []APIKey
+}
+
+// APIKey ...
+type APIKey struct {
+	Key   string
+	Name  string
+	Roles []string
+}
+
+// Rule 访问控制规则，Method 支持 /package.Service/Method、/package.Service/* 和 *，越具体越优先
+type Rule struct {
+	Method string
+	// Principals 允许访问的调用方，* 表示任意已认证的调用方
+	Principals []string
+	// Roles 允许访问的角色
+	Roles []string
+	// Public 允许未认证的请求访问且不校验凭证，如健康检查
+	Public bool
+}
+
+// DefaultConfig ...
+func DefaultConfig() Config {
+	return Config{
+		JWT:    JWTConfig{RolesClaim: "roles"},
+		APIKey: APIKeyConfig{Header: "x-api-key"},
+	}
+}
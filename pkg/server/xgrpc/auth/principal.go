@@ -0,0 +1,60 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import "context"
+
+const (
+	// MethodJWT ...
+	MethodJWT = "jwt"
+	// MethodAPIKey ...
+	MethodAPIKey = "apikey"
+	// MethodMTLS ...
+	MethodMTLS = "mtls"
+)
+
+// Principal 认证后的调用方
+type Principal struct {
+	// Name 调用方标识，JWT的sub、API Key的名称或证书的SAN/CN
+	Name string
+	// Roles 调用方的角色
+	Roles []string
+	// Method 认证方式
+	Method string
+	// Claims JWT的claims
+	Claims map[string]interface{}
+}
+
+type principalKey struct{}
+
+// NewContext 将调用方存入context
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext 返回认证后的调用方
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+func (p *Principal) hasRole(roles map[string]bool) bool {
+	for _, role := range p.Roles {
+		if roles[role] {
+			return true
+		}
+	}
+	return false
+}
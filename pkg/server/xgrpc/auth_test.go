@@ -0,0 +1,144 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cgrpc "github.com/douyu/jupiter/pkg/client/grpc"
+	"github.com/douyu/jupiter/pkg/server/xgrpc/auth"
+	helloworldv1 "github.com/douyu/jupiter/proto/helloworld/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type principalGreeter struct {
+	helloworldv1.UnimplementedGreeterServiceServer
+}
+
+func (principalGreeter) SayHello(ctx context.Context, req *helloworldv1.SayHelloRequest) (*helloworldv1.SayHelloResponse, error) {
+	p, _ := auth.FromContext(ctx)
+	return &helloworldv1.SayHelloResponse{Data: &helloworldv1.SayHelloResponse_Data{Name: p.Name}}, nil
+}
+
+// writeCerts writes a ca and certificates of server.local and client.local
+// signed by it into dir
+func writeCerts(t *testing.T, dir string) {
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.Nil(t, err)
+		return key
+	}
+	write := func(name, typ string, der []byte) {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600))
+	}
+
+	caKey := newKey()
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	assert.Nil(t, err)
+	write("ca.pem", "CERTIFICATE", der)
+	ca, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+
+	for i, name := range []string{"server.local", "client.local"} {
+		usage := x509.ExtKeyUsageServerAuth
+		if name == "client.local" {
+			usage = x509.ExtKeyUsageClientAuth
+		}
+		key := newKey()
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		assert.Nil(t, err)
+		write(name+".pem", "CERTIFICATE", der)
+		keyDer, err := x509.MarshalECPrivateKey(key)
+		assert.Nil(t, err)
+		write(name+".key", "EC PRIVATE KEY", keyDer)
+	}
+}
+
+func TestServer_MTLSAuth(t *testing.T) {
+	dir := t.TempDir()
+	file := func(name string) string { return filepath.Join(dir, name) }
+	writeCerts(t, dir)
+
+	for _, httpEnable := range []bool{false, true} {
+		config := DefaultConfig()
+		config.Host = "127.0.0.1"
+		config.Port = 0
+		config.EnableTLS = true
+		config.CaFile = file("ca.pem")
+		config.CertFile = file("server.local.pem")
+		config.PrivateFile = file("server.local.key")
+		config.HTTP.Enable = httpEnable
+		config.Auth.Enable = true
+		config.Auth.MTLS = true
+		config.Auth.Rules = []auth.Rule{{Method: "*", Principals: []string{"client.local"}}}
+		s := config.MustBuild()
+		helloworldv1.RegisterGreeterServiceServer(s.Server, principalGreeter{})
+		go func() {
+			_ = s.Serve()
+		}()
+
+		cc := cgrpc.DefaultConfig()
+		cc.Addr = s.Address()
+		cc.EnableTLS = true
+		cc.CaFile = file("ca.pem")
+		cc.CertFile = file("client.local.pem")
+		cc.PrivateFile = file("client.local.key")
+		cc.ServerName = "server.local"
+		conn, err := cc.Build()
+		assert.Nil(t, err)
+
+		// 经过真实的TLS握手，校验过的客户端证书作为调用方
+		res, err := helloworldv1.NewGreeterServiceClient(conn).SayHello(context.Background(), &helloworldv1.SayHelloRequest{})
+		assert.Nil(t, err, "http: %v", httpEnable)
+		assert.Equal(t, "client.local", res.GetData().GetName())
+
+		_, err = helloworldv1.NewGreeterServiceClient(conn).SayHi(context.Background(), &helloworldv1.SayHiRequest{})
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+		conn.Close()
+		_ = s.Stop()
+	}
+}
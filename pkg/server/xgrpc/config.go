@@ -16,12 +16,16 @@ package xgrpc
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/douyu/jupiter/pkg/conf"
 	"github.com/douyu/jupiter/pkg/core/constant"
 	"github.com/douyu/jupiter/pkg/core/ecode"
+	"github.com/douyu/jupiter/pkg/core/limiter"
 	"github.com/douyu/jupiter/pkg/flag"
+	"github.com/douyu/jupiter/pkg/server/xgrpc/auth"
 	"github.com/douyu/jupiter/pkg/xlog"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
@@ -32,16 +36,43 @@ type Config struct {
 	Host       string `json:"host"`
 	Port       int    `json:"port"`
 	Deployment string `json:"deployment"`
-	// Network network type, tcp4 by default
+	// Network network type, tcp4 by default, unix for unix domain socket
 	Network string `json:"network" toml:"network"`
+	// SocketPath Network为unix时socket文件的路径，服务停止时删除
+	SocketPath string
+	// SocketMode socket文件的权限，如 0660，为空时不修改
+	SocketMode string
 	// EnableAccessLog enable Access Interceptor, true by default
 	EnableAccessLog bool
+	// AccessInterceptorLevel info 记录所有请求，其他值只记录出错的请求，默认 info
+	AccessInterceptorLevel string
+	// EnableAccessInterceptorReq 访问日志记录请求内容
+	EnableAccessInterceptorReq bool
+	// EnableAccessInterceptorRes 访问日志记录响应内容
+	EnableAccessInterceptorRes bool
+	// AccessInterceptorPayloadLimit 记录的请求和响应内容的最大字节数，超出部分截断，默认1024，0为不限制
+	AccessInterceptorPayloadLimit int
+	// AccessInterceptorRedactFields 请求和响应中需要脱敏的字段名，不区分大小写，如 password
+	AccessInterceptorRedactFields []string
 	// DisableTrace disable Trace Interceptor, false by default
 	DisableTrace bool
 	// DisableMetric disable Metric Interceptor, false by default
 	DisableMetric bool
 	// DisableSentinel disable Sentinel Interceptor, false by default
 	DisableSentinel bool
+	// Limiter 自适应限流，过载时拒绝请求并返回 ResourceExhausted
+	Limiter limiter.Config
+	// EnableValidation 校验请求(PGV生成的Validate方法或xvalidate.Register注册的校验器)，失败时返回 InvalidArgument
+	EnableValidation bool
+	// Auth 认证和方法级别的访问控制，拒绝时返回 Unauthenticated 或 PermissionDenied
+	Auth auth.Config
+	// HTTP 在同一端口上提供HTTP/JSON转码和gRPC-Web
+	HTTP HTTPConfig
+	// Methods 方法级别的配置，key为方法的glob，如 /helloworld.v1.GreeterService/SayHello、
+	// /helloworld.v1.GreeterService/* 和 *，完全匹配优先，其次是更长的glob，配置变化时实时生效
+	Methods map[string]MethodConfig
+	// Shadow 将一定比例的unary请求异步复制到影子服务，用于验证新版本
+	Shadow ShadowConfig
 	// SlowQueryThresholdInMilli, request will be colored if cost over this threshold value
 	SlowQueryThresholdInMilli int64
 	// ServiceAddress service address in registry info, default to 'Host:Port'
@@ -60,15 +91,35 @@ type Config struct {
 	serverOptions      []grpc.ServerOption
 	streamInterceptors []grpc.StreamServerInterceptor
 	unaryInterceptors  []grpc.UnaryServerInterceptor
+	authenticators     []auth.Authenticator
+	gatewayOptions     []runtime.ServeMuxOption
+	shadowClient       grpc.ClientConnInterface
 
 	logger *xlog.Logger
+	// key 配置的key，用于监听方法配置的变化
+	key     string
+	methods *methodTable
+}
+
+// HTTPConfig ...
+type HTTPConfig struct {
+	// Enable 同一端口上接收HTTP/1.1和h2c请求，按content-type分发给gRPC、gRPC-Web和HTTP/JSON转码
+	Enable bool
+	// EnableGRPCWeb 接收浏览器的gRPC-Web请求，默认true
+	EnableGRPCWeb bool
+	// AllowedOrigins 允许跨域访问的Origin，* 表示全部，默认不允许跨域
+	AllowedOrigins []string
 }
 
 // StdConfig represents Standard gRPC Server config
 // which will parse config by conf package,
 // panic if no config key found in conf
 func StdConfig(name string) *Config {
-	return RawConfig(constant.ConfigKey("server." + name))
+	config := RawConfig(constant.ConfigKey("server." + name))
+	if config.Name == "" {
+		config.Name = name
+	}
+	return config
 }
 
 // RawConfig ...
@@ -81,6 +132,7 @@ func RawConfig(key string) *Config {
 			xlog.FieldValueAny(config),
 		)
 	}
+	config.key = key
 	return config
 }
 
@@ -88,19 +140,25 @@ func RawConfig(key string) *Config {
 // User should construct config base on DefaultConfig
 func DefaultConfig() *Config {
 	return &Config{
-		Network:                   "tcp4",
-		Host:                      flag.String("host"),
-		Port:                      9092,
-		Deployment:                constant.DefaultDeployment,
-		EnableAccessLog:           true,
-		DisableMetric:             false,
-		DisableTrace:              false,
-		EnableTLS:                 false,
-		SlowQueryThresholdInMilli: 500,
-		logger:                    xlog.Jupiter().Named(ecode.ModGrpcServer),
-		serverOptions:             []grpc.ServerOption{},
-		streamInterceptors:        []grpc.StreamServerInterceptor{},
-		unaryInterceptors:         []grpc.UnaryServerInterceptor{},
+		Network:                       "tcp4",
+		Host:                          flag.String("host"),
+		Port:                          9092,
+		Deployment:                    constant.DefaultDeployment,
+		EnableAccessLog:               true,
+		AccessInterceptorLevel:        "info",
+		AccessInterceptorPayloadLimit: 1024,
+		DisableMetric:                 false,
+		DisableTrace:                  false,
+		EnableTLS:                     false,
+		SlowQueryThresholdInMilli:     500,
+		Limiter:                       limiter.DefaultConfig(),
+		Auth:                          auth.DefaultConfig(),
+		HTTP:                          HTTPConfig{EnableGRPCWeb: true},
+		Shadow:                        ShadowConfig{Rate: 100, MaxConcurrency: 10, Timeout: time.Second},
+		logger:                        xlog.Jupiter().Named(ecode.ModGrpcServer),
+		serverOptions:                 []grpc.ServerOption{},
+		streamInterceptors:            []grpc.StreamServerInterceptor{},
+		unaryInterceptors:             []grpc.UnaryServerInterceptor{},
 	}
 }
 
@@ -135,6 +193,27 @@ func (config *Config) WithUnaryInterceptor(intes ...grpc.UnaryServerInterceptor)
 	return config
 }
 
+// WithAuthenticator inject custom authenticators, which are tried after
+// the configured ones when Auth is enabled
+func (config *Config) WithAuthenticator(authenticators ...auth.Authenticator) *Config {
+	config.authenticators = append(config.authenticators, authenticators...)
+	return config
+}
+
+// WithGatewayOption inject grpc-gateway options to the HTTP/JSON transcoding mux,
+// such as runtime.WithMarshalerOption and runtime.WithErrorHandler
+func (config *Config) WithGatewayOption(options ...runtime.ServeMuxOption) *Config {
+	config.gatewayOptions = append(config.gatewayOptions, options...)
+	return config
+}
+
+// WithShadowClient inject the client of shadow service, such as a client
+// built by client/grpc, which is required when Shadow is enabled
+func (config *Config) WithShadowClient(cc grpc.ClientConnInterface) *Config {
+	config.shadowClient = cc
+	return config
+}
+
 func (config *Config) MustBuild() *Server {
 	server, err := config.Build()
 	if err != nil {
@@ -157,5 +236,8 @@ func (config *Config) WithLogger(logger *xlog.Logger) *Config {
 
 // Address ...
 func (config Config) Address() string {
+	if config.Network == "unix" {
+		return config.SocketPath
+	}
 	return fmt.Sprintf("%s:%d", config.Host, config.Port)
 }
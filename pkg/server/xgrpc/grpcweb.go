@@ -0,0 +1,129 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+)
+
+// isGRPCWeb 判断是否为gRPC-Web请求
+func isGRPCWeb(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), grpcWebContentType)
+}
+
+// serveGRPCWeb 把gRPC-Web请求转化为gRPC请求，trailer以消息帧的形式写在响应体的最后
+func serveGRPCWeb(handler http.Handler, w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	text := strings.HasPrefix(contentType, grpcWebTextContentType)
+
+	req := r.Clone(r.Context())
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	req.ContentLength = -1
+	req.Header.Del("Content-Length")
+	if text {
+		req.Header.Set("Content-Type", "application/grpc"+strings.TrimPrefix(contentType, grpcWebTextContentType))
+		req.Body = io.NopCloser(base64.NewDecoder(base64.StdEncoding, r.Body))
+	} else {
+		req.Header.Set("Content-Type", "application/grpc"+strings.TrimPrefix(contentType, grpcWebContentType))
+	}
+
+	ww := &grpcWebResponseWriter{
+		ResponseWriter: w,
+		header:         make(http.Header),
+		contentType:    contentType,
+		text:           text,
+	}
+	handler.ServeHTTP(ww, req)
+	ww.finish()
+}
+
+type grpcWebResponseWriter struct {
+	http.ResponseWriter
+	header      http.Header
+	sent        http.Header
+	contentType string
+	text        bool
+}
+
+func (w *grpcWebResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *grpcWebResponseWriter) WriteHeader(code int) {
+	if w.sent != nil {
+		return
+	}
+	w.sent = w.header.Clone()
+	h := w.ResponseWriter.Header()
+	for k, vals := range w.sent {
+		if k == "Trailer" || strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		h[k] = vals
+	}
+	if strings.HasPrefix(h.Get("Content-Type"), "application/grpc") {
+		h.Set("Content-Type", w.contentType)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *grpcWebResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.text {
+		if _, err := w.ResponseWriter.Write([]byte(base64.StdEncoding.EncodeToString(b))); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *grpcWebResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish 写入trailer帧
+func (w *grpcWebResponseWriter) finish() {
+	w.WriteHeader(http.StatusOK)
+	trailer := trailers(w.sent, w.header)
+	if len(trailer) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	for k, vals := range trailer {
+		for _, v := range vals {
+			buf.WriteString(strings.ToLower(k) + ": " + v + "\r\n")
+		}
+	}
+	frame := make([]byte, 5, 5+buf.Len())
+	frame[0] = 1 << 7
+	binary.BigEndian.PutUint32(frame[1:], uint32(buf.Len()))
+	_, _ = w.Write(append(frame, buf.Bytes()...))
+	w.Flush()
+}
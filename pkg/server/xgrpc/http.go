@@ -0,0 +1,151 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/pkg/errors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// GatewayHandler registers grpc-gateway handlers of a service with the
+// in-process client connection, for example:
+//
+//	func(ctx context.Context, mux *runtime.ServeMux, cc grpc.ClientConnInterface) error {
+//		return helloworldv1.RegisterGreeterServiceHandlerClient(ctx, mux, helloworldv1.NewGreeterServiceClient(cc))
+//	}
+type GatewayHandler func(ctx context.Context, mux *runtime.ServeMux, cc grpc.ClientConnInterface) error
+
+// RegisterGateway registers HTTP/JSON transcoding of google.api.http annotations,
+// requests are served in-process by the grpc server with the same interceptors.
+// HTTP.Enable is required.
+func (s *Server) RegisterGateway(handlers ...GatewayHandler) error {
+	if s.gateway == nil {
+		return errors.New("http is not enabled")
+	}
+	cc := &inprocConn{handler: s.Server}
+	for _, handler := range handlers {
+		if err := handler(context.Background(), s.gateway, cc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newHTTPServer 在同一个listener上复用gRPC、gRPC-Web和HTTP/JSON转码
+func (s *Server) newHTTPServer(tlsConfig *tls.Config) error {
+	s.gateway = runtime.NewServeMux(append([]runtime.ServeMuxOption{
+		runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher),
+	}, s.gatewayOptions...)...)
+
+	h2s := &http2.Server{}
+	s.httpServer = &http.Server{Handler: http.HandlerFunc(s.serveHTTP)}
+	if tlsConfig != nil {
+		s.httpServer.TLSConfig = tlsConfig
+	}
+	if err := http2.ConfigureServer(s.httpServer, h2s); err != nil {
+		return errors.Wrap(err, "http2.ConfigureServer failed")
+	}
+	if tlsConfig != nil {
+		s.listener = tls.NewListener(s.listener, s.httpServer.TLSConfig)
+	} else {
+		s.httpServer.Handler = h2c.NewHandler(s.httpServer.Handler, h2s)
+	}
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") && !isGRPCWeb(r) {
+		s.Server.ServeHTTP(w, r)
+		return
+	}
+	if s.cors(w, r) {
+		return
+	}
+	if s.HTTP.EnableGRPCWeb && isGRPCWeb(r) {
+		serveGRPCWeb(s.Server, w, r)
+		return
+	}
+	s.gateway.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), httpRequestKey{}, r)))
+}
+
+// cors 设置跨域响应头，返回true表示已经响应了预检请求
+func (s *Server) cors(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || !s.allowOrigin(origin) {
+		return false
+	}
+
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Add("Vary", "Origin")
+	h.Set("Access-Control-Expose-Headers", "grpc-status, grpc-message, grpc-status-details-bin")
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+
+	h.Set("Access-Control-Allow-Methods", r.Header.Get("Access-Control-Request-Method"))
+	if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		h.Set("Access-Control-Allow-Headers", headers)
+	}
+	h.Set("Access-Control-Max-Age", "600")
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func (s *Server) allowOrigin(origin string) bool {
+	for _, o := range s.HTTP.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// incomingHeaderMatcher 除了grpc-gateway默认的header，其他header也转为metadata，
+// 以便认证、限流等拦截器和gRPC请求的行为一致
+func incomingHeaderMatcher(key string) (string, bool) {
+	if k, ok := runtime.DefaultHeaderMatcher(key); ok {
+		return k, true
+	}
+	k := strings.ToLower(key)
+	switch k {
+	case "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade", "te", "host",
+		"content-length", "content-type":
+		return "", false
+	}
+	if strings.HasPrefix(k, "grpc-") {
+		return "", false
+	}
+	return k, true
+}
+
+// serve 开启HTTP时由http.Server接收连接
+func (s *Server) serve() error {
+	if s.httpServer == nil {
+		return s.Server.Serve(s.listener)
+	}
+	if err := s.httpServer.Serve(s.listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
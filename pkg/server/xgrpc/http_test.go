@@ -0,0 +1,174 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	helloworldv1 "github.com/douyu/jupiter/proto/helloworld/v1"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHTTP(t *testing.T) {
+	var calls []string
+	config := DefaultConfig()
+	config.Host = "127.0.0.1"
+	config.Port = 0
+	config.HTTP.Enable = true
+	config.HTTP.AllowedOrigins = []string{"http://example.com"}
+	config.WithUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		p, _ := peer.FromContext(ctx)
+		calls = append(calls, info.FullMethod+" "+strings.Join(md.Get("x-api-key"), ",")+" "+p.Addr.String()[:10])
+		if len(md.Get("x-fail")) > 0 {
+			return nil, status.Error(codes.Unavailable, "unavailable")
+		}
+		return handler(ctx, req)
+	})
+	s := config.MustBuild()
+	defer s.Stop()
+	helloworldv1.RegisterGreeterServiceServer(s.Server, greeter{})
+	assert.Nil(t, s.RegisterGateway(func(ctx context.Context, mux *runtime.ServeMux, cc grpc.ClientConnInterface) error {
+		return helloworldv1.RegisterGreeterServiceHandlerClient(ctx, mux, helloworldv1.NewGreeterServiceClient(cc))
+	}))
+	go func() { _ = s.Serve() }()
+	addr := "http://" + s.Address()
+
+	t.Run("grpc", func(t *testing.T) {
+		calls = nil
+		conn, err := grpc.Dial(s.Address(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "key")
+		reply, err := helloworldv1.NewGreeterServiceClient(conn).SayHello(ctx, &helloworldv1.SayHelloRequest{Name: "jupiter"})
+		assert.Nil(t, err)
+		assert.Equal(t, "jupiter", reply.Data.Name)
+		_, err = helloworldv1.NewGreeterServiceClient(conn).SayHi(ctx, &helloworldv1.SayHiRequest{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, []string{
+			"/helloworld.v1.GreeterService/SayHello key 127.0.0.1:",
+			"/helloworld.v1.GreeterService/SayHi key 127.0.0.1:",
+		}, calls)
+	})
+
+	t.Run("transcoding", func(t *testing.T) {
+		calls = nil
+		req, _ := http.NewRequest(http.MethodPost, addr+"/v1/helloworld.Greeter/SayHello", strings.NewReader(`{"name":"jupiter"}`))
+		req.Header.Set("X-Api-Key", "key")
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, string(body), `"name":"jupiter"`)
+
+		req, _ = http.NewRequest(http.MethodGet, addr+"/v1/helloworld.Greeter/SayHello/jupiter", nil)
+		req.Header.Set("X-Fail", "1")
+		resp, err = http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+		assert.Equal(t, []string{
+			"/helloworld.v1.GreeterService/SayHello key 127.0.0.1:",
+			"/helloworld.v1.GreeterService/SayHello  127.0.0.1:",
+		}, calls)
+	})
+
+	t.Run("grpc-web", func(t *testing.T) {
+		msg, _ := proto.Marshal(&helloworldv1.SayHelloRequest{Name: "jupiter"})
+		frame := append([]byte{0, 0, 0, 0, 0}, msg...)
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+
+		for _, contentType := range []string{"application/grpc-web+proto", "application/grpc-web-text"} {
+			body := frame
+			if strings.HasPrefix(contentType, grpcWebTextContentType) {
+				body = []byte(base64.StdEncoding.EncodeToString(frame))
+			}
+			resp, err := http.Post(addr+"/helloworld.v1.GreeterService/SayHello", contentType, bytes.NewReader(body))
+			assert.Nil(t, err)
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, contentType, resp.Header.Get("Content-Type"))
+			if strings.HasPrefix(contentType, grpcWebTextContentType) {
+				data = decodeChunks(t, data)
+			}
+
+			// 消息帧和trailer帧
+			n := binary.BigEndian.Uint32(data[1:5])
+			reply := &helloworldv1.SayHelloResponse{}
+			assert.Nil(t, proto.Unmarshal(data[5:5+n], reply))
+			assert.Equal(t, "jupiter", reply.Data.Name)
+			trailer := data[5+n:]
+			assert.Equal(t, byte(0x80), trailer[0])
+			assert.Contains(t, string(trailer[5:]), "grpc-status: 0\r\n")
+		}
+	})
+
+	t.Run("cors", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodOptions, addr+"/helloworld.v1.GreeterService/SayHello", nil)
+		req.Header.Set("Origin", "http://example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, "http://example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "content-type,x-grpc-web", resp.Header.Get("Access-Control-Allow-Headers"))
+
+		req.Header.Set("Origin", "http://evil.com")
+		resp, err = http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+	})
+}
+
+// decodeChunks 解码分段base64编码的响应
+func decodeChunks(t *testing.T, data []byte) []byte {
+	var out []byte
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '=')
+		end := len(data)
+		if i >= 0 {
+			end = i
+			for end < len(data) && data[end] == '=' {
+				end++
+			}
+		}
+		b, err := base64.StdEncoding.DecodeString(string(data[:end]))
+		assert.Nil(t, err)
+		out = append(out, b...)
+		data = data[end:]
+	}
+	return out
+}
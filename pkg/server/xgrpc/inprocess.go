@@ -0,0 +1,360 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// inprocConn 进程内调用grpc.Server，请求经过完整的拦截器链，不需要拨号
+type inprocConn struct {
+	handler http.Handler
+}
+
+var _ grpc.ClientConnInterface = (*inprocConn)(nil)
+
+// httpRequestKey HTTP请求放在context中，进程内调用时沿用原始请求的地址和TLS信息
+type httpRequestKey struct{}
+
+// Invoke implements grpc.ClientConnInterface
+func (c *inprocConn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	cs, err := c.NewStream(ctx, &grpc.StreamDesc{}, method, opts...)
+	if err != nil {
+		return err
+	}
+	if err := cs.SendMsg(args); err != nil && err != io.EOF {
+		return err
+	}
+	if err := cs.CloseSend(); err != nil {
+		return err
+	}
+	if err := cs.RecvMsg(reply); err != nil {
+		return err
+	}
+	// 读取trailer中的状态
+	if err := cs.RecvMsg(reply); err != io.EOF {
+		if err == nil {
+			return status.Error(codes.Internal, "unary call returned more than one response")
+		}
+		return err
+	}
+	return nil
+}
+
+// NewStream implements grpc.ClientConnInterface
+func (c *inprocConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	reqReader, reqWriter := io.Pipe()
+	respReader, respWriter := io.Pipe()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, method, reqReader)
+	if err != nil {
+		cancel()
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	req.Body = reqReader
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("Te", "trailers")
+	if orig, ok := ctx.Value(httpRequestKey{}).(*http.Request); ok {
+		req.RemoteAddr = orig.RemoteAddr
+		req.TLS = orig.TLS
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for k, vals := range md {
+		for _, v := range vals {
+			if strings.HasSuffix(k, "-bin") {
+				v = base64.RawStdEncoding.EncodeToString([]byte(v))
+			}
+			req.Header.Add(k, v)
+		}
+	}
+
+	cs := &inprocClientStream{
+		ctx:        ctx,
+		cancel:     cancel,
+		reqWriter:  reqWriter,
+		respReader: respReader,
+		rw: &inprocResponseWriter{
+			header:     make(http.Header),
+			headerSent: make(chan struct{}),
+			body:       respWriter,
+		},
+		done: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			cs.headerAddr = append(cs.headerAddr, o.HeaderAddr)
+		case grpc.TrailerCallOption:
+			cs.trailerAddr = append(cs.trailerAddr, o.TrailerAddr)
+		}
+	}
+
+	go func() {
+		defer close(cs.done)
+		c.handler.ServeHTTP(cs.rw, req)
+		cs.rw.finish()
+		_ = reqReader.Close()
+		_ = respWriter.Close()
+	}()
+	go func() {
+		// 调用方放弃时解除读写的阻塞
+		select {
+		case <-ctx.Done():
+			reqReader.CloseWithError(ctx.Err())
+			respReader.CloseWithError(ctx.Err())
+		case <-cs.done:
+		}
+	}()
+	return cs, nil
+}
+
+type inprocClientStream struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	reqWriter  *io.PipeWriter
+	respReader *io.PipeReader
+	rw         *inprocResponseWriter
+	done       chan struct{}
+
+	headerAddr  []*metadata.MD
+	trailerAddr []*metadata.MD
+	once        sync.Once
+	err         error
+}
+
+func (s *inprocClientStream) Header() (metadata.MD, error) {
+	select {
+	case <-s.rw.headerSent:
+	case <-s.done:
+	case <-s.ctx.Done():
+		return nil, status.FromContextError(s.ctx.Err()).Err()
+	}
+	return s.rw.headerMD(), nil
+}
+
+func (s *inprocClientStream) Trailer() metadata.MD {
+	select {
+	case <-s.done:
+		return toMetadata(s.rw.trailer)
+	default:
+		return nil
+	}
+}
+
+func (s *inprocClientStream) CloseSend() error {
+	return s.reqWriter.Close()
+}
+
+func (s *inprocClientStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *inprocClientStream) SendMsg(m interface{}) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "message %T is not a proto.Message", m)
+	}
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	frame := make([]byte, 5+len(b))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(b)))
+	copy(frame[5:], b)
+	if _, err := s.reqWriter.Write(frame); err != nil {
+		// 服务端已经结束，状态由RecvMsg返回
+		return io.EOF
+	}
+	return nil
+}
+
+func (s *inprocClientStream) RecvMsg(m interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	var prefix [5]byte
+	if _, err := io.ReadFull(s.respReader, prefix[:]); err != nil {
+		if err != io.EOF {
+			return s.finish(status.FromContextError(err).Err())
+		}
+		return s.finish(s.status())
+	}
+	if prefix[0] != 0 {
+		return s.finish(status.Error(codes.Internal, "compressed message is not supported"))
+	}
+	b := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(s.respReader, b); err != nil {
+		return s.finish(status.Error(codes.Internal, "unexpected end of message"))
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return s.finish(status.Errorf(codes.Internal, "message %T is not a proto.Message", m))
+	}
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return s.finish(status.Error(codes.Internal, err.Error()))
+	}
+	return nil
+}
+
+// finish 结束流，返回io.EOF或者状态错误
+func (s *inprocClientStream) finish(err error) error {
+	s.once.Do(func() {
+		s.err = err
+		s.cancel()
+		<-s.done
+		for _, md := range s.headerAddr {
+			*md = s.rw.headerMD()
+		}
+		for _, md := range s.trailerAddr {
+			*md = toMetadata(s.rw.trailer)
+		}
+	})
+	return s.err
+}
+
+// status 解析trailer中的状态，服务端已经结束
+func (s *inprocClientStream) status() error {
+	<-s.done
+	return statusFromHeader(s.rw.trailer)
+}
+
+// inprocResponseWriter 记录grpc.Server写入的header和trailer，消息写入管道
+type inprocResponseWriter struct {
+	header     http.Header
+	sent       http.Header
+	headerSent chan struct{}
+	body       *io.PipeWriter
+	trailer    http.Header
+}
+
+func (w *inprocResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *inprocResponseWriter) WriteHeader(int) {
+	if w.sent == nil {
+		w.sent = w.header.Clone()
+		close(w.headerSent)
+	}
+}
+
+func (w *inprocResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+func (w *inprocResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
+
+// finish 在handler返回后取出trailer
+func (w *inprocResponseWriter) finish() {
+	w.WriteHeader(http.StatusOK)
+	w.trailer = trailers(w.sent, w.header)
+}
+
+func (w *inprocResponseWriter) headerMD() metadata.MD {
+	select {
+	case <-w.headerSent:
+		return toMetadata(w.sent)
+	default:
+		return metadata.MD{}
+	}
+}
+
+// trailers 返回header发送后写入的trailer
+func trailers(sent, header http.Header) http.Header {
+	trailer := make(http.Header)
+	for _, k := range sent.Values("Trailer") {
+		if vals := header.Values(k); len(vals) > 0 {
+			trailer[http.CanonicalHeaderKey(k)] = vals
+		}
+	}
+	for k, vals := range header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			trailer[http.CanonicalHeaderKey(strings.TrimPrefix(k, http.TrailerPrefix))] = vals
+		}
+	}
+	return trailer
+}
+
+// toMetadata 转化header为metadata，去掉gRPC保留的header
+func toMetadata(h http.Header) metadata.MD {
+	md := metadata.MD{}
+	for k, vals := range h {
+		k = strings.ToLower(k)
+		if k == "content-type" || k == "trailer" || strings.HasPrefix(k, "grpc-") {
+			continue
+		}
+		for _, v := range vals {
+			if strings.HasSuffix(k, "-bin") {
+				if b, err := decodeBinHeader(v); err == nil {
+					v = string(b)
+				}
+			}
+			md.Append(k, v)
+		}
+	}
+	return md
+}
+
+// statusFromHeader 解析grpc-status、grpc-message和grpc-status-details-bin
+func statusFromHeader(h http.Header) error {
+	code, err := strconv.Atoi(h.Get("Grpc-Status"))
+	if err != nil {
+		return status.Error(codes.Internal, "missing grpc-status")
+	}
+	if code == int(codes.OK) {
+		return io.EOF
+	}
+	if v := h.Get("Grpc-Status-Details-Bin"); v != "" {
+		if b, err := decodeBinHeader(v); err == nil {
+			st := &spb.Status{}
+			if err := proto.Unmarshal(b, st); err == nil {
+				return status.ErrorProto(st)
+			}
+		}
+	}
+	msg := h.Get("Grpc-Message")
+	if m, err := url.PathUnescape(msg); err == nil {
+		msg = m
+	}
+	return status.Error(codes.Code(code), msg)
+}
+
+func decodeBinHeader(v string) ([]byte, error) {
+	if len(v)%4 == 0 {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return base64.RawStdEncoding.DecodeString(v)
+}
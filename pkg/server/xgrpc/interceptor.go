@@ -16,17 +16,21 @@ package xgrpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/alibaba/sentinel-golang/core/base"
 	"github.com/douyu/jupiter/pkg/core/ecode"
 	"github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/core/sentinel"
 	"github.com/douyu/jupiter/pkg/core/xtrace"
+	"github.com/douyu/jupiter/pkg/util/xstring"
 	"github.com/douyu/jupiter/pkg/xlog"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -158,23 +162,28 @@ func recoveryStreamServerInterceptor(logger *xlog.Logger) grpc.StreamServerInter
 	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
 		defer func() {
 			if rec := recover(); rec != nil {
+				var cause error
 				switch rec := rec.(type) {
 				case error:
-					err = rec
+					cause = rec
 				default:
-					err = fmt.Errorf("%v", rec)
+					cause = fmt.Errorf("%v", rec)
 				}
 
 				stack := make([]byte, 4096)
 				length := runtime.Stack(stack, false)
 				stack = stack[:length]
 
+				id := correlationID(stream.Context())
 				logger.Error("recovery",
 					xlog.Any("grpc interceptor type", "stream"),
 					xlog.FieldMethod(info.FullMethod),
 					xlog.FieldStack(stack),
-					zap.Any("err", err),
+					zap.Any("err", cause),
+					xlog.String("correlation_id", id),
 				)
+				// 返回Internal，不向客户端暴露panic的内容
+				err = panicStatus(id).Err()
 			}
 		}()
 		return handler(srv, stream)
@@ -186,23 +195,28 @@ func recoveryUnaryServerInterceptor(logger *xlog.Logger) grpc.UnaryServerInterce
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 		defer func() {
 			if rec := recover(); rec != nil {
+				var cause error
 				switch rec := rec.(type) {
 				case error:
-					err = rec
+					cause = rec
 				default:
-					err = fmt.Errorf("%v", rec)
+					cause = fmt.Errorf("%v", rec)
 				}
 
 				stack := make([]byte, 4096)
 				length := runtime.Stack(stack, false)
 				stack = stack[:length]
 
+				id := correlationID(ctx)
 				logger.Error("recovery",
 					xlog.Any("grpc interceptor type", "unary"),
 					xlog.FieldMethod(info.FullMethod),
 					xlog.FieldStack(stack),
-					zap.Any("err", err),
+					zap.Any("err", cause),
+					xlog.String("correlation_id", id),
 				)
+				// 返回Internal，不向客户端暴露panic的内容
+				err = panicStatus(id).Err()
 			}
 		}()
 		return handler(ctx, req)
@@ -210,13 +224,13 @@ func recoveryUnaryServerInterceptor(logger *xlog.Logger) grpc.UnaryServerInterce
 }
 
 // slowLogStreamServerInterceptor logs slow requests for stream
-func slowLogStreamServerInterceptor(logger *xlog.Logger, slowThreshold time.Duration) grpc.StreamServerInterceptor {
+func slowLogStreamServerInterceptor(logger *xlog.Logger, slowThreshold time.Duration, methods *methodTable) grpc.StreamServerInterceptor {
 	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		beg := time.Now()
 		err := handler(srv, stream)
 		cost := time.Since(beg)
 
-		if slowThreshold > 0 && cost >= slowThreshold {
+		if threshold := methods.slowThreshold(info.FullMethod, slowThreshold); threshold > 0 && cost >= threshold {
 			logger.Error("slow",
 				xlog.Any("grpc interceptor type", "stream"),
 				xlog.FieldMethod(info.FullMethod),
@@ -228,13 +242,13 @@ func slowLogStreamServerInterceptor(logger *xlog.Logger, slowThreshold time.Dura
 }
 
 // slowLogUnaryServerInterceptor logs slow requests for unary
-func slowLogUnaryServerInterceptor(logger *xlog.Logger, slowThreshold time.Duration) grpc.UnaryServerInterceptor {
+func slowLogUnaryServerInterceptor(logger *xlog.Logger, slowThreshold time.Duration, methods *methodTable) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 		beg := time.Now()
 		resp, err = handler(ctx, req)
 		cost := time.Since(beg)
 
-		if slowThreshold > 0 && cost >= slowThreshold {
+		if threshold := methods.slowThreshold(info.FullMethod, slowThreshold); threshold > 0 && cost >= threshold {
 			logger.Error("slow",
 				xlog.Any("grpc interceptor type", "unary"),
 				xlog.FieldMethod(info.FullMethod),
@@ -287,7 +301,7 @@ func NewSentinelUnaryServerInterceptor() grpc.UnaryServerInterceptor {
 			sentinel.WithTrafficType(base.Inbound),
 		)
 		if blockerr != nil {
-			return nil, blockerr
+			return nil, blockStatus(blockerr).Err()
 		}
 
 		resp, err := handler(ctx, req)
@@ -296,3 +310,165 @@ func NewSentinelUnaryServerInterceptor() grpc.UnaryServerInterceptor {
 		return resp, err
 	}
 }
+
+// accessLogger logs requests of the server, the fields are the same as
+// the access log of the grpc client
+type accessLogger struct {
+	logger *xlog.Logger
+	name   string
+	level  string
+
+	logReq       bool
+	logRes       bool
+	payloadLimit int
+	redact       map[string]bool
+	methods      *methodTable
+}
+
+func newAccessLogger(config *Config) *accessLogger {
+	al := &accessLogger{
+		logger:       config.logger,
+		name:         config.Name,
+		level:        config.AccessInterceptorLevel,
+		logReq:       config.EnableAccessInterceptorReq,
+		logRes:       config.EnableAccessInterceptorRes,
+		payloadLimit: config.AccessInterceptorPayloadLimit,
+		redact:       make(map[string]bool, len(config.AccessInterceptorRedactFields)),
+		methods:      config.methods,
+	}
+	for _, field := range config.AccessInterceptorRedactFields {
+		al.redact[strings.ToLower(field)] = true
+	}
+	return al
+}
+
+func (al *accessLogger) log(ctx context.Context, err error, fields ...xlog.Field) {
+	spbStatus := ecode.ExtractCodes(err)
+	peer := getPeer(ctx)
+	fields = append(fields,
+		xlog.FieldCode(spbStatus.Code),
+		xlog.FieldName(al.name),
+		xlog.FieldAid(peer["aid"]),
+		xlog.FieldIP(peer["clientIP"]),
+		xlog.FieldHost(peer["host"]),
+		xlog.FieldContext(ctx),
+	)
+
+	switch {
+	case err != nil && spbStatus.Code < ecode.EcodeNum:
+		// 只记录系统级别错误
+		al.logger.Error("access", append(fields, xlog.FieldStringErr(spbStatus.Message))...)
+	case err != nil:
+		// 业务报错只做warning
+		al.logger.Warn("access", append(fields, xlog.FieldStringErr(spbStatus.Message))...)
+	case al.level == "info":
+		al.logger.Info("access", fields...)
+	}
+}
+
+// payload returns the json of obj with redacted fields, which is
+// truncated into a string if it's longer than the payload limit
+func (al *accessLogger) payload(key string, obj interface{}) xlog.Field {
+	data := xstring.JsonBytes(obj)
+	if len(al.redact) > 0 {
+		var value interface{}
+		if json.Unmarshal(data, &value) == nil {
+			data, _ = json.Marshal(al.redacted(value))
+		}
+	}
+
+	if al.payloadLimit > 0 && len(data) > al.payloadLimit {
+		// step back to a rune boundary, not to split a multi-byte character
+		n := al.payloadLimit
+		for n > 0 && !utf8.RuneStart(data[n]) {
+			n--
+		}
+		return xlog.String(key, string(data[:n])+"...(truncated)")
+	}
+	// Reflect rather than Any, which may encode it as a Stringer
+	return xlog.Reflect(key, json.RawMessage(data))
+}
+
+func (al *accessLogger) redacted(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			if al.redact[strings.ToLower(k)] {
+				value[k] = "***"
+				continue
+			}
+			value[k] = al.redacted(v)
+		}
+	case []interface{}:
+		for i, v := range value {
+			value[i] = al.redacted(v)
+		}
+	}
+	return value
+}
+
+// loggerUnaryServerInterceptor gRPC服务端访问日志中间件
+func loggerUnaryServerInterceptor(al *accessLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		beg := time.Now()
+		resp, err := handler(ctx, req)
+
+		fields := []xlog.Field{
+			xlog.FieldType("unary"),
+			xlog.FieldMethod(info.FullMethod),
+			xlog.FieldCost(time.Since(beg)),
+		}
+		logReq, logRes := al.methods.logPayload(info.FullMethod, al.logReq, al.logRes)
+		if logReq {
+			fields = append(fields, al.payload("req", req))
+		}
+		if logRes && err == nil {
+			fields = append(fields, al.payload("reply", resp))
+		}
+		al.log(ctx, err, fields...)
+		return resp, err
+	}
+}
+
+// countedServerStream counts messages of the stream
+type countedServerStream struct {
+	grpc.ServerStream
+	sent     int64
+	received int64
+}
+
+// SendMsg ...
+func (s *countedServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.sent, 1)
+	}
+	return err
+}
+
+// RecvMsg ...
+func (s *countedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.received, 1)
+	}
+	return err
+}
+
+// loggerStreamServerInterceptor gRPC服务端流式调用访问日志中间件，在流结束时记录
+func loggerStreamServerInterceptor(al *accessLogger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		beg := time.Now()
+		stream := &countedServerStream{ServerStream: ss}
+		err := handler(srv, stream)
+
+		al.log(ss.Context(), err,
+			xlog.FieldType("stream"),
+			xlog.FieldMethod(info.FullMethod),
+			xlog.FieldCost(time.Since(beg)),
+			xlog.Int64("sent", atomic.LoadInt64(&stream.sent)),
+			xlog.Int64("received", atomic.LoadInt64(&stream.received)),
+		)
+		return err
+	}
+}
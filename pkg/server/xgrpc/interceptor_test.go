@@ -0,0 +1,115 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/douyu/jupiter/pkg/xlog"
+	helloworldv1 "github.com/douyu/jupiter/proto/helloworld/v1"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type greeter struct {
+	helloworldv1.UnimplementedGreeterServiceServer
+}
+
+func (greeter) SayHello(_ context.Context, req *helloworldv1.SayHelloRequest) (*helloworldv1.SayHelloResponse, error) {
+	return &helloworldv1.SayHelloResponse{
+		Msg:  "hello",
+		Data: &helloworldv1.SayHelloResponse_Data{Name: req.Name},
+	}, nil
+}
+
+func (greeter) SayHi(context.Context, *helloworldv1.SayHiRequest) (*helloworldv1.SayHiResponse, error) {
+	return nil, status.Error(codes.Unavailable, "unavailable")
+}
+
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logConfig := xlog.DefaultConfig()
+	logConfig.Core = core
+
+	config := DefaultConfig()
+	config.Name = "access"
+	config.Host = "127.0.0.1"
+	config.Port = 0
+	config.EnableAccessInterceptorReq = true
+	config.EnableAccessInterceptorRes = true
+	config.AccessInterceptorRedactFields = []string{"Name"}
+	config.WithLogger(logConfig.Build())
+	s := config.MustBuild()
+	defer s.Stop()
+	helloworldv1.RegisterGreeterServiceServer(s.Server, greeter{})
+	go func() { _ = s.Serve() }()
+
+	conn, err := grpc.Dial(s.Address(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := helloworldv1.NewGreeterServiceClient(conn)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "aid", "42", "client-host", "host-1")
+	_, err = client.SayHello(ctx, &helloworldv1.SayHelloRequest{Name: "jupiter"})
+	assert.Nil(t, err)
+	_, err = client.SayHi(ctx, &helloworldv1.SayHiRequest{Name: "jupiter"})
+	assert.NotNil(t, err)
+
+	entries := logs.FilterMessage("access").All()
+	assert.Len(t, entries, 2)
+
+	fields := entries[0].ContextMap()
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	assert.Equal(t, "unary", fields["type"])
+	assert.Equal(t, "/helloworld.v1.GreeterService/SayHello", fields["meth"])
+	assert.Equal(t, int32(0), fields["code"])
+	assert.Equal(t, "access", fields["name"])
+	assert.Equal(t, "42", fields["aid"])
+	assert.Equal(t, "127.0.0.1", fields["ip"])
+	assert.Equal(t, "host-1", fields["host"])
+	assert.JSONEq(t, `{"name": "***", "type": 0, "updateMask": null}`, string(fields["req"].(json.RawMessage)))
+	assert.Contains(t, string(fields["reply"].(json.RawMessage)), `"name":"***"`)
+
+	fields = entries[1].ContextMap()
+	assert.Equal(t, zapcore.ErrorLevel, entries[1].Level)
+	assert.Equal(t, int32(codes.Unavailable), fields["code"])
+	assert.Equal(t, "unavailable", fields["err"])
+	assert.NotContains(t, fields, "reply")
+}
+
+func TestAccessLogger_Payload(t *testing.T) {
+	al := newAccessLogger(&Config{AccessInterceptorPayloadLimit: 10, AccessInterceptorRedactFields: []string{"password"}})
+
+	field := al.payload("req", map[string]interface{}{"list": []interface{}{map[string]interface{}{"Password": "secret"}}})
+	assert.Equal(t, `{"list":[{...(truncated)`, field.String)
+
+	// "名" takes 3 bytes, the limit falls in the middle of it
+	field = al.payload("req", map[string]interface{}{"name": "名字"})
+	assert.Equal(t, `{"name":"...(truncated)`, field.String)
+	assert.True(t, utf8.ValidString(field.String))
+
+	al.payloadLimit = 0
+	field = al.payload("req", map[string]interface{}{"list": []interface{}{map[string]interface{}{"Password": "secret"}}})
+	assert.Equal(t, json.RawMessage(`{"list":[{"Password":"***"}]}`), field.Interface)
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"context"
+
+	"github.com/douyu/jupiter/pkg/core/limiter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// errOverloaded 自适应限流拒绝请求时返回
+var errOverloaded = status.Error(codes.ResourceExhausted, "server overloaded")
+
+// limiterUnaryServerInterceptor 自适应限流，请求优先级取自metadata
+func limiterUnaryServerInterceptor(l *limiter.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		done, err := l.Allow(priority(ctx, l.PriorityKey()))
+		if err != nil {
+			return nil, errOverloaded
+		}
+		defer done()
+		return handler(ctx, req)
+	}
+}
+
+// limiterStreamServerInterceptor 自适应限流，流结束前一直占用并发
+func limiterStreamServerInterceptor(l *limiter.Limiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done, err := l.Allow(priority(ss.Context(), l.PriorityKey()))
+		if err != nil {
+			return errOverloaded
+		}
+		defer done()
+		return handler(srv, ss)
+	}
+}
+
+func priority(ctx context.Context, key string) limiter.Priority {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(key); len(vals) > 0 {
+			return limiter.ParsePriority(vals[0])
+		}
+	}
+	return limiter.PriorityDefault
+}
@@ -0,0 +1,373 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/conf"
+	"github.com/douyu/jupiter/pkg/xlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// MethodConfig 方法级别的配置，未配置的项使用服务级别的配置
+type MethodConfig struct {
+	// Timeout 服务端的处理超时，超时后返回 DeadlineExceeded，客户端的deadline更短时以客户端为准
+	Timeout time.Duration
+	// SlowThreshold 慢日志阈值，覆盖 SlowQueryThresholdInMilli
+	SlowThreshold time.Duration
+	// MaxRecvMsgSize 请求消息的最大字节数(解压后)，超过时返回 ResourceExhausted，只能比服务级别的限制(默认4MB)更小
+	MaxRecvMsgSize int
+	// MaxSendMsgSize 响应消息的最大字节数，超过时返回 ResourceExhausted
+	MaxSendMsgSize int
+	// MaxConcurrency 每个方法的最大并发请求数，超过时返回 ResourceExhausted
+	MaxConcurrency int
+	// LogPayload 访问日志是否记录请求和响应的内容，覆盖 EnableAccessInterceptorReq 和 EnableAccessInterceptorRes
+	LogPayload *bool
+}
+
+// methodGlob 按glob匹配方法的配置
+type methodGlob struct {
+	pattern string
+	config  *MethodConfig
+}
+
+// methodConfigs 方法配置，完全匹配优先，其次是更长的glob
+type methodConfigs struct {
+	exact map[string]*MethodConfig
+	globs []methodGlob
+}
+
+func newMethodConfigs(methods map[string]MethodConfig) (*methodConfigs, error) {
+	mcs := &methodConfigs{exact: make(map[string]*MethodConfig)}
+	for pattern, mc := range methods {
+		mc := mc
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("method config %s: %w", pattern, err)
+		}
+		if mc.Timeout < 0 || mc.SlowThreshold < 0 || mc.MaxRecvMsgSize < 0 || mc.MaxSendMsgSize < 0 || mc.MaxConcurrency < 0 {
+			return nil, fmt.Errorf("method config %s: negative value", pattern)
+		}
+		if !hasMeta(pattern) {
+			mcs.exact[pattern] = &mc
+			continue
+		}
+		mcs.globs = append(mcs.globs, methodGlob{pattern: pattern, config: &mc})
+	}
+	sort.Slice(mcs.globs, func(i, j int) bool {
+		if len(mcs.globs[i].pattern) != len(mcs.globs[j].pattern) {
+			return len(mcs.globs[i].pattern) > len(mcs.globs[j].pattern)
+		}
+		return mcs.globs[i].pattern < mcs.globs[j].pattern
+	})
+	return mcs, nil
+}
+
+// lookup returns the config of method, nil if not configured
+func (mcs *methodConfigs) lookup(method string) *MethodConfig {
+	if mc, ok := mcs.exact[method]; ok {
+		return mc
+	}
+	for _, glob := range mcs.globs {
+		// * 匹配所有方法，path.Match 的 * 不匹配 /
+		if glob.pattern == "*" {
+			return glob.config
+		}
+		if ok, _ := path.Match(glob.pattern, method); ok {
+			return glob.config
+		}
+	}
+	return nil
+}
+
+func hasMeta(pattern string) bool {
+	for _, c := range pattern {
+		switch c {
+		case '*', '?', '[', '\\':
+			return true
+		}
+	}
+	return false
+}
+
+// methodTable 方法配置，配置变化时整体替换，并发数按方法统计
+type methodTable struct {
+	configs  atomic.Value // *methodConfigs
+	inflight sync.Map     // method => *int64
+	unwatch  func()
+}
+
+func newMethodTable(methods map[string]MethodConfig) (*methodTable, error) {
+	mt := &methodTable{}
+	if err := mt.store(methods); err != nil {
+		return nil, err
+	}
+	return mt, nil
+}
+
+func (mt *methodTable) store(methods map[string]MethodConfig) error {
+	mcs, err := newMethodConfigs(methods)
+	if err != nil {
+		return err
+	}
+	mt.configs.Store(mcs)
+	return nil
+}
+
+func (mt *methodTable) lookup(method string) *MethodConfig {
+	if mt == nil {
+		return nil
+	}
+	return mt.configs.Load().(*methodConfigs).lookup(method)
+}
+
+// slowThreshold returns the slow log threshold of method, def if not configured
+func (mt *methodTable) slowThreshold(method string, def time.Duration) time.Duration {
+	if mc := mt.lookup(method); mc != nil && mc.SlowThreshold > 0 {
+		return mc.SlowThreshold
+	}
+	return def
+}
+
+// logPayload returns whether to log the request and response of method
+func (mt *methodTable) logPayload(method string, req, res bool) (bool, bool) {
+	if mc := mt.lookup(method); mc != nil && mc.LogPayload != nil {
+		return *mc.LogPayload, *mc.LogPayload
+	}
+	return req, res
+}
+
+// acquire 占用方法的并发数，超过限制时返回false
+func (mt *methodTable) acquire(method string, limit int) (func(), bool) {
+	v, _ := mt.inflight.LoadOrStore(method, new(int64))
+	n := v.(*int64)
+	if atomic.AddInt64(n, 1) > int64(limit) {
+		atomic.AddInt64(n, -1)
+		return nil, false
+	}
+	return func() { atomic.AddInt64(n, -1) }, true
+}
+
+// watch 重新加载key下新增、修改或删除的方法配置，注册后显式加载一次当前的配置
+func (mt *methodTable) watch(key string, logger *xlog.Logger) {
+	mt.unwatch = conf.Watch(key, func(c *conf.Configuration) {
+		mt.reload(key, c.UnmarshalKey, logger)
+	})
+	if conf.Get(key) != nil {
+		mt.reload(key, conf.UnmarshalKey, logger)
+	}
+}
+
+// stop 注销配置的监听
+func (mt *methodTable) stop() {
+	if mt.unwatch != nil {
+		mt.unwatch()
+	}
+}
+
+func (mt *methodTable) reload(key string, unmarshalKey func(string, interface{}, ...conf.GetOption) error, logger *xlog.Logger) {
+	var methods map[string]MethodConfig
+	if err := unmarshalKey(key, &methods); err != nil {
+		logger.Error("reload method config", xlog.FieldErr(err), xlog.FieldKey(key))
+		return
+	}
+	if err := mt.store(methods); err != nil {
+		logger.Error("reload method config", xlog.FieldErr(err), xlog.FieldKey(key))
+		return
+	}
+	logger.Info("reload method config", xlog.FieldKey(key), xlog.Int("methods", len(methods)))
+}
+
+func errTooManyRequests(method string, limit int) error {
+	return status.Errorf(codes.ResourceExhausted, "%s exceeds max concurrency %d", method, limit)
+}
+
+func checkMsgSize(m interface{}, limit int, direction string) error {
+	if limit <= 0 {
+		return nil
+	}
+	if msg, ok := m.(proto.Message); ok {
+		return checkSize(proto.Size(msg), limit, direction)
+	}
+	return nil
+}
+
+// checkRecvMsgSize 按recvSizeHandler记录的大小检查收到的消息，没有记录时按消息编码后的大小检查
+func checkRecvMsgSize(ctx context.Context, m interface{}, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+	if size, ok := recvSize(ctx); ok {
+		return checkSize(size, limit, "received")
+	}
+	return checkMsgSize(m, limit, "received")
+}
+
+func checkSize(size, limit int, direction string) error {
+	if size > limit {
+		return status.Errorf(codes.ResourceExhausted, "%s message larger than max (%d vs. %d)", direction, size, limit)
+	}
+	return nil
+}
+
+type recvSizeKey struct{}
+
+// recvSizeHandler 记录最近收到的消息解压后的字节数，与服务级别的 MaxRecvMsgSize 口径一致，
+// grpc在解码消息后、调用拦截器或返回RecvMsg前通知InPayload
+type recvSizeHandler struct{}
+
+// TagRPC ...
+func (recvSizeHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	size := new(int64)
+	*size = -1
+	return context.WithValue(ctx, recvSizeKey{}, size)
+}
+
+// HandleRPC ...
+func (recvSizeHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	if in, ok := rs.(*stats.InPayload); ok {
+		if size, ok := ctx.Value(recvSizeKey{}).(*int64); ok {
+			atomic.StoreInt64(size, int64(in.Length))
+		}
+	}
+}
+
+// TagConn ...
+func (recvSizeHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn ...
+func (recvSizeHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+func recvSize(ctx context.Context) (int, bool) {
+	size, ok := ctx.Value(recvSizeKey{}).(*int64)
+	if !ok {
+		return 0, false
+	}
+	n := atomic.LoadInt64(size)
+	return int(n), n >= 0
+}
+
+// deadlineExceeded 处理因服务端超时失败时返回 DeadlineExceeded，超时后仍成功返回的不处理
+func deadlineExceeded(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded {
+		return status.Error(codes.DeadlineExceeded, context.DeadlineExceeded.Error())
+	}
+	return err
+}
+
+// methodUnaryServerInterceptor 方法级别的并发数、超时和消息大小限制
+func methodUnaryServerInterceptor(mt *methodTable) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		mc := mt.lookup(info.FullMethod)
+		if mc == nil {
+			return handler(ctx, req)
+		}
+		if mc.MaxConcurrency > 0 {
+			release, ok := mt.acquire(info.FullMethod, mc.MaxConcurrency)
+			if !ok {
+				return nil, errTooManyRequests(info.FullMethod, mc.MaxConcurrency)
+			}
+			defer release()
+		}
+		if err := checkRecvMsgSize(ctx, req, mc.MaxRecvMsgSize); err != nil {
+			return nil, err
+		}
+		if mc.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, mc.Timeout)
+			defer cancel()
+		}
+
+		resp, err := handler(ctx, req)
+		if mc.Timeout > 0 {
+			err = deadlineExceeded(ctx, err)
+		}
+		if err == nil {
+			if err := checkMsgSize(resp, mc.MaxSendMsgSize, "sent"); err != nil {
+				return nil, err
+			}
+		}
+		return resp, err
+	}
+}
+
+// methodStreamServerInterceptor 方法级别的并发数、超时和消息大小限制，消息大小按每个消息检查
+func methodStreamServerInterceptor(mt *methodTable) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		mc := mt.lookup(info.FullMethod)
+		if mc == nil {
+			return handler(srv, ss)
+		}
+		if mc.MaxConcurrency > 0 {
+			release, ok := mt.acquire(info.FullMethod, mc.MaxConcurrency)
+			if !ok {
+				return errTooManyRequests(info.FullMethod, mc.MaxConcurrency)
+			}
+			defer release()
+		}
+
+		ctx := ss.Context()
+		if mc.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, mc.Timeout)
+			defer cancel()
+		}
+		err := handler(srv, &methodServerStream{ServerStream: ss, ctx: ctx, config: mc})
+		if mc.Timeout > 0 {
+			err = deadlineExceeded(ctx, err)
+		}
+		return err
+	}
+}
+
+type methodServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	config *MethodConfig
+}
+
+func (s *methodServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *methodServerStream) SendMsg(m interface{}) error {
+	if err := checkMsgSize(m, s.config.MaxSendMsgSize, "sent"); err != nil {
+		return err
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *methodServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return checkRecvMsgSize(s.ServerStream.Context(), m, s.config.MaxRecvMsgSize)
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/douyu/jupiter/pkg/conf"
+	helloworldv1 "github.com/douyu/jupiter/proto/helloworld/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+func TestMethodConfigs(t *testing.T) {
+	mcs, err := newMethodConfigs(map[string]MethodConfig{
+		"/helloworld.v1.GreeterService/SayHello": {Timeout: time.Second},
+		"/helloworld.v1.GreeterService/*":        {Timeout: 2 * time.Second},
+		"/helloworld.*/*":                        {Timeout: 3 * time.Second},
+		"*":                                      {Timeout: 4 * time.Second},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, mcs.lookup("/helloworld.v1.GreeterService/SayHello").Timeout)
+	assert.Equal(t, 2*time.Second, mcs.lookup("/helloworld.v1.GreeterService/SayHi").Timeout)
+	assert.Equal(t, 3*time.Second, mcs.lookup("/helloworld.v2.GreeterService/SayHi").Timeout)
+	assert.Equal(t, 4*time.Second, mcs.lookup("/grpc.health.v1.Health/Check").Timeout)
+
+	_, err = newMethodConfigs(map[string]MethodConfig{"/a.B/[": {}})
+	assert.NotNil(t, err)
+	_, err = newMethodConfigs(map[string]MethodConfig{"*": {MaxConcurrency: -1}})
+	assert.NotNil(t, err)
+}
+
+func TestMethodUnaryServerInterceptor(t *testing.T) {
+	mt, err := newMethodTable(map[string]MethodConfig{
+		"/test/Concurrency": {MaxConcurrency: 1},
+		"/test/Timeout":     {Timeout: 10 * time.Millisecond},
+		"/test/Size":        {MaxRecvMsgSize: 10, MaxSendMsgSize: 10},
+	})
+	assert.Nil(t, err)
+	interceptor := methodUnaryServerInterceptor(mt)
+	call := func(method string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+		return interceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+
+	t.Run("concurrency", func(t *testing.T) {
+		entered, release := make(chan struct{}), make(chan struct{})
+		go func() {
+			_, _ = call("/test/Concurrency", nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+				close(entered)
+				<-release
+				return nil, nil
+			})
+		}()
+		<-entered
+		_, err := call("/test/Concurrency", nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		close(release)
+
+		assert.Eventually(t, func() bool {
+			_, err := call("/test/Concurrency", nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+			return err == nil
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := call("/test/Timeout", nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+		// 超时后成功返回或返回其他错误的不处理
+		resp, err := call("/test/Timeout", nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return "ok", nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "ok", resp)
+		_, err = call("/test/Timeout", nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return nil, status.Error(codes.NotFound, "not found")
+		})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("size", func(t *testing.T) {
+		small := &helloworldv1.SayHelloRequest{Name: "a"}
+		large := &helloworldv1.SayHelloRequest{Name: "jupiter framework"}
+		echo := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return req, nil
+		}
+
+		_, err := call("/test/Size", small, echo)
+		assert.Nil(t, err)
+		_, err = call("/test/Size", large, echo)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		_, err = call("/test/Size", small, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return large, nil
+		})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		// 未配置的方法不限制
+		_, err = call("/test/Other", large, echo)
+		assert.Nil(t, err)
+
+		// 按recvSizeHandler记录的大小检查
+		h := recvSizeHandler{}
+		ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: "/test/Size"})
+		h.HandleRPC(ctx, &stats.InPayload{Payload: small, Length: 11})
+		_, err = interceptor(ctx, small, &grpc.UnaryServerInfo{FullMethod: "/test/Size"}, echo)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+}
+
+func TestMethodConfigReload(t *testing.T) {
+	load := func(methods string) {
+		config := `
+[jupiter.server.methods]
+    port = 0
+` + methods
+		assert.Nil(t, conf.LoadFromReader(bytes.NewBufferString(config), toml.Unmarshal))
+	}
+	load(`
+[jupiter.server.methods.methods."/helloworld.v1.GreeterService/*"]
+    timeout = "1s"
+    maxConcurrency = 10
+`)
+
+	s := StdConfig("methods").MustBuild()
+	mc := s.methods.lookup("/helloworld.v1.GreeterService/SayHello")
+	assert.Equal(t, time.Second, mc.Timeout)
+	assert.Equal(t, 10, mc.MaxConcurrency)
+
+	load(`
+[jupiter.server.methods.methods."/helloworld.v1.GreeterService/*"]
+    timeout = "3s"
+    maxConcurrency = 10
+`)
+	assert.Eventually(t, func() bool {
+		return s.methods.lookup("/helloworld.v1.GreeterService/SayHello").Timeout == 3*time.Second
+	}, time.Second, 10*time.Millisecond)
+
+	// 新增更具体的配置，删除原有的配置
+	load(`
+[jupiter.server.methods.methods."/helloworld.v1.GreeterService/SayHi"]
+    timeout = "2s"
+`)
+	assert.Eventually(t, func() bool {
+		mc := s.methods.lookup("/helloworld.v1.GreeterService/SayHi")
+		return mc != nil && mc.Timeout == 2*time.Second && s.methods.lookup("/helloworld.v1.GreeterService/SayHello") == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 停止后不再监听
+	assert.Nil(t, s.Stop())
+	load(`
+[jupiter.server.methods.methods."/helloworld.v1.GreeterService/SayHi"]
+    timeout = "4s"
+`)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 2*time.Second, s.methods.lookup("/helloworld.v1.GreeterService/SayHi").Timeout)
+}
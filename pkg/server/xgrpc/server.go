@@ -17,15 +17,19 @@ package xgrpc
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
-	"io/ioutil"
 	"net"
+	"net/http"
+	"sort"
 	"time"
 
 	"github.com/douyu/jupiter/pkg/core/constant"
+	"github.com/douyu/jupiter/pkg/core/limiter"
 	"github.com/douyu/jupiter/pkg/server"
+	"github.com/douyu/jupiter/pkg/server/xgrpc/auth"
 	"github.com/douyu/jupiter/pkg/util/xnet"
+	"github.com/douyu/jupiter/pkg/util/xtls"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -37,15 +41,28 @@ type Server struct {
 	*grpc.Server
 	listener net.Listener
 	*Config
+
+	unaryInterceptors  []string
+	streamInterceptors []string
+
+	// 开启HTTP时，由httpServer接收连接
+	httpServer *http.Server
+	gateway    *runtime.ServeMux
 }
 
 func newServer(config *Config) (*Server, error) {
 	slowThreshold := time.Duration(config.SlowQueryThresholdInMilli) * time.Millisecond
 
+	methods, err := newMethodTable(config.Methods)
+	if err != nil {
+		return nil, errors.Wrap(err, "newMethodTable failed")
+	}
+	config.methods = methods
+
 	var streamInterceptors = append(
 		[]grpc.StreamServerInterceptor{
 			recoveryStreamServerInterceptor(config.logger),
-			slowLogStreamServerInterceptor(config.logger, slowThreshold),
+			slowLogStreamServerInterceptor(config.logger, slowThreshold, methods),
 		},
 		config.streamInterceptors...,
 	)
@@ -53,11 +70,45 @@ func newServer(config *Config) (*Server, error) {
 	var unaryInterceptors = append(
 		[]grpc.UnaryServerInterceptor{
 			recoveryUnaryServerInterceptor(config.logger),
-			slowLogUnaryServerInterceptor(config.logger, slowThreshold),
+			slowLogUnaryServerInterceptor(config.logger, slowThreshold, methods),
 		},
 		config.unaryInterceptors...,
 	)
 
+	if config.EnableValidation {
+		// after auth, so unauthenticated requests are not validated
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{validateUnaryServerInterceptor}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{validateStreamServerInterceptor}, streamInterceptors...)
+	}
+
+	if config.Auth.Enable {
+		// inside of access log and metric, so denials are logged
+		a, err := auth.New(config.Auth, config.authenticators...)
+		if err != nil {
+			return nil, errors.Wrap(err, "auth.New failed")
+		}
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{a.UnaryServerInterceptor()}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{a.StreamServerInterceptor()}, streamInterceptors...)
+	}
+
+	// 方法级别的限制在认证之前，被拒绝的请求记录在访问日志中
+	unaryInterceptors = append([]grpc.UnaryServerInterceptor{methodUnaryServerInterceptor(methods)}, unaryInterceptors...)
+	streamInterceptors = append([]grpc.StreamServerInterceptor{methodStreamServerInterceptor(methods)}, streamInterceptors...)
+
+	if config.EnableAccessLog {
+		// outside of recovery, so recovered panics are logged as errors
+		al := newAccessLogger(config)
+		unaryInterceptors = append(
+			[]grpc.UnaryServerInterceptor{loggerUnaryServerInterceptor(al)},
+			unaryInterceptors...,
+		)
+
+		streamInterceptors = append(
+			[]grpc.StreamServerInterceptor{loggerStreamServerInterceptor(al)},
+			streamInterceptors...,
+		)
+	}
+
 	if !config.DisableTrace {
 		unaryInterceptors = append(
 			[]grpc.UnaryServerInterceptor{NewTraceUnaryServerInterceptor()},
@@ -82,6 +133,16 @@ func newServer(config *Config) (*Server, error) {
 		)
 	}
 
+	var shadow *shadower
+	if config.Shadow.Enable {
+		if shadow, err = newShadower(config); err != nil {
+			return nil, errors.Wrap(err, "newShadower failed")
+		}
+	}
+	// 在限流之后，被拒绝的请求不复制
+	unaryInterceptors = append([]grpc.UnaryServerInterceptor{shadowUnaryServerInterceptor(shadow)}, unaryInterceptors...)
+	streamInterceptors = append([]grpc.StreamServerInterceptor{shadowStreamServerInterceptor}, streamInterceptors...)
+
 	if !config.DisableSentinel {
 		unaryInterceptors = append(
 			[]grpc.UnaryServerInterceptor{NewSentinelUnaryServerInterceptor()},
@@ -89,51 +150,70 @@ func newServer(config *Config) (*Server, error) {
 		)
 	}
 
+	if config.Limiter.Enable {
+		// 过载时在其他拦截器之前拒绝请求
+		l := limiter.New(config.Name, config.Limiter)
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{limiterUnaryServerInterceptor(l)}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{limiterStreamServerInterceptor(l)}, streamInterceptors...)
+	}
+
+	// 错误转化放在最外层，其他拦截器看到的是handler返回的原始错误
+	unaryInterceptors = append([]grpc.UnaryServerInterceptor{statusUnaryServerInterceptor}, unaryInterceptors...)
+	streamInterceptors = append([]grpc.StreamServerInterceptor{statusStreamServerInterceptor}, streamInterceptors...)
+
+	var tlsConfig *tls.Config
 	if config.EnableTLS {
-		cert, err := tls.LoadX509KeyPair(config.CertFile, config.PrivateFile)
-		if err != nil {
-			return nil, errors.Wrap(err, "tls.LoadX509KeyPair failed")
+		// certificates are reloaded when files change, client certificates
+		// are required and verified by CaFile
+		if config.CaFile == "" {
+			return nil, errors.New("CaFile is required to verify client certificates")
 		}
-
-		certPool := x509.NewCertPool()
-		rootBuf, err := ioutil.ReadFile(config.CaFile)
+		reloader, err := xtls.NewReloader(config.CertFile, config.PrivateFile, config.CaFile)
 		if err != nil {
-			return nil, errors.Wrap(err, "ioutil.ReadFile failed")
-		}
-		if !certPool.AppendCertsFromPEM(rootBuf) {
-			return nil, errors.New("certPool.AppendCertsFromPEM failed")
-		}
-
-		tlsConf := &tls.Config{
-			ClientAuth:   tls.RequireAndVerifyClientCert,
-			Certificates: []tls.Certificate{cert},
-			ClientCAs:    certPool,
+			return nil, errors.Wrap(err, "xtls.NewReloader failed")
 		}
 
+		tlsConfig = reloader.ServerConfig(true)
 		config.serverOptions = append(config.serverOptions,
-			grpc.Creds(credentials.NewTLS(tlsConf)),
+			grpc.Creds(credentials.NewTLS(tlsConfig)),
 		)
 	}
 
 	config.serverOptions = append(config.serverOptions,
+		grpc.StatsHandler(recvSizeHandler{}),
 		grpc.StreamInterceptor(StreamInterceptorChain(streamInterceptors...)),
 		grpc.UnaryInterceptor(UnaryInterceptorChain(unaryInterceptors...)),
 	)
 
 	newServer := grpc.NewServer(config.serverOptions...)
-	listener, err := net.Listen(config.Network, config.Address())
+	listener, err := server.Listen(config.Network, config.Address(), config.SocketMode)
 	if err != nil {
 		return nil, errors.Wrap(err, "net.Listen failed")
 	}
-	config.Port = listener.Addr().(*net.TCPAddr).Port
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		config.Port = addr.Port
+	}
 
 	reflection.Register(newServer)
+	if config.key != "" {
+		methods.watch(config.key+".methods", config.logger)
+	}
 
-	return &Server{
-		Server:   newServer,
-		listener: listener,
-		Config:   config,
-	}, nil
+	s := &Server{
+		Server:             newServer,
+		listener:           listener,
+		Config:             config,
+		unaryInterceptors:  funcNames(unaryInterceptors),
+		streamInterceptors: funcNames(streamInterceptors),
+	}
+	if config.HTTP.Enable {
+		if err := s.newHTTPServer(tlsConfig); err != nil {
+			methods.stop()
+			_ = listener.Close()
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
 func (s *Server) Healthz() bool {
@@ -150,13 +230,16 @@ func (s *Server) Serve() error {
 	}
 	// display grpc server addr
 	fmt.Printf("[GRPC] \x1b[33m%8s\x1b[0m %s\n", "Listen On", s.listener.Addr().String())
-	err := s.Server.Serve(s.listener)
-	return err
+	return s.serve()
 }
 
 // Stop implements server.Server interface
 // it will terminate echo server immediately
 func (s *Server) Stop() error {
+	s.methods.stop()
+	if s.httpServer != nil {
+		_ = s.httpServer.Close()
+	}
 	s.Server.Stop()
 	return nil
 }
@@ -164,8 +247,14 @@ func (s *Server) Stop() error {
 // GracefulStop implements server.Server interface
 // it will stop echo server gracefully
 func (s *Server) GracefulStop(ctx context.Context) error {
+	s.methods.stop()
+	var err error
+	if s.httpServer != nil {
+		// 停止接收连接，HTTP/2连接收到GOAWAY，进行中的gRPC请求由GracefulStop等待
+		err = s.httpServer.Shutdown(ctx)
+	}
 	s.Server.GracefulStop()
-	return nil
+	return err
 }
 
 // Info returns server info, used by governor and consumer balancer
@@ -173,7 +262,37 @@ func (s *Server) Info() *server.ServiceInfo {
 	info := server.ApplyOptions(
 		server.WithScheme("grpc"),
 		server.WithAddress(xnet.Address(s.listener)),
+		server.WithNetwork(s.listener.Addr().Network()),
 		server.WithKind(constant.ServiceProvider),
 	)
 	return &info
 }
+
+// Describe implements server.Describer interface.
+func (s *Server) Describe() server.Detail {
+	routes := make([]server.RouteInfo, 0)
+	for name, info := range s.GetServiceInfo() {
+		for _, method := range info.Methods {
+			route := server.RouteInfo{
+				Method:      "UNARY",
+				Path:        "/" + name + "/" + method.Name,
+				Middlewares: s.unaryInterceptors,
+			}
+			if method.IsClientStream || method.IsServerStream {
+				route.Method = "STREAM"
+				route.Middlewares = s.streamInterceptors
+			}
+			routes = append(routes, route)
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+
+	return server.Detail{
+		Name:    s.Config.Name,
+		Scheme:  "grpc",
+		Address: xnet.Address(s.listener),
+		Routes:  routes,
+	}
+}
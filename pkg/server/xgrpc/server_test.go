@@ -17,9 +17,12 @@ package xgrpc
 import (
 	"context"
 	"net"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	cgrpc "github.com/douyu/jupiter/pkg/client/grpc"
 	"github.com/douyu/jupiter/pkg/core/constant"
 	"github.com/douyu/jupiter/pkg/xlog"
 	helloworldv1 "github.com/douyu/jupiter/proto/helloworld/v1"
@@ -175,9 +178,68 @@ func TestServer_Info(t *testing.T) {
 	})
 }
 
+func TestServer_Unix(t *testing.T) {
+	config := DefaultConfig()
+	config.Network = "unix"
+	config.SocketPath = filepath.Join(t.TempDir(), "grpc.sock")
+	config.SocketMode = "0660"
+	s := config.MustBuild()
+	helloworldv1.RegisterGreeterServiceServer(s.Server, new(helloworldv1.FooServer))
+	go func() {
+		_ = s.Serve()
+	}()
+
+	fi, err := os.Stat(config.SocketPath)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0660), fi.Mode().Perm())
+	assert.Equal(t, config.SocketPath, s.Info().Address)
+	assert.True(t, s.Info().Local())
+
+	// 客户端使用 unix:// 地址，拦截器与tcp相同
+	cc := cgrpc.DefaultConfig()
+	cc.Addr = "unix://" + config.SocketPath
+	conn, err := cc.Build()
+	assert.Nil(t, err)
+	defer conn.Close()
+	res, err := helloworldv1.NewGreeterServiceClient(conn).SayHello(context.Background(), &helloworldv1.SayHelloRequest{Name: "unix"})
+	assert.Nil(t, err)
+	assert.Equal(t, "unix", res.GetData().GetName())
+
+	// 停止后删除socket文件
+	assert.Nil(t, s.GracefulStop(context.Background()))
+	_, err = os.Stat(config.SocketPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
 func errorDesc(err error) string {
 	if s, ok := status.FromError(err); ok {
 		return s.Message()
 	}
 	return err.Error()
 }
+
+func TestServer_Describe(t *testing.T) {
+	config := DefaultConfig()
+	config.Name = "grpc"
+	config.Port = 0
+	s := config.MustBuild()
+	defer s.Stop()
+
+	helloworldv1.RegisterGreeterServiceServer(s.Server, struct {
+		helloworldv1.GreeterServiceServer
+	}{})
+
+	detail := s.Describe()
+	assert.Equal(t, "grpc", detail.Name)
+	assert.Equal(t, "grpc", detail.Scheme)
+
+	var found bool
+	for _, route := range detail.Routes {
+		if route.Path == "/helloworld.v1.GreeterService/SayHello" {
+			found = true
+			assert.Equal(t, "UNARY", route.Method)
+			assert.NotEmpty(t, route.Middlewares)
+		}
+	}
+	assert.True(t, found)
+}
@@ -0,0 +1,271 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/imeta"
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/douyu/jupiter/pkg/xlog"
+	"github.com/juju/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// ShadowConfig 流量复制的配置
+type ShadowConfig struct {
+	// Enable 将一定比例的unary请求异步复制到影子服务，影子请求的结果不影响调用方，
+	// 影子服务的客户端通过 WithShadowClient 设置
+	Enable bool
+	// Percent 复制的请求比例，0-100
+	Percent float64
+	// Methods 复制的方法的glob，如 /helloworld.v1.GreeterService/*，为空时复制所有方法
+	Methods []string
+	// Rate 每秒最多复制的请求数，默认100
+	Rate int
+	// MaxConcurrency 同时进行的影子请求的最大数量，默认10
+	MaxConcurrency int
+	// Timeout 影子请求的超时，默认1s
+	Timeout time.Duration
+	// Compare 对比影子响应和原响应的错误码和内容，不一致时记录指标和日志
+	Compare bool
+}
+
+// shadower 复制请求到影子服务，超过速率或并发限制时丢弃
+type shadower struct {
+	config ShadowConfig
+	cc     grpc.ClientConnInterface
+	bucket *ratelimit.Bucket
+	sem    chan struct{}
+	logger *xlog.Logger
+	// replies method => protoreflect.MessageType
+	replies sync.Map
+}
+
+func newShadower(config *Config) (*shadower, error) {
+	sc := config.Shadow
+	if config.shadowClient == nil {
+		return nil, errors.New("shadow client is required, set by WithShadowClient")
+	}
+	if sc.Percent < 0 || sc.Percent > 100 {
+		return nil, fmt.Errorf("shadow percent %v out of range [0, 100]", sc.Percent)
+	}
+	if sc.Rate <= 0 || sc.MaxConcurrency <= 0 || sc.Timeout <= 0 {
+		return nil, errors.New("shadow rate, maxConcurrency and timeout must be positive")
+	}
+	for _, pattern := range sc.Methods {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("shadow method %s: %w", pattern, err)
+		}
+	}
+
+	return &shadower{
+		config: sc,
+		cc:     config.shadowClient,
+		bucket: ratelimit.NewBucketWithRate(float64(sc.Rate), int64(sc.Rate)),
+		sem:    make(chan struct{}, sc.MaxConcurrency),
+		logger: config.logger,
+	}, nil
+}
+
+// sample 按方法和比例选择复制的请求，并占用速率
+func (s *shadower) sample(method string) bool {
+	if !s.match(method) || rand.Float64()*100 >= s.config.Percent {
+		return false
+	}
+	if s.bucket.TakeAvailable(1) == 0 {
+		metric.ServerShadowCounter.Inc(method, "rate_limited")
+		return false
+	}
+	return true
+}
+
+func (s *shadower) match(method string) bool {
+	if len(s.config.Methods) == 0 {
+		return true
+	}
+	for _, pattern := range s.config.Methods {
+		// * 匹配所有方法，path.Match 的 * 不匹配 /
+		if pattern == "*" || pattern == method {
+			return true
+		}
+		if ok, _ := path.Match(pattern, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mirror 异步发送影子请求，不继承调用方的deadline和取消
+func (s *shadower) mirror(ctx context.Context, method string, req proto.Message, resp interface{}, err error) {
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		metric.ServerShadowCounter.Inc(method, "concurrency_limited")
+		return
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = outgoingMD(md)
+	go func() {
+		defer func() { <-s.sem }()
+
+		reply := s.newReply(method, resp)
+		if reply == nil {
+			metric.ServerShadowCounter.Inc(method, "error")
+			return
+		}
+		sctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(context.Background(), md), s.config.Timeout)
+		defer cancel()
+		serr := s.cc.Invoke(sctx, method, req, reply)
+		if !s.config.Compare {
+			if serr != nil {
+				metric.ServerShadowCounter.Inc(method, "error")
+				return
+			}
+			metric.ServerShadowCounter.Inc(method, "ok")
+			return
+		}
+		s.compare(method, resp, toStatusError(err), reply, serr)
+	}()
+}
+
+// compare 对比错误码，都成功时对比响应内容
+func (s *shadower) compare(method string, resp interface{}, err error, reply proto.Message, serr error) {
+	code, scode := status.Code(err), status.Code(serr)
+	var equal bool
+	if code == scode {
+		if msg, ok := resp.(proto.Message); ok && err == nil {
+			equal = proto.Equal(msg, reply)
+		} else {
+			equal = err != nil
+		}
+	}
+	if equal {
+		metric.ServerShadowCounter.Inc(method, "match")
+		return
+	}
+	metric.ServerShadowCounter.Inc(method, "mismatch")
+	s.logger.Warn("shadow mismatch",
+		xlog.FieldMethod(method),
+		xlog.String("code", code.String()),
+		xlog.String("shadowCode", scode.String()),
+		xlog.FieldErr(serr),
+	)
+}
+
+// newReply 创建影子响应，原请求失败时按方法的描述查找响应类型
+func (s *shadower) newReply(method string, resp interface{}) proto.Message {
+	if msg, ok := resp.(proto.Message); ok && msg != nil {
+		return msg.ProtoReflect().New().Interface()
+	}
+	if mt, ok := s.replies.Load(method); ok {
+		return mt.(protoreflect.MessageType).New().Interface()
+	}
+	mt, err := replyType(method)
+	if err != nil {
+		return nil
+	}
+	s.replies.Store(method, mt)
+	return mt.New().Interface()
+}
+
+// replyType 查找 /package.Service/Method 的响应类型
+func replyType(method string) (protoreflect.MessageType, error) {
+	i := strings.LastIndex(method, "/")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid method %s", method)
+	}
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(method[1:i]))
+	if err != nil {
+		return nil, err
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", method[1:i])
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method[i+1:]))
+	if md == nil {
+		return nil, fmt.Errorf("method %s not found", method)
+	}
+	return protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+}
+
+// outgoingMD 影子请求的metadata，去掉伪header并加上影子流量标记
+func outgoingMD(in metadata.MD) metadata.MD {
+	md := make(metadata.MD, len(in)+1)
+	for k, v := range in {
+		if !strings.HasPrefix(k, ":") {
+			md[k] = v
+		}
+	}
+	md.Set(imeta.KeyShadow, "1")
+	return md
+}
+
+// isShadow 请求是否为影子流量
+func isShadow(ctx context.Context) bool {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		return imeta.MD(md).IsShadow()
+	}
+	return false
+}
+
+// withShadow 将影子流量标记加入imeta，下游通过 MD.IsShadow 判断
+func withShadow(ctx context.Context) context.Context {
+	md, _ := imeta.FromContext(ctx)
+	md = md.Copy()
+	md.Set(imeta.KeyShadow, "1")
+	return imeta.WithContext(ctx, md)
+}
+
+// shadowUnaryServerInterceptor 标记影子流量，并按配置复制请求到影子服务，影子流量不再复制
+func shadowUnaryServerInterceptor(s *shadower) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isShadow(ctx) {
+			return handler(withShadow(ctx), req)
+		}
+		msg, ok := req.(proto.Message)
+		if s == nil || !ok || !s.sample(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		// handler可能修改请求，先复制
+		shadowReq := proto.Clone(msg)
+		resp, err := handler(ctx, req)
+		s.mirror(ctx, info.FullMethod, shadowReq, resp, err)
+		return resp, err
+	}
+}
+
+// shadowStreamServerInterceptor 标记影子流量，stream请求不复制
+func shadowStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isShadow(ss.Context()) {
+		return handler(srv, contextedServerStream{ServerStream: ss, ctx: withShadow(ss.Context())})
+	}
+	return handler(srv, ss)
+}
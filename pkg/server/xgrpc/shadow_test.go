@@ -0,0 +1,164 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/core/imeta"
+	"github.com/douyu/jupiter/pkg/core/metric"
+	helloworldv1 "github.com/douyu/jupiter/proto/helloworld/v1"
+	"github.com/juju/ratelimit"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// shadowGreeter 影子服务，记录请求是否带有影子标记
+type shadowGreeter struct {
+	helloworldv1.GreeterServiceServer
+	shadow chan bool
+}
+
+func (g *shadowGreeter) SayHello(ctx context.Context, in *helloworldv1.SayHelloRequest) (*helloworldv1.SayHelloResponse, error) {
+	md, _ := imeta.FromContext(ctx)
+	g.shadow <- md.IsShadow()
+	name := in.GetName()
+	if name == "diff" {
+		name = "shadow"
+	}
+	return &helloworldv1.SayHelloResponse{Data: &helloworldv1.SayHelloResponse_Data{Name: name}}, nil
+}
+
+func TestShadow(t *testing.T) {
+	target := DefaultConfig()
+	target.Host = "127.0.0.1"
+	target.Port = 0
+	ts := target.MustBuild()
+	greeter := &shadowGreeter{shadow: make(chan bool, 10)}
+	helloworldv1.RegisterGreeterServiceServer(ts.Server, greeter)
+	go func() {
+		_ = ts.Serve()
+	}()
+	defer ts.Stop()
+
+	conn, err := grpc.NewClient(target.Address(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	config := DefaultConfig()
+	config.Shadow = ShadowConfig{Enable: true, Percent: 100, Rate: 100, MaxConcurrency: 1, Timeout: time.Second, Compare: true}
+	s, err := newShadower(config.WithShadowClient(conn))
+	assert.Nil(t, err)
+	interceptor := shadowUnaryServerInterceptor(s)
+
+	method := "/helloworld.v1.GreeterService/SayHello"
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(":authority", "test", "x-test", "1"))
+	call := func(name string) {
+		_, err := interceptor(ctx, &helloworldv1.SayHelloRequest{Name: name}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			in := req.(*helloworldv1.SayHelloRequest)
+			name := in.Name
+			// 修改请求不影响影子请求
+			in.Name = "changed"
+			return &helloworldv1.SayHelloResponse{Data: &helloworldv1.SayHelloResponse_Data{Name: name}}, nil
+		})
+		assert.Nil(t, err)
+	}
+	counter := func(result string) float64 {
+		return testutil.ToFloat64(metric.ServerShadowCounter.WithLabelValues(method, result))
+	}
+
+	// 响应一致
+	match := counter("match")
+	call("same")
+	assert.True(t, <-greeter.shadow)
+	assert.Eventually(t, func() bool { return counter("match")-match == 1 }, time.Second, 10*time.Millisecond)
+
+	// 响应不一致
+	mismatch := counter("mismatch")
+	call("diff")
+	assert.True(t, <-greeter.shadow)
+	assert.Eventually(t, func() bool { return counter("mismatch")-mismatch == 1 }, time.Second, 10*time.Millisecond)
+
+	// 超过并发限制
+	limited := counter("concurrency_limited")
+	s.sem <- struct{}{}
+	call("same")
+	<-s.sem
+	assert.Equal(t, float64(1), counter("concurrency_limited")-limited)
+
+	// 超过速率限制
+	limited = counter("rate_limited")
+	s.bucket = ratelimit.NewBucketWithRate(0.1, 1)
+	s.bucket.TakeAvailable(1)
+	call("same")
+	assert.Equal(t, float64(1), counter("rate_limited")-limited)
+
+	// 影子流量不再复制，下游可以取得影子标记
+	shadowCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(imeta.KeyShadow, "1"))
+	_, err = interceptor(shadowCtx, &helloworldv1.SayHelloRequest{}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		md, _ := imeta.FromContext(ctx)
+		assert.True(t, md.IsShadow())
+		return nil, nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, greeter.shadow, 0)
+}
+
+func TestShadow_Config(t *testing.T) {
+	config := DefaultConfig()
+	config.Shadow.Enable = true
+	config.Shadow.Percent = 100
+	_, err := newShadower(config)
+	assert.NotNil(t, err)
+
+	config.Shadow.Percent = 101
+	_, err = newShadower(config.WithShadowClient(new(grpc.ClientConn)))
+	assert.NotNil(t, err)
+
+	config.Shadow.Percent = 100
+	config.Shadow.Methods = []string{"/helloworld.v1.GreeterService/*"}
+	s, err := newShadower(config)
+	assert.Nil(t, err)
+	assert.True(t, s.match("/helloworld.v1.GreeterService/SayHello"))
+	assert.False(t, s.match("/grpc.health.v1.Health/Check"))
+}
+
+func TestShadow_Compare(t *testing.T) {
+	s, err := newShadower(DefaultConfig().WithShadowClient(new(grpc.ClientConn)))
+	assert.Nil(t, err)
+
+	// 原请求失败时按方法的描述创建响应
+	reply := s.newReply("/helloworld.v1.GreeterService/SayHello", nil)
+	assert.IsType(t, &helloworldv1.SayHelloResponse{}, reply)
+	assert.Nil(t, s.newReply("/unknown.Service/Method", nil))
+
+	method := "/test/compare"
+	counter := func(result string) float64 {
+		return testutil.ToFloat64(metric.ServerShadowCounter.WithLabelValues(method, result))
+	}
+	match, mismatch := counter("match"), counter("mismatch")
+	s.compare(method, nil, status.Error(codes.NotFound, "a"), reply, status.Error(codes.NotFound, "b"))
+	s.compare(method, nil, status.Error(codes.NotFound, "a"), reply, nil)
+	assert.Equal(t, float64(1), counter("match")-match)
+	assert.Equal(t, float64(1), counter("mismatch")-mismatch)
+}
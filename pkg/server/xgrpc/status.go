@@ -0,0 +1,118 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/alibaba/sentinel-golang/core/flow"
+	"github.com/douyu/jupiter/pkg/util/xerror"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// defaultRetryDelay 限流时建议客户端的重试间隔
+const defaultRetryDelay = time.Second
+
+// statusUnaryServerInterceptor 将handler返回的错误转化为标准的gRPC status
+func statusUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, toStatusError(err)
+}
+
+// statusStreamServerInterceptor 将handler返回的错误转化为标准的gRPC status
+func statusStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return toStatusError(handler(srv, ss))
+}
+
+// toStatusError 转化规则:
+//   - sentinel的BlockError转化为ResourceExhausted，熔断转化为Unavailable，附带RetryInfo
+//   - xerror.Err 转化为对应的gRPC错误码，自定义错误码和Data记录在ErrorInfo中
+//   - 自定义错误码的status(如ecode)转化为Unknown，原始错误码记录在ErrorInfo中
+func toStatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var blockErr *base.BlockError
+	if errors.As(err, &blockErr) {
+		return blockStatus(blockErr).Err()
+	}
+
+	var xerr *xerror.Err
+	if errors.As(err, &xerr) {
+		return xerr.GRPCStatus().Err()
+	}
+
+	if s, ok := status.FromError(err); ok {
+		return xerror.Canonical(s).Err()
+	}
+	return err
+}
+
+// blockStatus 将sentinel的BlockError转化为status
+func blockStatus(err *base.BlockError) *status.Status {
+	code := codes.ResourceExhausted
+	delay := defaultRetryDelay
+
+	switch rule := err.TriggeredRule().(type) {
+	case *circuitbreaker.Rule:
+		if rule.RetryTimeoutMs > 0 {
+			delay = time.Duration(rule.RetryTimeoutMs) * time.Millisecond
+		}
+	case *flow.Rule:
+		if rule.StatIntervalInMs > 0 {
+			delay = time.Duration(rule.StatIntervalInMs) * time.Millisecond
+		}
+	}
+	if err.BlockType() == base.BlockTypeCircuitBreaking {
+		code = codes.Unavailable
+	}
+
+	s := status.New(code, err.Error())
+	if ds, e := s.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}); e == nil {
+		return ds
+	}
+	return s
+}
+
+// panicStatus 将panic转化为Internal，correlation id用于关联服务端的recovery日志
+func panicStatus(id string) *status.Status {
+	s := status.New(codes.Internal, "internal error, correlation id: "+id)
+	if ds, err := s.WithDetails(&errdetails.RequestInfo{RequestId: id}); err == nil {
+		return ds
+	}
+	return s
+}
+
+// correlationID 优先使用trace id，没有时随机生成
+func correlationID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/douyu/jupiter/pkg/util/xerror"
+	"github.com/douyu/jupiter/pkg/xlog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatusError(t *testing.T) {
+	assert.Nil(t, toStatusError(nil))
+
+	err := errors.New("plain")
+	assert.Equal(t, err, toStatusError(err))
+
+	s := status.Convert(toStatusError(base.NewBlockError(base.WithBlockType(base.BlockTypeFlow))))
+	assert.Equal(t, codes.ResourceExhausted, s.Code())
+	assert.Equal(t, time.Second, s.Details()[0].(*errdetails.RetryInfo).RetryDelay.AsDuration())
+
+	s = status.Convert(toStatusError(base.NewBlockError(
+		base.WithBlockType(base.BlockTypeCircuitBreaking),
+		base.WithRule(&circuitbreaker.Rule{RetryTimeoutMs: 3000}),
+	)))
+	assert.Equal(t, codes.Unavailable, s.Code())
+	assert.Equal(t, 3*time.Second, s.Details()[0].(*errdetails.RetryInfo).RetryDelay.AsDuration())
+
+	s = status.Convert(toStatusError(&xerror.Err{Ecode: 10001, Msg: "biz error", Data: "data"}))
+	assert.Equal(t, codes.Unknown, s.Code())
+	assert.Equal(t, &xerror.Err{Ecode: 10001, Msg: "biz error", Data: "data"}, xerror.FromStatus(s))
+
+	s = status.Convert(toStatusError(status.Error(codes.Code(10002), "ecode error")))
+	assert.Equal(t, codes.Unknown, s.Code())
+	assert.Equal(t, int32(10002), xerror.FromStatus(s).Ecode)
+}
+
+func TestRecoveryUnaryServerInterceptor(t *testing.T) {
+	interceptor := recoveryUnaryServerInterceptor(xlog.Jupiter())
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			panic("boom")
+		})
+
+	s := status.Convert(err)
+	assert.Equal(t, codes.Internal, s.Code())
+	assert.NotContains(t, s.Message(), "boom")
+	id := s.Details()[0].(*errdetails.RequestInfo).RequestId
+	assert.Len(t, id, 32)
+	assert.Contains(t, s.Message(), id)
+}
@@ -17,6 +17,7 @@ package xgrpc
 import (
 	"context"
 
+	"github.com/douyu/jupiter/pkg/util/xstring"
 	"google.golang.org/grpc"
 )
 
@@ -52,3 +53,12 @@ func UnaryInterceptorChain(interceptors ...grpc.UnaryServerInterceptor) grpc.Una
 		return chain(ctx, req)
 	}
 }
+
+// funcNames returns the names of interceptors.
+func funcNames[T any](fns []T) []string {
+	names := make([]string, 0, len(fns))
+	for _, fn := range fns {
+		names = append(names, xstring.FunctionName(fn))
+	}
+	return names
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xgrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type validateRequest struct {
+	name string
+}
+
+func (r *validateRequest) Validate() error {
+	if r.name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func TestValidateUnaryServerInterceptor(t *testing.T) {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+
+	reply, err := validateUnaryServerInterceptor(context.Background(), &validateRequest{name: "jupiter"}, info, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", reply)
+
+	_, err = validateUnaryServerInterceptor(context.Background(), &validateRequest{}, info, handler)
+	s := status.Convert(toStatusError(err))
+	assert.Equal(t, codes.InvalidArgument, s.Code())
+	assert.Equal(t, "name is required", s.Details()[0].(*errdetails.BadRequest).FieldViolations[0].Description)
+}
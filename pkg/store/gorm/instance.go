@@ -17,6 +17,7 @@ package gorm
 import (
 	"errors"
 
+	"github.com/douyu/jupiter/pkg/core/inspect"
 	prome "github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/util/xretry"
 	"github.com/douyu/jupiter/pkg/xlog"
@@ -67,5 +68,24 @@ func dial(name string, config *Config) *gorm.DB {
 		}
 	}
 
+	inspect.Register(prome.TypeGorm, name, config, func() inspect.Stats {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil
+		}
+		stats := sqlDB.Stats()
+		return inspect.Stats{
+			"max_open_conns":       stats.MaxOpenConnections,
+			"open_conns":           stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"wait_count":           stats.WaitCount,
+			"wait_duration":        stats.WaitDuration,
+			"max_idle_closed":      stats.MaxIdleClosed,
+			"max_idle_time_closed": stats.MaxIdleTimeClosed,
+			"max_lifetime_closed":  stats.MaxLifetimeClosed,
+		}
+	})
+
 	return db
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"github.com/douyu/jupiter/pkg/core/inspect"
+	"github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/xlog"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -32,6 +34,11 @@ func newSession(config *Config) *mongo.Client {
 	}
 
 	_instances.Store(config.Name, client)
+	inspect.Register(metric.TypeMongo, config.Name, config, func() inspect.Stats {
+		return inspect.Stats{
+			"sessions_in_progress": client.NumberSessionsInProgress(),
+		}
+	})
 	return client
 }
 
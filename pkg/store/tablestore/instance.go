@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/douyu/jupiter/pkg/core/inspect"
 	prome "github.com/douyu/jupiter/pkg/core/metric"
 	"github.com/douyu/jupiter/pkg/util/xdebug"
 	"github.com/douyu/jupiter/pkg/util/xstring"
@@ -49,6 +50,7 @@ func newTs(config *Config) *tablestore.TableStoreClient {
 			},
 		},
 	}
+	inspect.Register(prome.TypeTableStore, config.Name, config, nil)
 	return tablestore.NewClientWithConfig(config.EndPoint, config.Instance, config.AccessKeyId, config.AccessKeySecret, config.SecurityToken, tsConfig)
 }
 
@@ -110,11 +110,7 @@ func Convert(err error) *Err {
 	// 将status.error转化为Err
 	gs, ok := status.FromError(err)
 	if ok {
-		return &Err{
-			Ecode: ErrsFromGRPCCode(gs.Code()),
-			Msg:   gs.Message(),
-			Data:  struct{}{},
-		}
+		return FromStatus(gs)
 	}
 	return &Err{
 		Ecode: int32(UnknownCode),
@@ -124,7 +120,11 @@ func Convert(err error) *Err {
 }
 
 // GRPCStatus returns the Status represented by se.
+// 非标准gRPC错误码以及Data记录在ErrorInfo中，由FromStatus还原
 func (e *Err) GRPCStatus() *status.Status {
 	s := status.New(GRPCCodeFromeErrs(e.Ecode), e.Msg)
+	if !isGRPCCode(e.Ecode) || hasData(e.Data) {
+		s = withErrorInfo(s, e.Ecode, e.Data)
+	}
 	return s
 }
@@ -91,6 +91,28 @@ func TestErr(t *testing.T) {
 		s := status.New(codes.Unknown, error1.Msg)
 		assert.Equal(t, s, error1.GRPCStatus())
 	})
+	t.Run("StatusDetails", func(t *testing.T) {
+		error1 := &Err{Ecode: 10001, Msg: "biz error", Data: map[string]interface{}{"id": "1"}}
+		s := error1.GRPCStatus()
+		assert.Equal(t, codes.Unknown, s.Code())
+		assert.Equal(t, error1, Convert(s.Err()))
+		assert.Equal(t, codes.Code(10001), Restore(s).Code())
+
+		error2 := InvalidArgument.WithMsg("invalid name").WithData([]interface{}{"name"})
+		s = error2.GRPCStatus()
+		assert.Equal(t, codes.InvalidArgument, s.Code())
+		assert.Equal(t, s, Restore(s))
+		assert.Equal(t, error2, Convert(s.Err()))
+
+		s = Canonical(status.New(codes.Code(10002), "ecode error"))
+		assert.Equal(t, codes.Unknown, s.Code())
+		assert.Equal(t, &Err{Ecode: 10002, Msg: "ecode error", Data: struct{}{}}, Convert(s.Err()))
+		assert.Equal(t, codes.Code(10002), Restore(s).Code())
+		assert.Equal(t, int32(10002), Convert(Restore(s).Err()).Ecode)
+
+		s = status.New(codes.NotFound, "not found")
+		assert.Equal(t, s, Canonical(s))
+	})
 	t.Run("CodeConvert", func(t *testing.T) {
 		assert.Equal(t, GRPCCodeFromeErrs(OK.Ecode), codes.OK)
 		assert.Equal(t, ErrsFromGRPCCode(codes.OK), OK.Ecode)
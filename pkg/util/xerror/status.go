@@ -0,0 +1,125 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xerror
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Domain ErrorInfo的domain，标识由jupiter写入的错误详情
+	Domain = "jupiter"
+	// Reason ErrorInfo的reason
+	Reason = "ECODE"
+
+	metaEcode = "ecode"
+	metaData  = "data"
+)
+
+// Canonical 将自定义错误码的status转化为标准gRPC错误码codes.Unknown，
+// 原始错误码记录在ErrorInfo中，客户端通过Restore还原
+func Canonical(s *status.Status) *status.Status {
+	if isGRPCCode(int32(s.Code())) {
+		return s
+	}
+	p := s.Proto()
+	ecode := p.Code
+	p.Code = int32(codes.Unknown)
+	return withErrorInfo(status.FromProto(p), ecode, nil)
+}
+
+// Restore 还原Canonical或Err.GRPCStatus记录在ErrorInfo中的原始错误码
+func Restore(s *status.Status) *status.Status {
+	info := errorInfo(s)
+	if info == nil {
+		return s
+	}
+	ecode, err := strconv.ParseInt(info.Metadata[metaEcode], 10, 32)
+	if err != nil || int32(ecode) == int32(s.Code()) {
+		return s
+	}
+	p := s.Proto()
+	p.Code = int32(ecode)
+	return status.FromProto(p)
+}
+
+// FromStatus 将status转化为Err，错误码和数据优先从ErrorInfo中还原，
+// Data经过json编解码，结构体会还原为map[string]interface{}
+func FromStatus(s *status.Status) *Err {
+	e := &Err{
+		Ecode: int32(s.Code()),
+		Msg:   s.Message(),
+		Data:  struct{}{},
+	}
+	if isGRPCCode(e.Ecode) {
+		e.Ecode = ErrsFromGRPCCode(s.Code())
+	}
+
+	info := errorInfo(s)
+	if info == nil {
+		return e
+	}
+	if ecode, err := strconv.ParseInt(info.Metadata[metaEcode], 10, 32); err == nil {
+		e.Ecode = int32(ecode)
+	}
+	if raw, ok := info.Metadata[metaData]; ok {
+		var data interface{}
+		if err := json.Unmarshal([]byte(raw), &data); err == nil {
+			e.Data = data
+		}
+	}
+	return e
+}
+
+func isGRPCCode(code int32) bool {
+	return code >= int32(codes.OK) && code <= int32(codes.Unauthenticated)
+}
+
+func hasData(data interface{}) bool {
+	return data != nil && !reflect.DeepEqual(data, struct{}{})
+}
+
+func withErrorInfo(s *status.Status, ecode int32, data interface{}) *status.Status {
+	info := &errdetails.ErrorInfo{
+		Reason:   Reason,
+		Domain:   Domain,
+		Metadata: map[string]string{metaEcode: strconv.Itoa(int(ecode))},
+	}
+	if hasData(data) {
+		if bs, err := json.Marshal(data); err == nil {
+			info.Metadata[metaData] = string(bs)
+		}
+	}
+	// codes.OK 不能附带详情
+	if ds, err := s.WithDetails(info); err == nil {
+		return ds
+	}
+	return s
+}
+
+func errorInfo(s *status.Status) *errdetails.ErrorInfo {
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == Domain {
+			return info
+		}
+	}
+	return nil
+}
@@ -9,6 +9,10 @@ import (
 
 // Address means the address of the service to be registered
 func Address(listener net.Listener) string {
+	// unix socket使用文件路径
+	if listener.Addr().Network() == "unix" {
+		return listener.Addr().String()
+	}
 	host, port := lo.Must2(net.SplitHostPort(listener.Addr().String()))
 	if host == "::" || host == "0.0.0.0" {
 		host, _, _ = GetLocalMainIP()
@@ -35,9 +35,17 @@ func TestAddress(t *testing.T) {
 			},
 			want: host + ":48081",
 		},
+		{
+			name: "unix",
+			args: args{
+				listener: lo.Must(net.Listen("unix", "/tmp/xnet_address_test.sock")),
+			},
+			want: "/tmp/xnet_address_test.sock",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			defer tt.args.listener.Close()
 			if got := Address(tt.args.listener); got != tt.want {
 				t.Errorf("Address() = %v, want %v", got, tt.want)
 			}
@@ -0,0 +1,230 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ewma provides a p2c which scores items by peak EWMA latency
+// times in-flight requests, the lower the better.
+package ewma
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/util/xp2c"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Config ...
+type Config struct {
+	// Decay 延迟衰减的时间常数，越小对延迟变化越敏感。
+	// 长时间没有请求的节点延迟会衰减到0，从而重新获得流量
+	Decay time.Duration
+	// Penalty 新加入节点的初始延迟，以及节点出错时记录的延迟
+	Penalty time.Duration
+	// CoolDown 节点加入或出错后的冷却时间，
+	// 冷却中的节点只有在另一个候选节点也在冷却时才会被选中
+	CoolDown time.Duration
+}
+
+// DefaultConfig ...
+func DefaultConfig() *Config {
+	return &Config{
+		Decay:    10 * time.Second,
+		Penalty:  250 * time.Millisecond,
+		CoolDown: 5 * time.Second,
+	}
+}
+
+type node struct {
+	item     interface{}
+	inflight int64
+
+	mu sync.Mutex
+	// latency peak EWMA latency in nanoseconds at stamp
+	latency   float64
+	stamp     time.Time
+	coolUntil time.Time
+}
+
+// cost returns the latency decayed to now times in-flight requests
+func (n *node) cost(decay time.Duration, now time.Time) float64 {
+	n.mu.Lock()
+	latency := n.latency * weight(now.Sub(n.stamp), decay)
+	n.mu.Unlock()
+
+	return latency * float64(atomic.LoadInt64(&n.inflight)+1)
+}
+
+func (n *node) cooling(now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return now.Before(n.coolUntil)
+}
+
+// observe records the rtt of a finished request. Latency goes up to a
+// higher rtt at once and down to a lower one gradually.
+func (n *node) observe(config *Config, rtt time.Duration, err error, now time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if failed(err) {
+		if rtt < config.Penalty {
+			rtt = config.Penalty
+		}
+		n.coolUntil = now.Add(config.CoolDown)
+	}
+
+	if sample := float64(rtt); sample > n.latency {
+		n.latency = sample
+	} else {
+		w := weight(now.Sub(n.stamp), config.Decay)
+		n.latency = n.latency*w + sample*(1-w)
+	}
+	n.stamp = now
+}
+
+// failed reports whether err is caused by the node rather than the
+// caller or the business
+func failed(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal:
+		return true
+	}
+	return false
+}
+
+func weight(elapsed, decay time.Duration) float64 {
+	if elapsed <= 0 {
+		return 1
+	}
+	if decay <= 0 {
+		return 0
+	}
+	return math.Exp(-float64(elapsed) / float64(decay))
+}
+
+// EWMA is a p2c keeping statistics of items across Update, it's safe
+// for concurrent use.
+type EWMA struct {
+	config *Config
+
+	mu    sync.Mutex
+	nodes []*node
+	rand  *rand.Rand
+	now   func() time.Time
+}
+
+var _ xp2c.P2c = (*EWMA)(nil)
+
+// New returns an EWMA p2c, DefaultConfig is used if config is nil
+func New(config *Config) *EWMA {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &EWMA{
+		config: config,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:    time.Now,
+	}
+}
+
+// Add adds an item, which starts cooling down
+func (p *EWMA) Add(item interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, n := range p.nodes {
+		if n.item == item {
+			return
+		}
+	}
+	p.nodes = append(p.nodes, p.newNode(item))
+}
+
+// Update replaces items, statistics of the remaining items are kept and
+// the new ones start cooling down
+func (p *EWMA) Update(items []interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old := make(map[interface{}]*node, len(p.nodes))
+	for _, n := range p.nodes {
+		old[n.item] = n
+	}
+
+	nodes := make([]*node, 0, len(items))
+	for _, item := range items {
+		if n, ok := old[item]; ok {
+			nodes = append(nodes, n)
+			continue
+		}
+		nodes = append(nodes, p.newNode(item))
+	}
+	p.nodes = nodes
+}
+
+func (p *EWMA) newNode(item interface{}) *node {
+	now := p.now()
+	return &node{
+		item:      item,
+		latency:   float64(p.config.Penalty),
+		stamp:     now,
+		coolUntil: now.Add(p.config.CoolDown),
+	}
+}
+
+// Next ...
+func (p *EWMA) Next() (interface{}, func(balancer.DoneInfo)) {
+	p.mu.Lock()
+	nodes := p.nodes
+	var a, b int
+	if len(nodes) > 1 {
+		// rand needs lock
+		a = p.rand.Intn(len(nodes))
+		b = p.rand.Intn(len(nodes) - 1)
+		if b >= a {
+			b = b + 1
+		}
+	}
+	p.mu.Unlock()
+
+	if len(nodes) == 0 {
+		return nil, func(balancer.DoneInfo) {}
+	}
+
+	start := p.now()
+	n := nodes[a]
+	if len(nodes) > 1 && p.better(nodes[b], n, start) {
+		n = nodes[b]
+	}
+
+	atomic.AddInt64(&n.inflight, 1)
+	return n.item, func(di balancer.DoneInfo) {
+		atomic.AddInt64(&n.inflight, -1)
+		now := p.now()
+		n.observe(p.config, now.Sub(start), di.Err, now)
+	}
+}
+
+// better reports whether x should be picked rather than y
+func (p *EWMA) better(x, y *node, now time.Time) bool {
+	if xc, yc := x.cooling(now), y.cooling(now); xc != yc {
+		return !xc
+	}
+	return x.cost(p.config.Decay, now) < y.cost(p.config.Decay, now)
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ewma
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestEWMA(clock *fakeClock, items ...interface{}) *EWMA {
+	p := New(&Config{Decay: time.Second, Penalty: 100 * time.Millisecond, CoolDown: time.Second})
+	p.now = clock.now
+	p.rand = rand.New(rand.NewSource(1))
+	for _, item := range items {
+		p.Add(item)
+	}
+	return p
+}
+
+// setLatency sets the latency of item at now
+func setLatency(p *EWMA, item interface{}, latency time.Duration) {
+	for _, n := range p.nodes {
+		if n.item == item {
+			n.latency = float64(latency)
+			n.stamp = p.now()
+		}
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+
+	t.Run("0 item", func(t *testing.T) {
+		p := newTestEWMA(clock)
+		item, done := p.Next()
+		done(balancer.DoneInfo{})
+		assert.Nil(t, item)
+	})
+
+	t.Run("1 item", func(t *testing.T) {
+		p := newTestEWMA(clock, "a")
+		p.Add("a")
+		item, done := p.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, "a", item)
+		assert.Len(t, p.nodes, 1)
+	})
+
+	t.Run("latency times inflight", func(t *testing.T) {
+		p := newTestEWMA(clock, "a", "b")
+		clock.advance(time.Second)
+		setLatency(p, "a", 10*time.Millisecond)
+		setLatency(p, "b", 105*time.Millisecond)
+
+		// a is picked until 10ms * (inflight+1) exceeds 105ms
+		var picks []interface{}
+		for i := 0; i < 11; i++ {
+			item, _ := p.Next()
+			picks = append(picks, item)
+		}
+		assert.Equal(t, []interface{}{"a", "a", "a", "a", "a", "a", "a", "a", "a", "a", "b"}, picks)
+	})
+
+	t.Run("cool down", func(t *testing.T) {
+		p := newTestEWMA(clock, "a")
+		clock.advance(time.Second)
+		setLatency(p, "a", time.Second)
+
+		// newly added b is cooling down
+		p.Add("b")
+		setLatency(p, "b", time.Millisecond)
+		item, done := p.Next()
+		done(balancer.DoneInfo{})
+		assert.Equal(t, "a", item)
+
+		clock.advance(time.Second)
+		item, done = p.Next()
+		assert.Equal(t, "b", item)
+
+		// b fails and cools down again
+		done(balancer.DoneInfo{Err: status.Error(codes.Unavailable, "unavailable")})
+		assert.True(t, p.nodes[1].cooling(clock.now()))
+		assert.Equal(t, float64(100*time.Millisecond), p.nodes[1].latency)
+		item, _ = p.Next()
+		assert.Equal(t, "a", item)
+
+		// business errors don't
+		clock.advance(time.Second)
+		item, done = p.Next()
+		assert.Equal(t, "b", item)
+		done(balancer.DoneInfo{Err: status.Error(codes.InvalidArgument, "invalid")})
+		assert.False(t, p.nodes[1].cooling(clock.now()))
+	})
+
+	t.Run("peak and decay", func(t *testing.T) {
+		p := newTestEWMA(clock, "a")
+		n := p.nodes[0]
+		setLatency(p, "a", 10*time.Millisecond)
+
+		// higher rtt takes effect at once
+		n.observe(p.config, 50*time.Millisecond, nil, clock.now())
+		assert.Equal(t, float64(50*time.Millisecond), n.latency)
+
+		// lower rtt is averaged by elapsed time
+		clock.advance(time.Second)
+		n.observe(p.config, 10*time.Millisecond, nil, clock.now())
+		w := math.Exp(-1)
+		assert.InDelta(t, 50e6*w+10e6*(1-w), n.latency, 1)
+
+		// cost decays to zero without requests
+		clock.advance(time.Minute)
+		assert.Less(t, n.cost(p.config.Decay, clock.now()), float64(time.Microsecond))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		p := newTestEWMA(clock, "a", "b")
+		a := p.nodes[0]
+		p.Update([]interface{}{"c", "a"})
+		assert.Len(t, p.nodes, 2)
+		assert.Equal(t, "c", p.nodes[0].item)
+		assert.Same(t, a, p.nodes[1])
+	})
+}
@@ -0,0 +1,253 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xtls provides tls configs whose certificates are reloaded when
+// the files change on disk, so rotated certificates take effect without
+// restart.
+package xtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultCheckInterval is the minimum interval between two checks of
+// the certificate files
+var DefaultCheckInterval = 10 * time.Second
+
+// ErrNoCertificate no certificate configured
+var ErrNoCertificate = errors.New("xtls: no certificate")
+
+// Reloader holds a certificate and a CA pool loaded from files. Files
+// are checked at most once per interval during handshakes, and reloaded
+// if their modification time or size changed. A failed reload keeps the
+// previous certificates.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	interval time.Duration
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	stats     map[string]fileStat
+	lastCheck time.Time
+}
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader loads certFile/keyFile and caFile, each of them can be
+// empty. An empty caFile means the system roots.
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("xtls: cert file and key file must be set together")
+	}
+
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: DefaultCheckInterval,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Certificate returns the current certificate, reloading it if changed
+func (r *Reloader) Certificate() (*tls.Certificate, error) {
+	r.maybeReload()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil {
+		return nil, ErrNoCertificate
+	}
+	return r.cert, nil
+}
+
+// CertPool returns the current CA pool, nil for the system roots
+func (r *Reloader) CertPool() *x509.CertPool {
+	r.maybeReload()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+// ServerConfig returns a server side tls config. Client certificates
+// are required and verified against the CA pool if clientAuth is true,
+// the verified chains are kept in tls.ConnectionState.VerifiedChains.
+func (r *Reloader) ServerConfig(clientAuth bool) *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.Certificate()
+		},
+	}
+
+	if clientAuth {
+		// ClientCAs is fixed once the config is used, so every handshake
+		// gets a copy of config with the reloaded pool. The copy is made
+		// from config at handshake time, to keep changes made after
+		// ServerConfig returns, e.g. NextProtos set by http2.
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c := config.Clone()
+			c.GetConfigForClient = nil
+			c.ClientCAs = r.CertPool()
+			return c, nil
+		}
+	}
+	return config
+}
+
+// ClientConfig returns a client side tls config. serverName overrides
+// the name to verify, server certificate verification is skipped if
+// insecureSkipVerify is true, which is for development only.
+func (r *Reloader) ClientConfig(serverName string, insecureSkipVerify bool) *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		// RootCAs is fixed once the config is used, so verify server
+		// certificates by hand with the reloaded pool
+		InsecureSkipVerify: true,
+	}
+
+	if !insecureSkipVerify {
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			return r.verify(cs, cs.ServerName, x509.ExtKeyUsageServerAuth)
+		}
+	}
+
+	if r.certFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.Certificate()
+		}
+	}
+	return config
+}
+
+func (r *Reloader) verify(cs tls.ConnectionState, dnsName string, usage x509.ExtKeyUsage) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("xtls: no peer certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:       dnsName,
+		Roots:         r.CertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+func (r *Reloader) maybeReload() {
+	r.mu.RLock()
+	due := time.Since(r.lastCheck) >= r.interval
+	r.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	if r.changed() && r.load() == nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.lastCheck = time.Now()
+	r.mu.Unlock()
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			// keep the previous one, file may be in the middle of rotation
+			continue
+		}
+		if stat := r.stats[file]; !stat.modTime.Equal(info.ModTime()) || stat.size != info.Size() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) load() error {
+	stats := make(map[string]fileStat)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("xtls: %w", err)
+		}
+		stats[file] = fileStat{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("xtls: load key pair: %w", err)
+		}
+		cert = &pair
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		data, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("xtls: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return errors.New("xtls: no certificate found in " + r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = cert
+	r.pool = pool
+	r.stats = stats
+	r.lastCheck = time.Now()
+	return nil
+}
+
+func (r *Reloader) files() []string {
+	files := make([]string, 0, 3)
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
@@ -0,0 +1,247 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns cert and key pem signed by ca
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	assert.Nil(t, os.WriteFile(path, data, 0600))
+	assert.Nil(t, os.Chtimes(path, modTime, modTime))
+}
+
+// handshake dials a tls server and returns the common name of server certificate
+func handshake(t *testing.T, server, client *tls.Config) (string, error) {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	assert.Nil(t, err)
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		_, _ = conn.Read(make([]byte, 1))
+		conn.Close()
+	}()
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), client)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	// tls 1.3 client finishes handshake before the server verifies it
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			return "", err
+		}
+	}
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	file := func(name string) string { return filepath.Join(dir, name) }
+	past := time.Now().Add(-time.Minute)
+
+	ca := newTestCA(t)
+	writeFile(t, file("ca.pem"), ca.pem, past)
+	cert, key := ca.issue(t, "server.local", x509.ExtKeyUsageServerAuth)
+	writeFile(t, file("server.pem"), cert, past)
+	writeFile(t, file("server.key"), key, past)
+	cert, key = ca.issue(t, "client.local", x509.ExtKeyUsageClientAuth)
+	writeFile(t, file("client.pem"), cert, past)
+	writeFile(t, file("client.key"), key, past)
+
+	server, err := NewReloader(file("server.pem"), file("server.key"), file("ca.pem"))
+	assert.Nil(t, err)
+	client, err := NewReloader(file("client.pem"), file("client.key"), file("ca.pem"))
+	assert.Nil(t, err)
+
+	cn, err := handshake(t, server.ServerConfig(true), client.ClientConfig("server.local", false))
+	assert.Nil(t, err)
+	assert.Equal(t, "server.local", cn)
+
+	// wrong server name
+	_, err = handshake(t, server.ServerConfig(true), client.ClientConfig("other.local", false))
+	assert.NotNil(t, err)
+
+	// client without certificate is rejected
+	anonymous, err := NewReloader("", "", file("ca.pem"))
+	assert.Nil(t, err)
+	_, err = handshake(t, server.ServerConfig(true), anonymous.ClientConfig("server.local", false))
+	assert.NotNil(t, err)
+
+	// rotate server certificate
+	server.interval = 0
+	cert, key = ca.issue(t, "server.local", x509.ExtKeyUsageServerAuth)
+	writeFile(t, file("server.pem"), cert, time.Now())
+	writeFile(t, file("server.key"), key, time.Now())
+	current, err := server.Certificate()
+	assert.Nil(t, err)
+	assert.Equal(t, cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: current.Certificate[0]}))
+
+	// broken files keep the previous certificate
+	writeFile(t, file("server.pem"), []byte("broken"), time.Now().Add(time.Minute))
+	kept, err := server.Certificate()
+	assert.Nil(t, err)
+	assert.Equal(t, current, kept)
+
+	_, err = NewReloader(file("server.pem"), "", "")
+	assert.NotNil(t, err)
+}
+
+func TestReloader_ServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := func(name string) string { return filepath.Join(dir, name) }
+	past := time.Now().Add(-time.Minute)
+
+	ca := newTestCA(t)
+	writeFile(t, file("ca.pem"), ca.pem, past)
+	cert, key := ca.issue(t, "server.local", x509.ExtKeyUsageServerAuth)
+	writeFile(t, file("server.pem"), cert, past)
+	writeFile(t, file("server.key"), key, past)
+	cert, key = ca.issue(t, "client.local", x509.ExtKeyUsageClientAuth)
+	writeFile(t, file("client.pem"), cert, past)
+	writeFile(t, file("client.key"), key, past)
+
+	server, err := NewReloader(file("server.pem"), file("server.key"), file("ca.pem"))
+	assert.Nil(t, err)
+	client, err := NewReloader(file("client.pem"), file("client.key"), file("ca.pem"))
+	assert.Nil(t, err)
+
+	// verifiedChains returns the client chains verified by the server
+	verifiedChains := func() ([][]*x509.Certificate, error) {
+		l, err := tls.Listen("tcp", "127.0.0.1:0", server.ServerConfig(true))
+		assert.Nil(t, err)
+		defer l.Close()
+
+		states := make(chan tls.ConnectionState, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if conn.(*tls.Conn).Handshake() == nil {
+				states <- conn.(*tls.Conn).ConnectionState()
+			}
+			close(states)
+		}()
+
+		conn, err := tls.Dial("tcp", l.Addr().String(), client.ClientConfig("server.local", false))
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		state, ok := <-states
+		if !ok {
+			return nil, errors.New("handshake failed")
+		}
+		return state.VerifiedChains, nil
+	}
+
+	chains, err := verifiedChains()
+	assert.Nil(t, err)
+	assert.Len(t, chains, 1)
+	assert.Equal(t, "client.local", chains[0][0].Subject.CommonName)
+
+	// rotate the ca, the old client certificate is rejected
+	server.interval = 0
+	ca2 := newTestCA(t)
+	writeFile(t, file("ca.pem"), ca2.pem, time.Now())
+	_, err = verifiedChains()
+	assert.NotNil(t, err)
+
+	client.interval = 0
+	cert, key = ca2.issue(t, "client.local", x509.ExtKeyUsageClientAuth)
+	writeFile(t, file("client.pem"), cert, time.Now())
+	writeFile(t, file("client.key"), key, time.Now())
+	cert, key = ca2.issue(t, "server.local", x509.ExtKeyUsageServerAuth)
+	writeFile(t, file("server.pem"), cert, time.Now())
+	writeFile(t, file("server.key"), key, time.Now())
+	chains, err = verifiedChains()
+	assert.Nil(t, err)
+	assert.Len(t, chains, 1)
+}
@@ -0,0 +1,206 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xvalidate 校验请求消息，支持PGV生成的Validate/ValidateAll方法，
+// 以及通过Register注册的校验器(如protovalidate)
+package xvalidate
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/douyu/jupiter/pkg/util/xerror"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Validator 校验消息，消息合法时返回nil
+type Validator interface {
+	Validate(msg interface{}) error
+}
+
+// ValidatorFunc ...
+type ValidatorFunc func(msg interface{}) error
+
+// Validate ...
+func (fn ValidatorFunc) Validate(msg interface{}) error {
+	return fn(msg)
+}
+
+// FieldViolationer 校验器返回的错误实现该接口时，直接使用其中的字段错误
+type FieldViolationer interface {
+	FieldViolations() []*errdetails.BadRequest_FieldViolation
+}
+
+var (
+	mu         sync.RWMutex
+	validators []Validator
+)
+
+// Register 注册额外的校验器，在PGV生成的方法之后执行
+func Register(v Validator) {
+	mu.Lock()
+	defer mu.Unlock()
+	validators = append(validators, v)
+}
+
+// Error 校验失败的字段
+type Error struct {
+	Violations []*errdetails.BadRequest_FieldViolation
+}
+
+func (e *Error) Error() string {
+	list := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		if v.Field == "" {
+			list = append(list, v.Description)
+			continue
+		}
+		list = append(list, v.Field+": "+v.Description)
+	}
+	return "invalid argument: " + strings.Join(list, "; ")
+}
+
+// GRPCStatus InvalidArgument，附带BadRequest详情
+func (e *Error) GRPCStatus() *status.Status {
+	s := status.New(codes.InvalidArgument, e.Error())
+	if ds, err := s.WithDetails(&errdetails.BadRequest{FieldViolations: e.Violations}); err == nil {
+		return ds
+	}
+	return s
+}
+
+type fieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// MarshalJSON HTTP响应体，格式与xerror.Err一致
+func (e *Error) MarshalJSON() ([]byte, error) {
+	violations := make([]fieldViolation, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		violations = append(violations, fieldViolation{Field: v.Field, Description: v.Description})
+	}
+	return json.Marshal(xerror.InvalidArgument.WithMsg(e.Error()).WithData(map[string]interface{}{
+		"fieldViolations": violations,
+	}))
+}
+
+// Validate 校验消息，失败时返回*Error
+func Validate(msg interface{}) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+
+	// 优先使用ValidateAll，返回全部字段的错误
+	switch m := msg.(type) {
+	case interface{ ValidateAll() error }:
+		violations = append(violations, fieldViolations(m.ValidateAll())...)
+	case interface{ Validate() error }:
+		violations = append(violations, fieldViolations(m.Validate())...)
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, v := range validators {
+		violations = append(violations, fieldViolations(v.Validate(msg))...)
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return &Error{Violations: violations}
+}
+
+// ValidateRequest 校验请求，失败时按类型和方法计数
+func ValidateRequest(typ, method string, msg interface{}) error {
+	err := Validate(msg)
+	if err != nil {
+		metric.ServerValidationFailedCounter.Inc(typ, method)
+	}
+	return err
+}
+
+// pgvError PGV生成的字段错误
+type pgvError interface {
+	Field() string
+	Reason() string
+	Cause() error
+}
+
+// pgvMultiError PGV生成的ValidateAll返回的错误
+type pgvMultiError interface {
+	AllErrors() []error
+}
+
+func fieldViolations(err error) []*errdetails.BadRequest_FieldViolation {
+	if err == nil {
+		return nil
+	}
+
+	var fv FieldViolationer
+	if errors.As(err, &fv) {
+		return fv.FieldViolations()
+	}
+
+	var multi pgvMultiError
+	if errors.As(err, &multi) {
+		var violations []*errdetails.BadRequest_FieldViolation
+		for _, e := range multi.AllErrors() {
+			violations = append(violations, fieldViolations(e)...)
+		}
+		return violations
+	}
+
+	var pe pgvError
+	if errors.As(err, &pe) {
+		field := lowerFirst(pe.Field())
+		// 嵌套消息的错误，字段路径加上外层字段
+		if cause := pe.Cause(); cause != nil {
+			nested := fieldViolations(cause)
+			if len(nested) > 0 && isPGV(cause) {
+				for _, v := range nested {
+					v.Field = joinField(field, v.Field)
+				}
+				return nested
+			}
+		}
+		return []*errdetails.BadRequest_FieldViolation{{Field: field, Description: pe.Reason()}}
+	}
+
+	return []*errdetails.BadRequest_FieldViolation{{Description: err.Error()}}
+}
+
+func isPGV(err error) bool {
+	var pe pgvError
+	var multi pgvMultiError
+	return errors.As(err, &pe) || errors.As(err, &multi)
+}
+
+func joinField(parent, field string) string {
+	if field == "" {
+		return parent
+	}
+	return parent + "." + field
+}
+
+// lowerFirst PGV的字段名为Go字段名，转化为proto json的风格
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
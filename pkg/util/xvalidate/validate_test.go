@@ -0,0 +1,135 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xvalidate
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/douyu/jupiter/pkg/core/metric"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fieldError 与PGV生成的ValidationError一致
+type fieldError struct {
+	field  string
+	reason string
+	cause  error
+}
+
+func (e fieldError) Field() string  { return e.field }
+func (e fieldError) Reason() string { return e.reason }
+func (e fieldError) Cause() error   { return e.cause }
+func (e fieldError) Error() string  { return e.field + ": " + e.reason }
+
+// multiError 与PGV生成的MultiError一致
+type multiError []error
+
+func (m multiError) Error() string      { return "multi error" }
+func (m multiError) AllErrors() []error { return m }
+
+type request struct {
+	Name string
+	Age  int
+}
+
+func (r *request) ValidateAll() error {
+	var errs multiError
+	if r.Name == "" {
+		errs = append(errs, fieldError{field: "Name", reason: "value length must be at least 1 runes"})
+	}
+	if r.Age < 0 {
+		errs = append(errs, fieldError{field: "Age", reason: "value must be greater than or equal to 0"})
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+type nested struct {
+	Data *request
+}
+
+func (n *nested) Validate() error {
+	if err := n.Data.ValidateAll(); err != nil {
+		return fieldError{field: "Data", reason: "embedded message failed validation", cause: err}
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, Validate(&request{Name: "jupiter"}))
+	assert.Nil(t, Validate("no validate method"))
+
+	err := Validate(&request{Age: -1})
+	var verr *Error
+	assert.True(t, errors.As(err, &verr))
+	assert.Equal(t, []*errdetails.BadRequest_FieldViolation{
+		{Field: "name", Description: "value length must be at least 1 runes"},
+		{Field: "age", Description: "value must be greater than or equal to 0"},
+	}, verr.Violations)
+
+	err = Validate(&nested{Data: &request{}})
+	assert.True(t, errors.As(err, &verr))
+	assert.Equal(t, []*errdetails.BadRequest_FieldViolation{
+		{Field: "data.name", Description: "value length must be at least 1 runes"},
+	}, verr.Violations)
+}
+
+func TestError(t *testing.T) {
+	err := &Error{Violations: []*errdetails.BadRequest_FieldViolation{
+		{Field: "name", Description: "is required"},
+	}}
+	assert.Equal(t, "invalid argument: name: is required", err.Error())
+
+	s := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, s.Code())
+	assert.Equal(t, err.Violations[0].Field, s.Details()[0].(*errdetails.BadRequest).FieldViolations[0].Field)
+
+	body, _ := json.Marshal(err)
+	assert.JSONEq(t, `{"error":3,"msg":"invalid argument: name: is required","data":{"fieldViolations":[{"field":"name","description":"is required"}]}}`, string(body))
+}
+
+func TestRegister(t *testing.T) {
+	defer func() {
+		mu.Lock()
+		validators = nil
+		mu.Unlock()
+	}()
+
+	Register(ValidatorFunc(func(msg interface{}) error {
+		if s, ok := msg.(string); ok && strings.HasPrefix(s, "bad") {
+			return errors.New("starts with bad")
+		}
+		return nil
+	}))
+
+	assert.Nil(t, Validate("good"))
+	err := Validate("bad")
+	assert.Equal(t, "invalid argument: starts with bad", err.Error())
+
+	counter := metric.ServerValidationFailedCounter.WithLabelValues("http", "/test")
+	before := testutil.ToFloat64(counter)
+	assert.NotNil(t, ValidateRequest("http", "/test", "bad"))
+	assert.Nil(t, ValidateRequest("http", "/test", "good"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(counter)-before)
+}
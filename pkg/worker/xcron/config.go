@@ -158,6 +158,8 @@ type wrappedJob struct {
 	waitLockTime    time.Duration
 	leaseTTL        int
 	client          *etcdv3.Client
+
+	state *jobState
 }
 
 const (
@@ -169,6 +171,11 @@ const (
 
 // Run ...
 func (wj wrappedJob) Run() {
+	if IsPaused(wj.Name()) {
+		wj.logger.Info("skip paused job", xlog.String("name", wj.Name()))
+		return
+	}
+
 	if wj.distributedTask {
 		mutex, err := wj.client.NewMutex(WorkerLockDir+wj.Name(), concurrency.WithTTL(wj.leaseTTL))
 		if err != nil {
@@ -194,6 +201,7 @@ func (wj wrappedJob) run() (err error) {
 	metric.JobHandleCounter.Inc("cron", wj.Name(), "begin")
 	var fields = []xlog.Field{zap.String("name", wj.Name())}
 	var beg = time.Now()
+	wj.state.begin()
 	defer func() {
 		if rec := recover(); rec != nil {
 			switch rec := rec.(type) {
@@ -214,6 +222,7 @@ func (wj wrappedJob) run() (err error) {
 			wj.logger.Info("run", fields...)
 		}
 		metric.JobHandleHistogram.Observe(time.Since(beg).Seconds(), "cron", wj.Name())
+		wj.state.end(beg, err)
 	}()
 
 	return wj.NamedJob.Run()
@@ -15,6 +15,7 @@
 package xcron
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -73,6 +74,8 @@ func (f FuncJob) Name() string { return xstring.FunctionName(f) }
 type Cron struct {
 	*Config
 	*cron.Cron
+
+	mu      sync.RWMutex
 	entries map[string]EntryID
 }
 
@@ -88,12 +91,18 @@ func newCron(config *Config) *Cron {
 			cron.WithChain(config.wrappers...),
 			cron.WithLogger(&wrappedLogger{config.logger.Sugar()}),
 		),
+		entries: make(map[string]EntryID),
 	}
+	register(cron)
 	return cron
 }
 
 // Schedule ...
 func (c *Cron) Schedule(schedule Schedule, job NamedJob) EntryID {
+	return c.schedule(describeSchedule(schedule), schedule, job)
+}
+
+func (c *Cron) schedule(spec string, schedule Schedule, job NamedJob) EntryID {
 	if c.ImmediatelyRun {
 		schedule = &immediatelyScheduler{
 			Schedule: schedule,
@@ -107,16 +116,25 @@ func (c *Cron) Schedule(schedule Schedule, job NamedJob) EntryID {
 		waitLockTime:    c.WaitLockTime,
 		leaseTTL:        c.Config.TTL,
 		client:          c.client,
+
+		state: &jobState{spec: spec},
 	}
 	// xdebug.PrintKVWithPrefix("worker", "add job", job.Name())
 	c.logger.Info("add job", xlog.String("name", job.Name()))
-	return c.Cron.Schedule(schedule, innnerJob)
+	id := c.Cron.Schedule(schedule, innnerJob)
+
+	c.mu.Lock()
+	c.entries[job.Name()] = id
+	c.mu.Unlock()
+	return id
 }
 
 // GetEntryByName ...
 func (c *Cron) GetEntryByName(name string) cron.Entry {
-	// todo(gorexlv): data race
-	return c.Entry(c.entries[name])
+	c.mu.RLock()
+	id := c.entries[name]
+	c.mu.RUnlock()
+	return c.Entry(id)
 }
 
 // AddJob ...
@@ -125,7 +143,7 @@ func (c *Cron) AddJob(spec string, cmd NamedJob) (EntryID, error) {
 	if err != nil {
 		return 0, err
 	}
-	return c.Schedule(schedule, cmd), nil
+	return c.schedule(spec, schedule, cmd), nil
 }
 
 // AddFunc ...
@@ -144,6 +162,7 @@ func (c *Cron) Run() error {
 // Stop ...
 func (c *Cron) Stop() error {
 	_ = c.Cron.Stop()
+	unregister(c)
 	return nil
 }
 
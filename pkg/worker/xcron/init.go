@@ -0,0 +1,58 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xcron
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/douyu/jupiter/pkg/server/governor"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func init() {
+	// 列出全部定时任务
+	governor.HandleFunc("/debug/cron/list", func(w http.ResponseWriter, r *http.Request) {
+		_ = jsoniter.NewEncoder(w).Encode(Jobs())
+	})
+
+	// 手动执行，如 /debug/cron/trigger?name=main.syncUser
+	governor.HandleFunc("/debug/cron/trigger", jobHandler(Trigger))
+	// 暂停调度，暂停状态不受配置重载影响
+	governor.HandleFunc("/debug/cron/pause", jobHandler(Pause))
+	// 恢复调度
+	governor.HandleFunc("/debug/cron/resume", jobHandler(Resume))
+}
+
+func jobHandler(fn func(name string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		err := fn(r.URL.Query().Get("name"))
+		switch {
+		case errors.Is(err, ErrJobNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrJobPaused):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			_, _ = w.Write([]byte("SUCCESS"))
+		}
+	}
+}
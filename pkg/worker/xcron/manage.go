@@ -0,0 +1,226 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xcron
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	// ErrJobNotFound job not scheduled
+	ErrJobNotFound = errors.New("xcron: job not found")
+	// ErrJobPaused job paused, resume it before trigger
+	ErrJobPaused = errors.New("xcron: job paused")
+)
+
+var (
+	cmu   sync.RWMutex
+	crons []*Cron
+
+	// paused job names, kept by name rather than by Cron so that
+	// crons rebuilt on config reload stay paused
+	paused sync.Map
+)
+
+// JobInfo runtime info of a scheduled job
+type JobInfo struct {
+	Name    string    `json:"name"`
+	Spec    string    `json:"spec"`
+	Next    time.Time `json:"next"`
+	Prev    time.Time `json:"prev"`
+	Paused  bool      `json:"paused"`
+	Running bool      `json:"running"`
+	// LastRun start time of the last run, zero if never run
+	LastRun time.Time `json:"lastRun"`
+	// LastCost duration of the last run
+	LastCost string `json:"lastCost"`
+	// LastError error of the last run, empty if succeeded
+	LastError string `json:"lastError"`
+}
+
+type jobState struct {
+	spec string
+
+	mu      sync.RWMutex
+	running int
+	lastRun time.Time
+	cost    time.Duration
+	err     error
+}
+
+func (s *jobState) begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running++
+}
+
+func (s *jobState) end(beg time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running--
+	s.lastRun = beg
+	s.cost = time.Since(beg)
+	s.err = err
+}
+
+func (s *jobState) fill(info *JobInfo) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info.Spec = s.spec
+	info.Running = s.running > 0
+	info.LastRun = s.lastRun
+	if !s.lastRun.IsZero() {
+		info.LastCost = s.cost.String()
+	}
+	if s.err != nil {
+		info.LastError = s.err.Error()
+	}
+}
+
+// Jobs returns the runtime info of all jobs of c
+func (c *Cron) Jobs() []JobInfo {
+	list := make([]JobInfo, 0)
+	for _, entry := range c.Entries() {
+		wj, ok := entry.Job.(*wrappedJob)
+		if !ok {
+			continue
+		}
+
+		info := JobInfo{
+			Name:   wj.Name(),
+			Next:   entry.Next,
+			Prev:   entry.Prev,
+			Paused: IsPaused(wj.Name()),
+		}
+		wj.state.fill(&info)
+		list = append(list, info)
+	}
+	return list
+}
+
+// Trigger runs the job right now in a new goroutine, with the job
+// wrappers of c applied
+func (c *Cron) Trigger(name string) error {
+	c.mu.RLock()
+	id, ok := c.entries[name]
+	c.mu.RUnlock()
+	if !ok {
+		return ErrJobNotFound
+	}
+	if IsPaused(name) {
+		return ErrJobPaused
+	}
+
+	entry := c.Entry(id)
+	if !entry.Valid() {
+		return ErrJobNotFound
+	}
+	go entry.WrappedJob.Run()
+	return nil
+}
+
+func (c *Cron) hasJob(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.entries[name]
+	return ok
+}
+
+// Pause stops scheduled runs of the job until Resume, the job is still
+// scheduled and runs are skipped
+func Pause(name string) error {
+	if findCron(name) == nil {
+		return ErrJobNotFound
+	}
+	paused.Store(name, struct{}{})
+	return nil
+}
+
+// Resume resumes the paused job
+func Resume(name string) error {
+	if findCron(name) == nil {
+		return ErrJobNotFound
+	}
+	paused.Delete(name)
+	return nil
+}
+
+// IsPaused returns whether the job is paused
+func IsPaused(name string) bool {
+	_, ok := paused.Load(name)
+	return ok
+}
+
+// Trigger runs the job right now, see Cron.Trigger
+func Trigger(name string) error {
+	c := findCron(name)
+	if c == nil {
+		return ErrJobNotFound
+	}
+	return c.Trigger(name)
+}
+
+// Jobs returns the runtime info of jobs of all crons
+func Jobs() []JobInfo {
+	cmu.RLock()
+	defer cmu.RUnlock()
+
+	list := make([]JobInfo, 0)
+	for _, c := range crons {
+		list = append(list, c.Jobs()...)
+	}
+	return list
+}
+
+func findCron(name string) *Cron {
+	cmu.RLock()
+	defer cmu.RUnlock()
+	for _, c := range crons {
+		if c.hasJob(name) {
+			return c
+		}
+	}
+	return nil
+}
+
+func register(c *Cron) {
+	cmu.Lock()
+	defer cmu.Unlock()
+	crons = append(crons, c)
+}
+
+func unregister(c *Cron) {
+	cmu.Lock()
+	defer cmu.Unlock()
+	for i, item := range crons {
+		if item == c {
+			crons = append(crons[:i], crons[i+1:]...)
+			return
+		}
+	}
+}
+
+// describeSchedule returns the spec of schedules not parsed from spec
+func describeSchedule(schedule Schedule) string {
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		return "@every " + every.Delay.String()
+	}
+	return fmt.Sprintf("%T", schedule)
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xcron
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/douyu/jupiter/pkg/server/governor"
+	"github.com/stretchr/testify/assert"
+)
+
+type countJob struct {
+	name  string
+	count int32
+	err   error
+}
+
+func (j *countJob) Run() error {
+	atomic.AddInt32(&j.count, 1)
+	return j.err
+}
+
+func (j *countJob) Name() string { return j.name }
+
+func TestManage(t *testing.T) {
+	c := DefaultConfig().Build()
+	job := &countJob{name: "count", err: errors.New("boom")}
+	_, err := c.AddJob("@every 1h", job)
+	assert.Nil(t, err)
+	c.Schedule(Every(time.Minute), &countJob{name: "every"})
+	c.Start()
+	defer c.Stop()
+
+	assert.Equal(t, ErrJobNotFound, Trigger("unknown"))
+	assert.Nil(t, Trigger("count"))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&job.count) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	jobs := make(map[string]JobInfo)
+	assert.Eventually(t, func() bool {
+		for _, info := range Jobs() {
+			jobs[info.Name] = info
+		}
+		return jobs["count"].LastError != ""
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "@every 1h", jobs["count"].Spec)
+	assert.Equal(t, "boom", jobs["count"].LastError)
+	assert.NotEmpty(t, jobs["count"].LastCost)
+	assert.False(t, jobs["count"].Next.IsZero())
+	assert.Equal(t, "@every 1m0s", jobs["every"].Spec)
+
+	// paused jobs are skipped and can't be triggered
+	assert.Nil(t, Pause("count"))
+	assert.True(t, IsPaused("count"))
+	assert.Equal(t, ErrJobPaused, Trigger("count"))
+	c.GetEntryByName("count").WrappedJob.Run()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&job.count))
+
+	// pause state survives rebuilding
+	c2 := DefaultConfig().Build()
+	defer c2.Stop()
+	c.Stop()
+	_, _ = c2.AddJob("@every 1h", job)
+	assert.True(t, IsPaused("count"))
+
+	w := httptest.NewRecorder()
+	governor.DefaultServeMux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/cron/resume?name=count", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, IsPaused("count"))
+
+	w = httptest.NewRecorder()
+	governor.DefaultServeMux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/cron/pause?name=unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
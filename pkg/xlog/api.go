@@ -18,6 +18,7 @@ import (
 	"context"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // L returns the standard logger.
@@ -119,3 +120,11 @@ func WithOptions(opts ...Option) *Logger {
 func Named(s string) *Logger {
 	return stdLogger.Named(s)
 }
+
+// Tee returns an Option that duplicates every entry of the logger to core,
+// e.g. an exporter sink.
+func Tee(core zapcore.Core) Option {
+	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return NewTraceCore(zapcore.NewTee(c, core))
+	})
+}
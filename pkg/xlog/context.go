@@ -44,15 +44,7 @@ func newContextWithJupiterLogger(ctx context.Context, l *Logger, traceID string)
 
 // Deprecated: use xlog.L instead
 func FromContext(ctx context.Context) *Logger {
-	if ctx == nil {
-		return defaultLogger
-	}
-
-	l, ok := ctx.Value(defaultLoggerKey{}).(*Logger)
-	if !ok {
-		return defaultLogger // default logger
-	}
-	return l
+	return getDefaultLoggerFromContext(ctx)
 }
 
 func getDefaultLoggerFromContext(ctx context.Context) *Logger {
@@ -62,9 +54,9 @@ func getDefaultLoggerFromContext(ctx context.Context) *Logger {
 
 	l, ok := ctx.Value(defaultLoggerKey{}).(*Logger)
 	if !ok {
-		return defaultLogger // default logger
+		return withTrace(ctx, defaultLogger, false) // default logger
 	}
-	return l
+	return withTrace(ctx, l, true)
 }
 
 func getJupiterLoggerFromContext(ctx context.Context) *Logger {
@@ -74,7 +66,7 @@ func getJupiterLoggerFromContext(ctx context.Context) *Logger {
 
 	l, ok := ctx.Value(jupiterLoggerKey{}).(*Logger)
 	if !ok {
-		return jupiterLogger // jupiter logger
+		return withTrace(ctx, jupiterLogger, false) // jupiter logger
 	}
-	return l
+	return withTrace(ctx, l, true)
 }
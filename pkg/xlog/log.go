@@ -140,7 +140,7 @@ func newLogger(config *Config) *zap.Logger {
 	}
 
 	zapLogger := zap.New(
-		core,
+		NewTraceCore(core),
 		zapOptions...,
 	)
 
@@ -0,0 +1,173 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	spanIDField  = "span_id"
+	contextField = "ctx"
+)
+
+// FieldTraceID ...
+func FieldTraceID(value string) Field {
+	return String(traceIDField, value)
+}
+
+// FieldSpanID ...
+func FieldSpanID(value string) Field {
+	return String(spanIDField, value)
+}
+
+// FieldContext carries ctx to the logger core, which replaces it with
+// the trace_id and span_id of the OpenTelemetry span in ctx. It is
+// dropped silently if ctx holds no valid span.
+func FieldContext(ctx context.Context) Field {
+	return Field{Key: contextField, Type: zapcore.SkipType, Interface: ctx}
+}
+
+// traceFields returns the trace_id and span_id fields of the span in ctx.
+func traceFields(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+
+	return []Field{
+		FieldTraceID(sc.TraceID().String()),
+		FieldSpanID(sc.SpanID().String()),
+	}
+}
+
+// withTrace attaches the span of ctx to l. If l already came from ctx
+// (NewContext stored it with a trace_id), only span_id is added.
+func withTrace(ctx context.Context, l *Logger, stored bool) *Logger {
+	fields := traceFields(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	if stored {
+		fields = fields[1:]
+	}
+
+	return l.With(fields...)
+}
+
+// traceCore expands FieldContext into trace fields before handing them
+// to the wrapped core.
+type traceCore struct {
+	zapcore.Core
+}
+
+// NewTraceCore wraps core so that FieldContext is expanded into trace fields.
+func NewTraceCore(core zapcore.Core) zapcore.Core {
+	if _, ok := core.(*traceCore); ok {
+		return core
+	}
+	return &traceCore{Core: core}
+}
+
+// With implements zapcore.Core.
+func (c *traceCore) With(fields []zapcore.Field) zapcore.Core {
+	return &traceCore{Core: c.Core.With(expandTraceFields(fields))}
+}
+
+// Check implements zapcore.Core. The wrapped core decides which of its
+// cores accept the entry (level, sampling, tee), and trace fields are
+// expanded when the entry is written to them.
+func (c *traceCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	checked := c.Core.Check(ent, nil)
+	if checked == nil {
+		return ce
+	}
+	return ce.AddCore(ent, &checkedCore{Core: c.Core, checked: checked})
+}
+
+// Write implements zapcore.Core.
+func (c *traceCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, expandTraceFields(fields))
+}
+
+// checkedCore writes an entry to the cores accepted by the wrapped core's Check.
+type checkedCore struct {
+	zapcore.Core
+	checked *zapcore.CheckedEntry
+}
+
+// Write implements zapcore.Core. CheckedEntry only reports the errors of
+// its cores to ErrorOutput, so they are collected there and returned.
+func (c *checkedCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	errs := &writeErrors{prefix: fmt.Sprintf("%v write error: ", c.checked.Time)}
+	c.checked.ErrorOutput = errs
+	c.checked.Write(expandTraceFields(fields)...)
+	return errs.err
+}
+
+// writeErrors collects the write errors reported by CheckedEntry.Write.
+type writeErrors struct {
+	prefix string
+	err    error
+}
+
+// Write implements zapcore.WriteSyncer.
+func (w *writeErrors) Write(p []byte) (int, error) {
+	msg := strings.TrimPrefix(strings.TrimSuffix(string(p), "\n"), w.prefix)
+	w.err = multierr.Append(w.err, errors.New(msg))
+	return len(p), nil
+}
+
+// Sync implements zapcore.WriteSyncer.
+func (w *writeErrors) Sync() error {
+	return nil
+}
+
+func expandTraceFields(fields []zapcore.Field) []zapcore.Field {
+	idx := -1
+	for i, f := range fields {
+		if f.Type == zapcore.SkipType && f.Key == contextField {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return fields
+	}
+
+	out := make([]zapcore.Field, 0, len(fields)+1)
+	for _, f := range fields {
+		if f.Type == zapcore.SkipType && f.Key == contextField {
+			if ctx, ok := f.Interface.(context.Context); ok {
+				out = append(out, traceFields(ctx)...)
+			}
+			continue
+		}
+		out = append(out, f)
+	}
+
+	return out
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 Douyu
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newSpanContext(t *testing.T) (context.Context, trace.SpanContext) {
+	traceID, err := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	assert.Nil(t, err)
+	spanID, err := trace.SpanIDFromHex("0102030405060708")
+	assert.Nil(t, err)
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+	return trace.ContextWithSpanContext(context.Background(), sc), sc
+}
+
+func TestTraceCorrelation(t *testing.T) {
+	config := DefaultConfig()
+	core, olog := observer.New(zapcore.InfoLevel)
+	config.Core = core
+	logger := config.Build()
+
+	old := defaultLogger
+	defer func() { defaultLogger = old }()
+	defaultLogger = logger
+
+	ctx, sc := newSpanContext(t)
+
+	L(ctx).Info("span")
+	L(context.Background()).Info("no span")
+	L(NewContext(ctx, logger, sc.TraceID().String())).Info("stored")
+	logger.Info("field", FieldContext(ctx))
+	logger.With(FieldContext(ctx)).Info("with")
+
+	logs := olog.All()
+	assert.Equal(t, 5, len(logs))
+
+	assert.Equal(t, sc.TraceID().String(), logs[0].ContextMap()["trace_id"])
+	assert.Equal(t, sc.SpanID().String(), logs[0].ContextMap()["span_id"])
+
+	assert.NotContains(t, logs[1].ContextMap(), "trace_id")
+	assert.NotContains(t, logs[1].ContextMap(), "span_id")
+
+	traceIDs := 0
+	for _, f := range logs[2].Context {
+		if f.Key == "trace_id" {
+			traceIDs++
+		}
+	}
+	assert.Equal(t, 1, traceIDs)
+	assert.Equal(t, sc.SpanID().String(), logs[2].ContextMap()["span_id"])
+
+	for _, entry := range logs[3:] {
+		assert.Equal(t, sc.TraceID().String(), entry.ContextMap()["trace_id"])
+		assert.Equal(t, sc.SpanID().String(), entry.ContextMap()["span_id"])
+		assert.NotContains(t, entry.ContextMap(), "ctx")
+	}
+}
+
+func TestTraceCoreCheck(t *testing.T) {
+	file, flog := observer.New(zapcore.InfoLevel)
+	sink, slog := observer.New(zapcore.DebugLevel)
+	logger := zap.New(file, Tee(sink))
+
+	ctx, sc := newSpanContext(t)
+	logger.Debug("debug", FieldContext(ctx))
+	logger.Info("info", FieldContext(ctx))
+
+	// 低于文件日志级别的日志只写入sink
+	assert.Equal(t, 1, flog.Len())
+	assert.Equal(t, "info", flog.All()[0].Message)
+	assert.Equal(t, 2, slog.Len())
+	for _, entry := range append(flog.All(), slog.All()...) {
+		assert.Equal(t, sc.TraceID().String(), entry.ContextMap()["trace_id"])
+		assert.NotContains(t, entry.ContextMap(), "ctx")
+	}
+
+	// 采样等基于Check的过滤仍然生效
+	sampled, olog := observer.New(zapcore.InfoLevel)
+	logger = zap.New(NewTraceCore(zapcore.NewSamplerWithOptions(sampled, time.Minute, 1, 0)))
+	for i := 0; i < 3; i++ {
+		logger.Info("sampled")
+	}
+	assert.Equal(t, 1, olog.Len())
+}
+
+type failingCore struct {
+	zapcore.LevelEnabler
+}
+
+func (c failingCore) With([]zapcore.Field) zapcore.Core { return c }
+func (c failingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+func (c failingCore) Write(zapcore.Entry, []zapcore.Field) error { return errors.New("disk full") }
+func (c failingCore) Sync() error                                { return nil }
+
+func TestTraceCoreWriteError(t *testing.T) {
+	core := NewTraceCore(zapcore.NewTee(failingCore{zapcore.InfoLevel}, failingCore{zapcore.InfoLevel}))
+
+	var errOutput bytes.Buffer
+	ce := core.Check(zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now()}, nil)
+	assert.NotNil(t, ce)
+	ce.ErrorOutput = zapcore.AddSync(&errOutput)
+	ce.Write()
+
+	// errors of the wrapped cores are returned, without the inner prefix
+	assert.Contains(t, errOutput.String(), "write error: disk full; disk full\n")
+	assert.Equal(t, 1, strings.Count(errOutput.String(), "write error"))
+}
@@ -7,6 +7,8 @@ package helloworldv1
 
 import (
 	context "context"
+	metric "github.com/douyu/jupiter/pkg/core/metric"
+	xvalidate "github.com/douyu/jupiter/pkg/util/xvalidate"
 	v4 "github.com/labstack/echo/v4"
 	metadata "google.golang.org/grpc/metadata"
 	http "net/http"
@@ -18,6 +20,8 @@ var _ = http.StatusOK
 var _ = new(context.Context)
 var _ = metadata.New
 var _ = v4.DefaultBinder{}
+var _ = metric.TypeHTTP
+var _ = xvalidate.ValidateRequest
 
 type GreeterServiceEchoServer interface {
 
@@ -48,6 +52,9 @@ func (s *_echo_GreeterService) _handler_SayHello_0(ctx v4.Context) error {
 		ctx.Error(err)
 		return nil
 	}
+	if err := xvalidate.ValidateRequest(metric.TypeHTTP, "/helloworld.v1.GreeterService/SayHello", &in); err != nil {
+		return ctx.JSON(http.StatusBadRequest, err)
+	}
 	md := metadata.New(nil)
 	for k, v := range ctx.Request().Header {
 		md.Set(k, v...)
@@ -69,6 +76,9 @@ func (s *_echo_GreeterService) _handler_SayHello_1(ctx v4.Context) error {
 		ctx.Error(err)
 		return nil
 	}
+	if err := xvalidate.ValidateRequest(metric.TypeHTTP, "/helloworld.v1.GreeterService/SayHello", &in); err != nil {
+		return ctx.JSON(http.StatusBadRequest, err)
+	}
 	md := metadata.New(nil)
 	for k, v := range ctx.Request().Header {
 		md.Set(k, v...)
@@ -90,6 +100,9 @@ func (s *_echo_GreeterService) _handler_SayHi_0(ctx v4.Context) error {
 		ctx.Error(err)
 		return nil
 	}
+	if err := xvalidate.ValidateRequest(metric.TypeHTTP, "/helloworld.v1.GreeterService/SayHi", &in); err != nil {
+		return ctx.JSON(http.StatusBadRequest, err)
+	}
 	md := metadata.New(nil)
 	for k, v := range ctx.Request().Header {
 		md.Set(k, v...)
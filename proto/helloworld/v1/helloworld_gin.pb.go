@@ -7,6 +7,8 @@ package helloworldv1
 
 import (
 	context "context"
+	metric "github.com/douyu/jupiter/pkg/core/metric"
+	xvalidate "github.com/douyu/jupiter/pkg/util/xvalidate"
 	gin "github.com/gin-gonic/gin"
 	metadata "google.golang.org/grpc/metadata"
 	http "net/http"
@@ -18,6 +20,8 @@ var _ = http.StatusOK
 var _ = new(context.Context)
 var _ = metadata.New
 var _ = gin.Engine{}
+var _ = metric.TypeHTTP
+var _ = xvalidate.ValidateRequest
 
 type GreeterServiceGinServer interface {
 
@@ -52,6 +56,10 @@ func (s *_gin_GreeterService) _gin_handler_SayHello_0(ctx *gin.Context) {
 		ctx.Error(err)
 		return
 	}
+	if err := xvalidate.ValidateRequest(metric.TypeHTTP, "/helloworld.v1.GreeterService/SayHello", &in); err != nil {
+		ctx.JSON(http.StatusBadRequest, err)
+		return
+	}
 	md := metadata.New(nil)
 	for k, v := range ctx.Request.Header {
 		md.Set(k, v...)
@@ -77,6 +85,10 @@ func (s *_gin_GreeterService) _gin_handler_SayHello_1(ctx *gin.Context) {
 		ctx.Error(err)
 		return
 	}
+	if err := xvalidate.ValidateRequest(metric.TypeHTTP, "/helloworld.v1.GreeterService/SayHello", &in); err != nil {
+		ctx.JSON(http.StatusBadRequest, err)
+		return
+	}
 	md := metadata.New(nil)
 	for k, v := range ctx.Request.Header {
 		md.Set(k, v...)
@@ -102,6 +114,10 @@ func (s *_gin_GreeterService) _gin_handler_SayHi_0(ctx *gin.Context) {
 		ctx.Error(err)
 		return
 	}
+	if err := xvalidate.ValidateRequest(metric.TypeHTTP, "/helloworld.v1.GreeterService/SayHi", &in); err != nil {
+		ctx.JSON(http.StatusBadRequest, err)
+		return
+	}
 	md := metadata.New(nil)
 	for k, v := range ctx.Request.Header {
 		md.Set(k, v...)